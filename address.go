@@ -0,0 +1,147 @@
+package terraconf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// A terraform address selecting resources, e.g. `module.vpc.aws_subnet.private[0]`, `aws_instance.web`
+// for every instance of a resource, or `module.vpc` for every resource in a module.
+type Address struct {
+	// Module names from the root, without the root module.
+	Module []string
+
+	// Nil when the address selects a whole module.
+	Resource *ResourceKey
+}
+
+func ParseAddress(s string) (*Address, error) {
+	a := &Address{}
+	rest := s
+
+	for strings.HasPrefix(rest, "module"+tfStateKeyDelimiter) {
+		rest = strings.TrimPrefix(rest, "module"+tfStateKeyDelimiter)
+
+		name := rest
+		if i := strings.Index(rest, tfStateKeyDelimiter); i >= 0 {
+			name = rest[:i]
+			rest = rest[i+1:]
+		} else {
+			rest = ""
+		}
+
+		if name == "" || strings.Contains(name, "[") {
+			return nil, fmt.Errorf("invalid address %q: unsupported module name %q", s, name)
+		}
+		a.Module = append(a.Module, name)
+	}
+
+	if rest == "" {
+		if len(a.Module) == 0 {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+		return a, nil
+	}
+
+	k, err := ParseResourceKey(rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %s", s, err)
+	}
+	a.Resource = k
+
+	return a, nil
+}
+
+// Whether the address selects the resource instance with the given key in the module at modulePath.
+func (a *Address) Matches(modulePath []string, k *ResourceKey) bool {
+	moduleNames := modulePath
+	if len(moduleNames) > 0 && moduleNames[0] == "root" {
+		moduleNames = moduleNames[1:]
+	}
+
+	if len(moduleNames) < len(a.Module) {
+		return false
+	}
+	for i, name := range a.Module {
+		if moduleNames[i] != name {
+			return false
+		}
+	}
+
+	// A module address also selects the resources of its child modules.
+	if a.Resource == nil {
+		return true
+	}
+
+	if len(moduleNames) != len(a.Module) {
+		return false
+	}
+
+	r := a.Resource
+	if r.Data != k.Data || r.Type != k.Type || r.Name != k.Name {
+		return false
+	}
+
+	// An address without an index selects every instance of the resource.
+	return r.Index == nil || r.Index == k.Index
+}
+
+// Returns a state with only the resources selected by the given addresses, e.g. to generate config
+// for exactly those resources. It is an error for an address to select nothing.
+func SelectResources(state *terraform.State, addresses []string) (*terraform.State, error) {
+	parsed := []*Address{}
+	for _, s := range addresses {
+		a, err := ParseAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, a)
+	}
+
+	selected := &terraform.State{
+		Version:   state.Version,
+		TFVersion: state.TFVersion,
+		Serial:    state.Serial,
+		Lineage:   state.Lineage,
+	}
+	matched := make([]bool, len(parsed))
+
+	for _, module := range state.Modules {
+		resources := map[string]*terraform.ResourceState{}
+
+		for key, resource := range module.Resources {
+			k, err := ParseResourceKey(key)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, a := range parsed {
+				if a.Matches(module.Path, k) {
+					resources[key] = resource
+					matched[i] = true
+				}
+			}
+		}
+
+		if len(resources) > 0 {
+			selected.Modules = append(selected.Modules, &terraform.ModuleState{
+				Path:      module.Path,
+				Resources: resources,
+			})
+		}
+	}
+
+	unmatched := []string{}
+	for i, ok := range matched {
+		if !ok {
+			unmatched = append(unmatched, addresses[i])
+		}
+	}
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("no resources found for %s", strings.Join(unmatched, ", "))
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,40 @@
+package terraconf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Renders comments tracing a generated resource back to its state entry: the original state address,
+// resource ID, provider and generation time. A zero generatedAt omits the timestamp, e.g. to keep
+// regenerated output stable.
+func ResourceAnnotationString(address string, state *terraform.ResourceState, generatedAt time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# address: %s\n", address)
+
+	if state.Primary != nil {
+		fmt.Fprintf(&b, "# id: %s\n", state.Primary.ID)
+	}
+
+	if state.Provider != "" {
+		fmt.Fprintf(&b, "# provider: %s\n", state.Provider)
+	}
+
+	if !generatedAt.IsZero() {
+		fmt.Fprintf(&b, "# generated: %s\n", generatedAt.UTC().Format(time.RFC3339))
+	}
+
+	return b.String()
+}
+
+// Prepends the source metadata comments of ResourceAnnotationString to generated resource config.
+func AnnotateResourceConfig(config string, address string, state *terraform.ResourceState, generatedAt time.Time) string {
+	if config == "" {
+		return ""
+	}
+
+	return ResourceAnnotationString(address, state, generatedAt) + config
+}
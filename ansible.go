@@ -0,0 +1,166 @@
+package terraconf
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Where the addresses and tags of a compute instance type are found in its expanded attributes, as
+// paths of map keys and list indexes.
+type AnsibleHostAttributes struct {
+	PublicIP  []string
+	PrivateIP []string
+	Tags      []string
+}
+
+// Characters Ansible doesn't allow in group names.
+var invalidAnsibleGroupChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Compute instance types exported by AnsibleExporter.
+var AnsibleComputeTypes = map[string]*AnsibleHostAttributes{
+	"aws_instance": {
+		PublicIP:  []string{"public_ip"},
+		PrivateIP: []string{"private_ip"},
+		Tags:      []string{"tags"},
+	},
+	"google_compute_instance": {
+		PublicIP:  []string{"network_interface", "0", "access_config", "0", "nat_ip"},
+		PrivateIP: []string{"network_interface", "0", "network_ip"},
+		Tags:      []string{"labels"},
+	},
+	"azurerm_linux_virtual_machine": {
+		PublicIP:  []string{"public_ip_address"},
+		PrivateIP: []string{"private_ip_address"},
+		Tags:      []string{"tags"},
+	},
+	"azurerm_windows_virtual_machine": {
+		PublicIP:  []string{"public_ip_address"},
+		PrivateIP: []string{"private_ip_address"},
+		Tags:      []string{"tags"},
+	},
+}
+
+// Exports the compute instances among resources as an Ansible YAML inventory, e.g. to manage the
+// hosts of adopted infrastructure. Hosts are named like PulumiExporter names resources and connect to
+// their public IP, or their private IP when they have none. Hosts are grouped by resource type and by
+// every tag, e.g. `tag_Environment_prod`, and carry their tags as host variables. Other resources are
+// left out.
+type AnsibleExporter struct {
+	generator *Generator
+}
+
+// Creates an exporter naming hosts with the remap rules of the given generator, or one with the
+// default options when nil.
+func NewAnsibleExporter(g *Generator) *AnsibleExporter {
+	if g == nil {
+		g = NewGenerator(nil)
+	}
+
+	return &AnsibleExporter{generator: g}
+}
+
+func (e *AnsibleExporter) Name() string {
+	return "ansible"
+}
+
+func (e *AnsibleExporter) Export(resources []*ResourceInstance, w io.Writer) error {
+	hosts := yaml.MapSlice{}
+	groups := map[string][]string{}
+
+	for _, r := range resources {
+		attrs, ok := AnsibleComputeTypes[r.Key.Type]
+		if !ok || r.Key.Data || r.State.Primary == nil {
+			continue
+		}
+
+		name := e.generator.qualifiedName(r)
+		values := expandAttributes(r.State.Primary.Attributes)
+		publicIP, _ := attributeAtPath(values, attrs.PublicIP).(string)
+		privateIP, _ := attributeAtPath(values, attrs.PrivateIP).(string)
+		tags, _ := attributeAtPath(values, attrs.Tags).(map[string]interface{})
+
+		vars := yaml.MapSlice{}
+		if host := publicIP; host != "" || privateIP != "" {
+			if host == "" {
+				host = privateIP
+			}
+			vars = append(vars, yaml.MapItem{Key: "ansible_host", Value: host})
+		}
+		if privateIP != "" {
+			vars = append(vars, yaml.MapItem{Key: "private_ip", Value: privateIP})
+		}
+		vars = append(vars,
+			yaml.MapItem{Key: "resource_id", Value: r.State.Primary.ID},
+			yaml.MapItem{Key: "resource_address", Value: r.Address()},
+		)
+		if len(tags) > 0 {
+			tagVars := yaml.MapSlice{}
+			for _, k := range sortedKeys(tags) {
+				tagVars = append(tagVars, yaml.MapItem{Key: k, Value: tags[k]})
+
+				group := ansibleGroupName(fmt.Sprintf("tag_%s_%v", k, tags[k]))
+				groups[group] = append(groups[group], name)
+			}
+			vars = append(vars, yaml.MapItem{Key: "tags", Value: tagVars})
+		}
+
+		hosts = append(hosts, yaml.MapItem{Key: name, Value: vars})
+		groups[r.Key.Type] = append(groups[r.Key.Type], name)
+	}
+
+	groupNames := []string{}
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+
+	children := yaml.MapSlice{}
+	for _, group := range groupNames {
+		members := yaml.MapSlice{}
+		for _, host := range groups[group] {
+			members = append(members, yaml.MapItem{Key: host, Value: yaml.MapSlice{}})
+		}
+		children = append(children, yaml.MapItem{Key: group, Value: yaml.MapSlice{{Key: "hosts", Value: members}}})
+	}
+
+	b, err := yaml.Marshal(yaml.MapSlice{{Key: "all", Value: yaml.MapSlice{
+		{Key: "hosts", Value: hosts},
+		{Key: "children", Value: children},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Returns the value at a path of map keys and list indexes within expanded attributes, or nil.
+func attributeAtPath(v interface{}, path []string) interface{} {
+	for _, part := range path {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			v = value[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil
+			}
+			v = value[i]
+		default:
+			return nil
+		}
+	}
+
+	return v
+}
+
+// Replaces the characters of a group name Ansible doesn't allow with underscores.
+func ansibleGroupName(name string) string {
+	return invalidAnsibleGroupChars.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,70 @@
+package terraconf
+
+// Rendering rules for common gotchas of the aws provider:
+//   - availability_zone of instances is dropped when subnet_id is set, since the subnet determines it.
+//   - associate_public_ip_address of instances is dropped when subnet_id is set, since it defaults to
+//     the map_public_ip_on_launch setting of the subnet.
+//   - The root volume is only rendered as root_block_device, never as an ebs_block_device, and the
+//     computed volume_id of block devices is dropped.
+var AWSProfile = &Profile{
+	Name: "aws",
+	Transformers: map[string][]ResourceTransformer{
+		"aws_instance": {awsInstanceTransformer},
+	},
+}
+
+func awsInstanceTransformer(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+	// The subnet is a reference once resources are linked, see Generator.LinkResources.
+	inSubnet := false
+	switch subnetID := attrs["subnet_id"].(type) {
+	case string:
+		inSubnet = subnetID != ""
+	case InterpolatedString:
+		inSubnet = subnetID != ""
+	}
+	if inSubnet {
+		delete(attrs, "availability_zone")
+		delete(attrs, "associate_public_ip_address")
+	}
+
+	rootDeviceName := ""
+	if rootDevices, ok := attrs["root_block_device"].([]interface{}); ok {
+		for _, item := range rootDevices {
+			device, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if name, ok := device["device_name"].(string); ok {
+				rootDeviceName = name
+			}
+			delete(device, "device_name")
+			delete(device, "volume_id")
+		}
+	}
+
+	if ebsDevices, ok := attrs["ebs_block_device"].([]interface{}); ok {
+		devices := []interface{}{}
+		for _, item := range ebsDevices {
+			device, ok := item.(map[string]interface{})
+			if !ok {
+				devices = append(devices, item)
+				continue
+			}
+
+			if name, _ := device["device_name"].(string); rootDeviceName != "" && name == rootDeviceName {
+				continue
+			}
+			delete(device, "volume_id")
+			devices = append(devices, device)
+		}
+
+		if len(devices) == 0 {
+			delete(attrs, "ebs_block_device")
+		} else {
+			attrs["ebs_block_device"] = devices
+		}
+	}
+
+	return attrs
+}
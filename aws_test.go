@@ -0,0 +1,64 @@
+package terraconf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Instances in a subnet leave the placement attributes implied by the subnet out.
+func TestAWSProfileInstance(t *testing.T) {
+	opts := terraconf.NewOptions()
+	opts.Syntax = terraconf.SyntaxHCL2
+	opts.ApplyProfile(terraconf.AWSProfile)
+	g := terraconf.NewGenerator(opts)
+
+	for _, subnetID := range []string{"subnet-0123", ""} {
+		config, err := g.ResourceConfig(terraconftest.NewResourceState("aws_instance", "i-0123", map[string]interface{}{
+			"availability_zone":           "eu-west-1a",
+			"associate_public_ip_address": "true",
+			"subnet_id":                   subnetID,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if placed := strings.Contains(config, "availability_zone"); placed != (subnetID == "") {
+			t.Errorf("subnet %q: expected availability_zone only without a subnet:\n%s", subnetID, config)
+		}
+	}
+}
+
+// The placement attributes implied by the subnet are dropped after the subnet ID is linked.
+func TestAWSProfileLinkedSubnet(t *testing.T) {
+	state := terraconftest.NewState(map[string]*terraform.ResourceState{
+		"aws_subnet.a": terraconftest.NewResourceState("aws_subnet", "subnet-0123", map[string]interface{}{
+			"availability_zone": "eu-west-1a",
+		}),
+		"aws_instance.web": terraconftest.NewResourceState("aws_instance", "i-0123", map[string]interface{}{
+			"availability_zone":           "eu-west-1a",
+			"associate_public_ip_address": "false",
+			"subnet_id":                   "subnet-0123",
+		}),
+	})
+
+	opts := terraconf.NewOptions()
+	opts.Syntax = terraconf.SyntaxHCL2
+	opts.ApplyProfile(terraconf.AWSProfile)
+
+	files, err := terraconf.GetStateConfigString(state, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "resource \"aws_instance\" \"web\" {\n  subnet_id = aws_subnet.a.id\n}\n"
+	if files["aws_instance.tf"] != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, files["aws_instance.tf"])
+	}
+	if !strings.Contains(files["aws_subnet.tf"], "availability_zone") {
+		t.Errorf("availability_zone of the subnet was dropped:\n%s", files["aws_subnet.tf"])
+	}
+}
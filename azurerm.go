@@ -0,0 +1,112 @@
+package terraconf
+
+import (
+	"strings"
+)
+
+// Segments of azure resource IDs with the casing the azurerm provider uses, by lower case segment.
+var azureIDSegments = map[string]string{
+	"subscriptions":  "subscriptions",
+	"resourcegroups": "resourceGroups",
+	"providers":      "providers",
+}
+
+// Rendering rules for the azurerm provider: resource IDs, which the API returns with inconsistent
+// casing, are normalized to `/subscriptions/.../resourceGroups/.../providers/...`, read-only fields of
+// common resource types are excluded, and identity and sku blocks are rendered as a single block
+// without their computed fields.
+var AzureRMProfile = &Profile{
+	Name: "azurerm",
+	TypeExcludes: map[string]ResourceExcludes{
+		"azurerm_sql_server":         {"fully_qualified_domain_name": struct{}{}},
+		"azurerm_mssql_server":       {"fully_qualified_domain_name": struct{}{}, "restorable_dropped_database_ids": struct{}{}},
+		"azurerm_postgresql_server":  {"fqdn": struct{}{}},
+		"azurerm_mysql_server":       {"fqdn": struct{}{}},
+		"azurerm_public_ip":          {"fqdn": struct{}{}},
+		"azurerm_kubernetes_cluster": {"fqdn": struct{}{}, "kube_admin_config": struct{}{}, "kube_admin_config_raw": struct{}{}, "kube_config": struct{}{}, "kube_config_raw": struct{}{}},
+		"azurerm_network_interface":  {"applied_dns_servers": struct{}{}, "mac_address": struct{}{}, "private_ip_address": struct{}{}, "private_ip_addresses": struct{}{}, "virtual_machine_id": struct{}{}},
+		"azurerm_storage_account":    {"primary_blob_endpoint": struct{}{}, "primary_location": struct{}{}, "secondary_location": struct{}{}},
+		"azurerm_key_vault":          {"vault_uri": struct{}{}},
+		"azurerm_container_registry": {"login_server": struct{}{}},
+		"azurerm_app_service":        {"default_site_hostname": struct{}{}, "outbound_ip_addresses": struct{}{}, "possible_outbound_ip_addresses": struct{}{}},
+		"azurerm_cosmosdb_account":   {"endpoint": struct{}{}, "read_endpoints": struct{}{}, "write_endpoints": struct{}{}},
+	},
+	Transformers: map[string][]ResourceTransformer{
+		AllResourceTypes: {azureRMTransformer},
+	},
+}
+
+func azureRMTransformer(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+	if providerName(resourceType) != "azurerm" {
+		return attrs
+	}
+
+	for attrName, v := range attrs {
+		attrs[attrName] = normalizeAzureIDs(v)
+	}
+
+	if identity, ok := singleAzureBlock(attrs["identity"]); ok {
+		delete(identity, "principal_id")
+		delete(identity, "tenant_id")
+		if ids, ok := identity["identity_ids"].([]interface{}); ok && len(ids) == 0 {
+			delete(identity, "identity_ids")
+		}
+		attrs["identity"] = []interface{}{identity}
+	}
+
+	if sku, ok := singleAzureBlock(attrs["sku"]); ok {
+		for k, v := range sku {
+			if v == "" {
+				delete(sku, k)
+			}
+		}
+		attrs["sku"] = []interface{}{sku}
+	}
+
+	return attrs
+}
+
+// Returns the block a value holds, whether it was stored as a map or a single element list.
+func singleAzureBlock(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, len(v) > 0
+	case []interface{}:
+		if len(v) == 1 {
+			block, ok := v[0].(map[string]interface{})
+			return block, ok
+		}
+	}
+
+	return nil, false
+}
+
+// Normalizes the casing of the azure resource IDs within a value.
+func normalizeAzureIDs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if !strings.HasPrefix(strings.ToLower(v), "/subscriptions/") {
+			return v
+		}
+
+		segments := strings.Split(v, "/")
+		// Segments alternate between keys and names, starting with the empty segment before the
+		// leading slash, so only the keys at odd positions are normalized.
+		for i := 1; i < len(segments); i += 2 {
+			if segment, ok := azureIDSegments[strings.ToLower(segments[i])]; ok {
+				segments[i] = segment
+			}
+		}
+		return strings.Join(segments, "/")
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeAzureIDs(item)
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalizeAzureIDs(item)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,64 @@
+package terraconf
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Attributes stored base64 encoded in state, by resource type, set by NewOptions.
+var Base64EncodedAttributes = map[string][]string{
+	"aws_instance":                    {"user_data_base64"},
+	"aws_launch_configuration":        {"user_data_base64"},
+	"aws_launch_template":             {"user_data"},
+	"azurerm_linux_virtual_machine":   {"custom_data", "user_data"},
+	"azurerm_windows_virtual_machine": {"custom_data", "user_data"},
+}
+
+// Renders a base64 encoded attribute as its decoded text wrapped in `base64encode(...)`, e.g. to
+// review user data scripts in the generated config. The text is a heredoc when it spans multiple
+// lines. Returns an empty string for values that are not base64 encoded text, and for HCL1 which
+// cannot call functions on heredocs.
+func (g *Generator) base64AttributeString(attrName string, attrRawVal interface{}) string {
+	s, ok := attrRawVal.(string)
+	if !ok || s == "" || g.opts.Syntax != SyntaxHCL2 {
+		return ""
+	}
+
+	decoded, ok := decodeBase64Text(s)
+	if !ok {
+		return ""
+	}
+
+	// Heredocs end with a newline, which chomp removes for text that doesn't.
+	if strings.Contains(strings.TrimSuffix(decoded, "\n"), "\n") {
+		if heredoc, ok := g.heredocString(decoded); ok {
+			return fmt.Sprintf("%s = base64encode(%s\n)\n", attrName, heredoc)
+		}
+		if heredoc, ok := g.heredocString(decoded + "\n"); ok {
+			return fmt.Sprintf("%s = base64encode(chomp(%s\n))\n", attrName, heredoc)
+		}
+	}
+
+	return fmt.Sprintf("%s = base64encode(%s)\n", attrName, g.valueString(decoded))
+}
+
+// Decodes a base64 encoded string, returning false unless it decodes to printable UTF-8 text, so
+// binary values, e.g. gzipped cloud-init, keep their encoded form.
+func decodeBase64Text(s string) (string, bool) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 || !utf8.Valid(b) {
+		return "", false
+	}
+
+	text := string(b)
+	for _, r := range text {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return "", false
+		}
+	}
+
+	return text, true
+}
@@ -0,0 +1,91 @@
+package terraconf_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Builds a root module state with n resources, cycling through instances, security groups and buckets
+// with nested blocks, lists and maps, roughly the mix of a typical AWS account.
+func syntheticState(n int) *terraform.State {
+	resources := map[string]*terraform.ResourceState{}
+
+	for i := 0; i < n; i++ {
+		tags := map[string]interface{}{
+			"Name":        fmt.Sprintf("resource-%d", i),
+			"Environment": "production",
+			"Team":        fmt.Sprintf("team-%d", i%10),
+		}
+
+		switch i % 3 {
+		case 0:
+			resources[fmt.Sprintf("aws_instance.web_%d", i)] = terraconftest.NewResourceState("aws_instance", fmt.Sprintf("i-%08x", i), map[string]interface{}{
+				"ami":                    "ami-0c55b159cbfafe1f0",
+				"instance_type":          "t3.micro",
+				"subnet_id":              fmt.Sprintf("subnet-%08x", i%16),
+				"monitoring":             "false",
+				"vpc_security_group_ids": []interface{}{fmt.Sprintf("sg-%08x", i+1)},
+				"root_block_device": []interface{}{
+					map[string]interface{}{"volume_size": "8", "volume_type": "gp2", "delete_on_termination": "true"},
+				},
+				"tags": tags,
+			})
+		case 1:
+			resources[fmt.Sprintf("aws_security_group.web_%d", i)] = terraconftest.NewResourceState("aws_security_group", fmt.Sprintf("sg-%08x", i), map[string]interface{}{
+				"name":        fmt.Sprintf("web-%d", i),
+				"description": "Managed by terraform",
+				"vpc_id":      "vpc-0a1b2c3d",
+				"ingress": []interface{}{
+					map[string]interface{}{"from_port": "443", "to_port": "443", "protocol": "tcp", "cidr_blocks": []interface{}{"0.0.0.0/0"}},
+					map[string]interface{}{"from_port": "22", "to_port": "22", "protocol": "tcp", "cidr_blocks": []interface{}{"10.0.0.0/8", "192.168.0.0/16"}},
+				},
+				"tags": tags,
+			})
+		default:
+			resources[fmt.Sprintf("aws_s3_bucket.logs_%d", i)] = terraconftest.NewResourceState("aws_s3_bucket", fmt.Sprintf("logs-%d", i), map[string]interface{}{
+				"bucket": fmt.Sprintf("logs-%d", i),
+				"acl":    "private",
+				"versioning": []interface{}{
+					map[string]interface{}{"enabled": "true", "mfa_delete": "false"},
+				},
+				"tags": tags,
+			})
+		}
+	}
+
+	return terraconftest.NewState(resources)
+}
+
+// Renders synthetic states into one file per resource type. See the README for the throughput targets
+// these are expected to meet.
+func BenchmarkOutputFiles(b *testing.B) {
+	syntaxes := map[string]terraconf.Syntax{"hcl1": terraconf.SyntaxHCL1, "hcl2": terraconf.SyntaxHCL2}
+
+	for _, n := range []int{1000, 10000, 50000} {
+		state := syntheticState(n)
+
+		for _, name := range []string{"hcl1", "hcl2"} {
+			syntax := syntaxes[name]
+
+			b.Run(fmt.Sprintf("%d/%s", n, name), func(b *testing.B) {
+				opts := terraconf.NewOptions()
+				opts.Syntax = syntax
+
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					if _, err := terraconf.NewGenerator(opts).OutputFiles(state, terraconf.GroupByType()); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "resources/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package terraconf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A set of attribute names.
+type AttributeSet map[string]struct{}
+
+// Attributes that hold numeric looking strings, set as the CoercionDenylist by NewOptions. Maps like
+// tags are listed so their values are left alone altogether.
+var DefaultCoercionDenylist = AttributeSet{
+	"account_id": struct{}{},
+	"owner_id":   struct{}{},
+	"tags":       struct{}{},
+	"labels":     struct{}{},
+}
+
+// Guesses the type of a state string, returning a bool for `true` and `false`, an int64 for integers
+// and a float64 for decimals. Values that would not render back to the same string, e.g. `007`, `1.50`
+// or `1e3`, and integers too long to be quantities, stay strings.
+func CoerceValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	// Longer numbers are much more likely to be IDs than quantities.
+	if len(s) == 0 || len(s) > 15 {
+		return s
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(i, 10) == s {
+		return i
+	}
+
+	if strings.Contains(s, ".") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == s {
+			return f
+		}
+	}
+
+	return s
+}
+
+// Whether the strings of an attribute, or of the nested attribute of a block or map, are coerced.
+// Allowed attributes are always coerced, denied ones and those named like IDs or versions never.
+func (g *Generator) isCoerced(attrName string) bool {
+	if _, ok := g.opts.CoercionAllowlist[attrName]; ok {
+		return true
+	}
+	if _, ok := g.opts.CoercionDenylist[attrName]; ok {
+		return false
+	}
+
+	return attrName != "id" && !strings.HasSuffix(attrName, "_id") && !strings.HasSuffix(attrName, "version")
+}
+
+// Coerces the strings within an attribute value, see CoerceValue.
+func (g *Generator) coerceAttribute(attrName string, v interface{}) interface{} {
+	if !g.isCoerced(attrName) {
+		return v
+	}
+
+	switch v := v.(type) {
+	case string:
+		return CoerceValue(v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = g.coerceAttribute(attrName, item)
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = g.coerceAttribute(k, item)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,213 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// An attribute that renders differently between two equivalent resources. Left or Right is empty
+// when the attribute is only rendered on the other side.
+type AttributeDifference struct {
+	Name  string
+	Left  string
+	Right string
+}
+
+// The result of comparing the resources found at the same address in two states.
+type ResourceComparison struct {
+	Address string
+	Type    string
+
+	// Set when the address only exists in one of the states.
+	OnlyLeft  bool
+	OnlyRight bool
+
+	Differences []*AttributeDifference
+}
+
+// Compares equivalent resources of two states, e.g. the staging and prod workspaces of a stack.
+// Resources are matched by address since their IDs differ between environments, and each side is
+// rendered with the generator's options. Only resources that differ are returned,
+// sorted by address.
+func (g *Generator) CompareStates(left *terraform.State, right *terraform.State) ([]*ResourceComparison, error) {
+	leftResources, err := resourcesByAddress(left)
+	if err != nil {
+		return nil, err
+	}
+
+	rightResources, err := resourcesByAddress(right)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := map[string]bool{}
+	for address := range leftResources {
+		addresses[address] = true
+	}
+	for address := range rightResources {
+		addresses[address] = true
+	}
+
+	sortedAddresses := []string{}
+	for address := range addresses {
+		sortedAddresses = append(sortedAddresses, address)
+	}
+	sort.Strings(sortedAddresses)
+
+	comparisons := []*ResourceComparison{}
+	comparer := g.comparer()
+
+	for _, address := range sortedAddresses {
+		l, inLeft := leftResources[address]
+		r, inRight := rightResources[address]
+
+		switch {
+		case !inRight:
+			comparisons = append(comparisons, &ResourceComparison{Address: address, Type: l.Type, OnlyLeft: true})
+			continue
+		case !inLeft:
+			comparisons = append(comparisons, &ResourceComparison{Address: address, Type: r.Type, OnlyRight: true})
+			continue
+		}
+
+		differences, err := comparer.compareResourceStates(l, r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", address, err)
+		}
+		if len(differences) > 0 {
+			comparisons = append(comparisons, &ResourceComparison{Address: address, Type: l.Type, Differences: differences})
+		}
+	}
+
+	return comparisons, nil
+}
+
+// Returns the attributes that render differently between two resources, sorted by name. Fails when a
+// hook fails for either resource.
+func (g *Generator) CompareResourceStates(left *terraform.ResourceState, right *terraform.ResourceState) ([]*AttributeDifference, error) {
+	return g.comparer().compareResourceStates(left, right)
+}
+
+// Returns a generator with the same options for rendering resources to compare, so the secret
+// variables and include files of compared resources aren't recorded by the generator.
+func (g *Generator) comparer() *Generator {
+	return NewGenerator(g.opts)
+}
+
+func (g *Generator) compareResourceStates(left *terraform.ResourceState, right *terraform.ResourceState) ([]*AttributeDifference, error) {
+	leftResolved, err := g.resolveAttributes(left)
+	if err != nil {
+		return nil, err
+	}
+	rightResolved, err := g.resolveAttributes(right)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both sides are rendered under the same name so stubbed secrets compare equal.
+	leftNames, leftRendered := g.resourceAttributeStrings(left, "", leftResolved)
+	rightNames, rightRendered := g.resourceAttributeStrings(right, "", rightResolved)
+
+	names := map[string]bool{}
+	for _, name := range leftNames {
+		names[name] = true
+	}
+	for _, name := range rightNames {
+		names[name] = true
+	}
+
+	sortedNames := []string{}
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	differences := []*AttributeDifference{}
+	for _, name := range sortedNames {
+		if leftRendered[name] != rightRendered[name] {
+			differences = append(differences, &AttributeDifference{
+				Name:  name,
+				Left:  leftRendered[name],
+				Right: rightRendered[name],
+			})
+		}
+	}
+
+	return differences, nil
+}
+
+// Renders comparisons as a side-by-side report of the differing attributes, e.g.
+//
+//	~ aws_instance.web
+//	    instance_type = "t2.micro" | instance_type = "m5.large"
+func ComparisonReportString(comparisons []*ResourceComparison, leftName string, rightName string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s | %s\n", leftName, rightName)
+
+	for _, comparison := range comparisons {
+		switch {
+		case comparison.OnlyLeft:
+			fmt.Fprintf(&b, "- %s (only in %s)\n", comparison.Address, leftName)
+		case comparison.OnlyRight:
+			fmt.Fprintf(&b, "+ %s (only in %s)\n", comparison.Address, rightName)
+		default:
+			fmt.Fprintf(&b, "~ %s\n", comparison.Address)
+			for _, difference := range comparison.Differences {
+				b.WriteString(sideBySideString(difference.Left, difference.Right, "    "))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// Lays out two rendered values next to each other, padding the left column to its widest line.
+func sideBySideString(left string, right string, indent string) string {
+	leftLines := strings.Split(strings.TrimSuffix(left, "\n"), "\n")
+	rightLines := strings.Split(strings.TrimSuffix(right, "\n"), "\n")
+
+	width := 0
+	for _, line := range leftLines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(leftLines) || i < len(rightLines); i++ {
+		l, r := "", ""
+		if i < len(leftLines) {
+			l = leftLines[i]
+		}
+		if i < len(rightLines) {
+			r = rightLines[i]
+		}
+		b.WriteString(strings.TrimRight(fmt.Sprintf("%s%-*s | %s", indent, width, l, r), " ") + "\n")
+	}
+
+	return b.String()
+}
+
+// Indexes the resources of every module in a state by their full address.
+func resourcesByAddress(state *terraform.State) (map[string]*terraform.ResourceState, error) {
+	resources := map[string]*terraform.ResourceState{}
+	if state == nil {
+		return resources, nil
+	}
+
+	for _, module := range state.Modules {
+		for key, resource := range module.Resources {
+			k, err := ParseResourceKey(key)
+			if err != nil {
+				return nil, err
+			}
+
+			resources[ResourceAddress(module.Path, k)] = resource
+		}
+	}
+
+	return resources, nil
+}
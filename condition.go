@@ -0,0 +1,130 @@
+package terraconf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A default only applied to resources whose attributes satisfy a condition, used as a value of
+// ResourceDefaults, e.g.
+//
+//	ResourceDefaults{"storage_encrypted": MustDefaultWhen(`engine == "postgres"`, true)}
+type ConditionalDefault struct {
+	Condition string
+	Value     interface{}
+
+	clauses []*conditionClause
+}
+
+// A comparison of an attribute with a literal value.
+type conditionClause struct {
+	attribute string
+	negated   bool
+	value     string
+}
+
+// Creates a default applied when the condition holds. Conditions compare top level attributes with
+// literal values using == or !=, joined by &&, e.g. `engine == "postgres" && multi_az != true`. String
+// literals are quoted, other values compare with their state form.
+func DefaultWhen(condition string, value interface{}) (*ConditionalDefault, error) {
+	d := &ConditionalDefault{Condition: condition, Value: value}
+
+	for _, rawClause := range splitOutsideQuotes(condition, "&&") {
+		clause, err := parseConditionClause(strings.TrimSpace(rawClause))
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %s", condition, err)
+		}
+		d.clauses = append(d.clauses, clause)
+	}
+
+	return d, nil
+}
+
+// Like DefaultWhen but panics when the condition doesn't parse, e.g. for defaults declared as globals.
+func MustDefaultWhen(condition string, value interface{}) *ConditionalDefault {
+	d, err := DefaultWhen(condition, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+// Splits s at every separator outside of double quoted string literals, which may contain escaped
+// quotes.
+func splitOutsideQuotes(s string, sep string) []string {
+	parts := []string{}
+	start := 0
+	quoted := false
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			// Skips the escaped character.
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			i += len(sep) - 1
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func parseConditionClause(s string) (*conditionClause, error) {
+	c := &conditionClause{}
+
+	// Operators are only looked for before the literal, which may contain them.
+	lhs := s
+	if q := strings.Index(s, `"`); q >= 0 {
+		lhs = s[:q]
+	}
+
+	op := "=="
+	i := strings.Index(lhs, op)
+	if j := strings.Index(lhs, "!="); j >= 0 && (i < 0 || j < i) {
+		op, i = "!=", j
+		c.negated = true
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("expected == or != in %q", s)
+	}
+
+	c.attribute = strings.TrimSpace(s[:i])
+	if c.attribute == "" {
+		return nil, fmt.Errorf("missing attribute in %q", s)
+	}
+
+	literal := strings.TrimSpace(s[i+len(op):])
+	if strings.HasPrefix(literal, `"`) {
+		unquoted, err := strconv.Unquote(literal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", literal)
+		}
+		literal = unquoted
+	} else if literal == "" {
+		return nil, fmt.Errorf("missing value in %q", s)
+	}
+	c.value = literal
+
+	return c, nil
+}
+
+// Whether the condition holds for the expanded attributes of a resource. Missing and complex
+// attributes compare unequal to every value.
+func (d *ConditionalDefault) matches(attrs map[string]interface{}) bool {
+	for _, c := range d.clauses {
+		v, ok := attrs[c.attribute]
+		equal := ok && IsPrimitive(v) && fmt.Sprintf("%v", v) == c.value
+
+		if equal == c.negated {
+			return false
+		}
+	}
+
+	return true
+}
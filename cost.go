@@ -0,0 +1,98 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Estimates the cost of generated resources, e.g. from a pricing API or the output of a cost tool.
+type PricingProvider interface {
+	// Returns the estimated monthly cost of a resource, or nil when the resource isn't priced.
+	MonthlyCost(r *IRResource) (*CostEstimate, error)
+}
+
+type CostEstimate struct {
+	Monthly float64
+	// ISO 4217 code, e.g. USD.
+	Currency string
+}
+
+// Renders a comment stating the estimated monthly cost of a resource, to give reviewers context on
+// what is being adopted. Returns an empty string when the resource isn't priced.
+func (g *Generator) costComment(ir *IRResource) (string, error) {
+	if g.opts.Pricing == nil {
+		return "", nil
+	}
+
+	estimate, err := g.opts.Pricing.MonthlyCost(ir)
+	if err != nil || estimate == nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("# estimated monthly cost: %.2f %s%s", estimate.Monthly, estimate.Currency, g.newline()), nil
+}
+
+// Prices resources with the costs of an Infracost breakdown, as written by
+// `infracost breakdown --format json` for the generated config. Resources are looked up by their full
+// address in the generated config, e.g. `module.app.aws_instance.web`, and are not priced when the
+// breakdown has no monthly cost for them, like resources that are only charged by usage.
+type InfracostPricing struct {
+	currency string
+	costs    map[string]float64
+}
+
+// The parts of the Infracost JSON output read by InfracostPricing.
+type infracostBreakdown struct {
+	Currency string `json:"currency"`
+	Projects []struct {
+		Breakdown *struct {
+			Resources []struct {
+				Name        string  `json:"name"`
+				MonthlyCost *string `json:"monthlyCost"`
+			} `json:"resources"`
+		} `json:"breakdown"`
+	} `json:"projects"`
+}
+
+// Reads the JSON output of `infracost breakdown`. Costs of resources with the same address in more
+// than one project are added up.
+func ReadInfracostPricing(r io.Reader) (*InfracostPricing, error) {
+	breakdown := &infracostBreakdown{}
+	if err := json.NewDecoder(r).Decode(breakdown); err != nil {
+		return nil, fmt.Errorf("reading infracost breakdown: %s", err)
+	}
+
+	p := &InfracostPricing{currency: breakdown.Currency, costs: map[string]float64{}}
+	for _, project := range breakdown.Projects {
+		if project.Breakdown == nil {
+			continue
+		}
+
+		for _, resource := range project.Breakdown.Resources {
+			if resource.MonthlyCost == nil {
+				continue
+			}
+
+			cost, err := strconv.ParseFloat(*resource.MonthlyCost, 64)
+			if err != nil {
+				return nil, fmt.Errorf("reading infracost breakdown: monthly cost of %s: %s", resource.Name, err)
+			}
+			p.costs[resource.Name] += cost
+		}
+	}
+
+	return p, nil
+}
+
+func (p *InfracostPricing) MonthlyCost(r *IRResource) (*CostEstimate, error) {
+	address := ResourceAddress(r.Module, &ResourceKey{Data: r.Mode == "data", Type: r.Type, Name: r.Name})
+
+	cost, ok := p.costs[address]
+	if !ok {
+		return nil, nil
+	}
+
+	return &CostEstimate{Monthly: cost, Currency: p.currency}, nil
+}
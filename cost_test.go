@@ -0,0 +1,98 @@
+package terraconf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+const infracostBreakdown = `{
+  "currency": "USD",
+  "projects": [
+    {
+      "breakdown": {
+        "resources": [
+          {"name": "aws_instance.web", "monthlyCost": "10.5"},
+          {"name": "module.app.aws_instance.web", "monthlyCost": "20"}
+        ]
+      }
+    }
+  ]
+}`
+
+// Resources are annotated with the monthly cost of the breakdown, unless it doesn't price them.
+func TestInfracostPricing(t *testing.T) {
+	pricing, err := terraconf.ReadInfracostPricing(strings.NewReader(infracostBreakdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := terraconftest.NewState(map[string]*terraform.ResourceState{
+		"aws_instance.web":   terraconftest.NewResourceState("aws_instance", "i-web", map[string]interface{}{"instance_type": "t3.micro"}),
+		"aws_s3_bucket.logs": terraconftest.NewResourceState("aws_s3_bucket", "logs", map[string]interface{}{"bucket": "logs"}),
+	})
+
+	opts := terraconf.NewOptions()
+	opts.Pricing = pricing
+
+	files, err := terraconf.NewGenerator(opts).OutputFiles(state, terraconf.GroupByType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"aws_instance.tf":  "# estimated monthly cost: 10.50 USD\n",
+		"aws_s3_bucket.tf": "resource",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(files))
+	}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Content, expected[f.Path]) {
+			t.Errorf("expected %s to start with %q:\n%s", f.Path, expected[f.Path], f.Content)
+		}
+	}
+}
+
+// Resources of the same name in different modules are priced by their full address.
+func TestInfracostPricingModules(t *testing.T) {
+	pricing, err := terraconf.ReadInfracostPricing(strings.NewReader(infracostBreakdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := &terraform.State{Version: terraform.StateVersion}
+	for _, path := range [][]string{{"root"}, {"root", "app"}, {"root", "db"}} {
+		state.Modules = append(state.Modules, &terraform.ModuleState{
+			Path: path,
+			Resources: map[string]*terraform.ResourceState{
+				"aws_instance.web": terraconftest.NewResourceState("aws_instance", "i-"+path[len(path)-1], map[string]interface{}{"instance_type": "t3.micro"}),
+			},
+		})
+	}
+
+	opts := terraconf.NewOptions()
+	opts.Pricing = pricing
+
+	files, err := terraconf.NewGenerator(opts).OutputFiles(state, terraconf.GroupByModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"root.tf": "# estimated monthly cost: 10.50 USD\n",
+		"app.tf":  "# estimated monthly cost: 20.00 USD\n",
+		"db.tf":   "resource",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(files))
+	}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Content, expected[f.Path]) {
+			t.Errorf("expected %s to start with %q:\n%s", f.Path, expected[f.Path], f.Content)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package terraconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider arguments holding credentials, by provider name. Their values are never taken from the
+// state into provider blocks. With the ProviderCredentialVariables option set, provider blocks
+// reference a variable for each instead.
+var ProviderCredentialArguments = map[string][]string{
+	"aws":        {"access_key", "secret_key", "token"},
+	"azurerm":    {"client_id", "client_secret", "client_certificate_password", "subscription_id", "tenant_id"},
+	"google":     {"credentials", "access_token"},
+	"kubernetes": {"token", "username", "password", "client_certificate", "client_key"},
+}
+
+// Whether an argument of a provider holds credentials.
+func isProviderCredential(provider string, attrName string) bool {
+	for _, credential := range ProviderCredentialArguments[provider] {
+		if credential == attrName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Renders the credential arguments of a provider block as references to variables, e.g.
+// `access_key = var.aws_access_key`, and records the variables along with the stubbed secrets so
+// SecretVariablesString declares them and SecretsExampleString lists them. Returns an empty string
+// unless the ProviderCredentialVariables option is set.
+func (g *Generator) providerCredentialsString(provider string) string {
+	if !g.opts.ProviderCredentialVariables {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, attrName := range ProviderCredentialArguments[provider] {
+		b.WriteString(g.secretVariableString(&SecretVariable{
+			Name:      secretVariableName("", provider, attrName),
+			Provider:  provider,
+			Attribute: attrName,
+		}))
+	}
+
+	return b.String()
+}
+
+// Renders a provider block with the given, unformatted, body and the credentials of the provider.
+func (g *Generator) providerBlockString(provider string, body string) (string, error) {
+	b, err := g.format(fmt.Sprintf("provider %q {\n%s%s}\n", provider, body, g.providerCredentialsString(provider)))
+	if err != nil {
+		return "", fmt.Errorf("formatting %s provider: %s", provider, err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,20 @@
+package terraconf
+
+import (
+	"regexp"
+)
+
+// Matches the replacement named in the description of a deprecated attribute, e.g. "Use the
+// `aws_s3_bucket_acl` resource instead".
+var deprecationReplacement = regexp.MustCompile("(?i)\\buse\\s+(?:the\\s+)?`?([A-Za-z][A-Za-z0-9_.]*)`?(?:\\s+(?:attribute|argument|block|resource))?\\s+instead")
+
+// Marks an attribute the schema deprecates with a comment on the line above, naming the replacement
+// when its description mentions one, e.g. `# DEPRECATED: use aws_s3_bucket_acl`.
+func deprecatedAttributeString(rendered string, attr *SchemaAttribute) string {
+	comment := "# DEPRECATED"
+	if m := deprecationReplacement.FindStringSubmatch(attr.Description); m != nil {
+		comment += ": use " + m[1]
+	}
+
+	return comment + "\n" + rendered
+}
@@ -0,0 +1,121 @@
+package terraconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// How a state file is encrypted, as detected by DetectEncryption.
+type Encryption int
+
+const (
+	EncryptionNone Encryption = iota
+	// Encrypted by sops, with the JSON structure kept and the values encrypted.
+	EncryptionSOPS
+	// Encrypted by age as a whole, in binary or armored form.
+	EncryptionAge
+)
+
+const (
+	// Environment variable naming the age identity file, shared with sops. Defaults to the file sops
+	// reads, `sops/age/keys.txt` in the user config directory.
+	AgeKeyFileEnv = "SOPS_AGE_KEY_FILE"
+
+	ageHeader        = "age-encryption.org/v1\n"
+	ageArmoredHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+)
+
+// Tells whether a state file is encrypted by sops or age by its header.
+func DetectEncryption(src []byte) Encryption {
+	trimmed := bytes.TrimSpace(src)
+	if bytes.HasPrefix(src, []byte(ageHeader)) || bytes.HasPrefix(trimmed, []byte(ageArmoredHeader)) {
+		return EncryptionAge
+	}
+
+	var header struct {
+		SOPS *struct {
+			MAC string `json:"mac"`
+		} `json:"sops"`
+	}
+	if json.Unmarshal(trimmed, &header) == nil && header.SOPS != nil && header.SOPS.MAC != "" {
+		return EncryptionSOPS
+	}
+
+	return EncryptionNone
+}
+
+// Decrypts a state file encrypted by sops or age by running the `sops` or `age` command, which have
+// to be installed. sops finds its keys on its own, e.g. in KMS, an age key file or the gpg agent; age
+// uses the identity file named by AgeKeyFileEnv. Plaintext is never written to disk. Unencrypted files
+// are returned as they are.
+func DecryptState(ctx context.Context, src []byte) ([]byte, error) {
+	switch DetectEncryption(src) {
+	case EncryptionSOPS:
+		return decryptSOPS(ctx, src)
+	case EncryptionAge:
+		keyFile, err := ageKeyFile()
+		if err != nil {
+			return nil, err
+		}
+		return runDecryption(ctx, src, "age", "--decrypt", "--identity", keyFile)
+	}
+
+	return src, nil
+}
+
+// sops only reads files, so the encrypted state is written to a temporary one.
+func decryptSOPS(ctx context.Context, src []byte) ([]byte, error) {
+	f, err := ioutil.TempFile("", "terraconf-state-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(src); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	return runDecryption(ctx, nil, "sops", "--decrypt", "--input-type", "json", "--output-type", "json", f.Name())
+}
+
+func ageKeyFile() (string, error) {
+	if path := os.Getenv(AgeKeyFileEnv); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding age identity file, set %s: %s", AgeKeyFileEnv, err)
+	}
+
+	return filepath.Join(dir, "sops", "age", "keys.txt"), nil
+}
+
+// Runs a decryption command with stdin, returning its output.
+func runDecryption(ctx context.Context, stdin []byte, command string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("decrypting state with %s: %s: %s", command, err, msg)
+		}
+		return nil, fmt.Errorf("decrypting state with %s: %s", command, err)
+	}
+
+	return stdout.Bytes(), nil
+}
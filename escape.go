@@ -0,0 +1,86 @@
+package terraconf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var hclIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Quotes a string as an HCL string literal. Only the escape sequences HCL understands are used, with
+// other control characters written as \uNNNN. Template sequences are kept, see escapeTemplates.
+func quoteHCLString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				b.WriteString(fmt.Sprintf(`\u%04x`, r))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// Renders a map key, quoting keys that are not valid identifiers, e.g. `kubernetes.io/role` or
+// `Cost Center`, which would otherwise produce invalid HCL.
+func hclKeyString(k string) string {
+	if hclIdentifier.MatchString(k) {
+		return k
+	}
+
+	return quoteHCLString(k)
+}
+
+// Escapes interpolation sequences as `$${`, and directive sequences as `%%{` for HCL2 which is the only
+// syntax that has them, so state values are reproduced literally.
+func (g *Generator) escapeTemplates(s string) string {
+	s = strings.Replace(s, "${", "$${", -1)
+	if g.opts.Syntax == SyntaxHCL2 {
+		s = strings.Replace(s, "%{", "%%{", -1)
+	}
+
+	return s
+}
+
+// Renders a multi-line string as a heredoc, e.g. for YAML documents, returning false for strings that
+// cannot be reproduced exactly since heredocs always end with a newline. Template sequences are
+// escaped, see escapeTemplates.
+func (g *Generator) heredocString(s string) (string, bool) {
+	if !strings.HasSuffix(s, "\n") || strings.ContainsAny(s, "\r") {
+		return "", false
+	}
+
+	s = g.escapeTemplates(s)
+
+	// The delimiter must not appear as a line of the string.
+	lines := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+
+	delimiter := "EOF"
+	for i := 1; lines[delimiter]; i++ {
+		delimiter = fmt.Sprintf("EOF%d", i)
+	}
+
+	return "<<" + delimiter + "\n" + s + delimiter, true
+}
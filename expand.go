@@ -0,0 +1,93 @@
+package terraconf
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/flatmap"
+)
+
+// Expands a flatmapped value like flatmap.Expand, except that the keys of maps may contain the
+// delimiter, e.g. `tags.kubernetes.io/cluster/main`. flatmap.Expand splits such keys into nested maps,
+// so maps with a `%` count are expanded here, taking everything after the map's prefix as the key as
+// long as the number of keys matches the count. Lists are expanded here too so maps within them are
+// handled the same way.
+func expandFlatValue(m map[string]string, key string) interface{} {
+	if _, ok := m[key]; ok {
+		return flatmap.Expand(m, key)
+	}
+
+	if count, ok := m[key+".#"]; ok {
+		if n, err := strconv.Atoi(count); err == nil {
+			return expandFlatList(m, key, n)
+		}
+	}
+
+	if count, ok := m[key+".%"]; ok {
+		if n, err := strconv.Atoi(count); err == nil {
+			if v, ok := expandFlatMap(m, key, n); ok {
+				return v
+			}
+		}
+	}
+
+	return flatmap.Expand(m, key)
+}
+
+// Expands the elements of a list or set, in the numeric order of their indexes or set hashes.
+func expandFlatList(m map[string]string, key string, n int) []interface{} {
+	if n == 0 {
+		return []interface{}{}
+	}
+
+	// Keys are split by element so each element only scans its own keys.
+	prefix := key + tfStateKeyDelimiter
+	elements := map[int]map[string]string{}
+	for k, v := range m {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		segment := strings.SplitN(k[len(prefix):], tfStateKeyDelimiter, 2)[0]
+		i, err := strconv.Atoi(segment)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := elements[i]; !ok {
+			elements[i] = map[string]string{}
+		}
+		elements[i][k] = v
+	}
+
+	sortedIndexes := []int{}
+	for i := range elements {
+		sortedIndexes = append(sortedIndexes, i)
+	}
+	sort.Ints(sortedIndexes)
+
+	list := []interface{}{}
+	for _, i := range sortedIndexes {
+		list = append(list, expandFlatValue(elements[i], prefix+strconv.Itoa(i)))
+	}
+
+	return list
+}
+
+// Expands a map of primitives, returning false when the keys don't add up to the count, e.g. for a map
+// holding nested values, which is left to flatmap.Expand.
+func expandFlatMap(m map[string]string, key string, n int) (map[string]interface{}, bool) {
+	prefix := key + tfStateKeyDelimiter
+	result := map[string]interface{}{}
+
+	for k := range m {
+		if !strings.HasPrefix(k, prefix) || k == prefix+"%" {
+			continue
+		}
+
+		result[k[len(prefix):]] = flatmap.Expand(m, k)
+	}
+
+	return result, len(result) == n
+}
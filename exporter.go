@@ -0,0 +1,162 @@
+package terraconf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Writes resources in an output format, e.g. HCL config or manifests for another tool. Register
+// exporters with RegisterExporter so they can be selected by name.
+type Exporter interface {
+	Name() string
+	Export(resources []*ResourceInstance, w io.Writer) error
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]Exporter{
+		"ansible": NewAnsibleExporter(nil),
+		"hcl":     NewHCLExporter(nil),
+		"pulumi":  NewPulumiExporter(nil),
+	}
+)
+
+// Makes an exporter available by its name. Panics if an exporter with the same name is already
+// registered, like the built-in "ansible", "hcl" and "pulumi"
+// exporters.
+func RegisterExporter(e Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+
+	if _, ok := exporters[e.Name()]; ok {
+		panic(fmt.Sprintf("terraconf: exporter %q registered twice", e.Name()))
+	}
+
+	exporters[e.Name()] = e
+}
+
+func LookupExporter(name string) (Exporter, bool) {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+
+	e, ok := exporters[name]
+	return e, ok
+}
+
+// Returns the names of the registered exporters, sorted.
+func ExporterNames() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+
+	names := []string{}
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Exports resources as config blocks rendered by a generator, each named after its state key with the
+// remap rules of the options applied.
+type HCLExporter struct {
+	generator *Generator
+}
+
+// Creates an exporter rendering with the given generator, or one with the default options when nil.
+func NewHCLExporter(g *Generator) *HCLExporter {
+	if g == nil {
+		g = NewGenerator(nil)
+	}
+
+	return &HCLExporter{generator: g}
+}
+
+func (e *HCLExporter) Name() string {
+	return "hcl"
+}
+
+// Writes a block for every resource. Resources that cannot be generated are skipped and returned as
+// GenerationFailures once the others have been written.
+func (e *HCLExporter) Export(resources []*ResourceInstance, w io.Writer) error {
+	g := e.generator
+	written := 0
+
+	return g.renderBlocks(resources, g.newProgress(len(resources)), func(_ []*ResourceInstance, block string) error {
+		if written > 0 {
+			block = g.blockSeparator() + block
+		}
+		written++
+
+		_, err := io.WriteString(w, block)
+		return err
+	})
+}
+
+// Renders the block of every resource in order, see instanceBlock, and passes it to fn along with the
+// resources it holds, which are several for instances collapsed into a block with count, see
+// Options.CollapseCounts. Resources must be sorted by address, e.g. by StateResources. Resources that
+// cannot be generated are skipped and returned as GenerationFailures once all others have been passed
+// to fn. Errors returned by fn stop rendering. Every resource is reported to progress, which may be
+// nil.
+func (g *Generator) renderBlocks(resources []*ResourceInstance, progress *ProgressCounter, fn func(resources []*ResourceInstance, block string) error) error {
+	failures := GenerationFailures{}
+
+	for _, unit := range g.renderUnits(resources) {
+		if unit.counted() {
+			block, err := g.countBlock(unit)
+			for _, r := range unit.instances {
+				if err != nil {
+					failures.Add(r.Address(), r.Key.Type, err)
+				}
+			}
+			if err == nil {
+				if err := fn(unit.instances, block); err != nil {
+					return err
+				}
+			}
+			for _, r := range unit.instances {
+				progress.Step(r.Address(), err)
+			}
+			continue
+		}
+
+		r := unit.instances[0]
+		if r.State.Primary == nil {
+			err := fmt.Errorf("%s resource has no primary instance", r.Key.Type)
+			failures.Add(r.Address(), r.Key.Type, err)
+			progress.Step(r.Address(), err)
+			continue
+		}
+
+		// Instances compared for collapsing are resolved already, so hooks don't run twice.
+		var block string
+		var err error
+		switch {
+		case len(unit.errs) > 0 && unit.errs[0] != nil:
+			err = unit.errs[0]
+		case len(unit.resolved) > 0:
+			block, err = g.resolvedInstanceBlock(r, unit.resolved[0])
+		default:
+			block, err = g.instanceBlock(r)
+		}
+		if err != nil {
+			failures.Add(r.Address(), r.Key.Type, err)
+			progress.Step(r.Address(), err)
+			continue
+		}
+
+		if err := fn(unit.instances, block); err != nil {
+			return err
+		}
+		progress.Step(r.Address(), nil)
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+)
+
+// A resource that could not be generated, e.g. because of unparseable attributes or a format error.
+type GenerationFailure struct {
+	Address string
+	Type    string
+	Err     error
+}
+
+// Tracks per-resource failures during a generation run, so the resources that did generate can still
+// be written and the failures reported at the end.
+type GenerationFailures []*GenerationFailure
+
+func (f *GenerationFailures) Add(address string, resourceType string, err error) {
+	*f = append(*f, &GenerationFailure{Address: address, Type: resourceType, Err: err})
+}
+
+func (f GenerationFailures) Error() string {
+	if len(f) == 1 {
+		return fmt.Sprintf("%s: %s", f[0].Address, f[0].Err)
+	}
+
+	return fmt.Sprintf("%d resources could not be generated", len(f))
+}
+
+// Renders the failures as a table with one row per skipped resource.
+func (f GenerationFailures) SummaryString() string {
+	if len(f) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ADDRESS\tTYPE\tERROR")
+	for _, failure := range f {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", failure.Address, failure.Type, failure.Err)
+	}
+	w.Flush()
+
+	return fmt.Sprintf("%d resources skipped:\n%s", len(f), b.String())
+}
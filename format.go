@@ -0,0 +1,153 @@
+package terraconf
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Formatting applied on top of the printers, for style checks that reject their output. The zero value
+// keeps the output of the printers.
+type FormatOptions struct {
+	// Spaces per indentation level, or 0 for the printers' two.
+	IndentWidth int
+
+	// Separate attribute names from their equals sign by a single space instead of aligning the equals
+	// signs of consecutive attributes.
+	NoAlignEquals bool
+
+	// Blank lines between top level blocks, or 0 for one.
+	BlankLinesBetweenBlocks int
+
+	// Remove the trailing comma after the last element of lists spanning multiple lines.
+	NoTrailingCommas bool
+}
+
+var (
+	alignedEquals      = regexp.MustCompile(`^(\s*(?:"(?:[^"\\]|\\.)*"|[^\s"=]+))\s+= `)
+	heredocStart       = regexp.MustCompile(`<<-?([A-Za-z_][A-Za-z0-9_]*)\s*$`)
+	listClosingBracket = regexp.MustCompile(`^\s*\]`)
+)
+
+// Stands in for `%{` while HCL1 config is laid out by hclwrite, which reads it as the start of a
+// template directive and would change the spacing inside it, although it is literal text in HCL1.
+const hcl1DirectivePlaceholder = "%\uE000{"
+
+// Lays out HCL1 config like `terraform fmt` lays out HCL2, e.g. without aligning trailing comments as
+// the HCL1 printer does, so the output is canonical for both.
+func hcl1CanonicalLayout(src []byte) []byte {
+	src = bytes.Replace(src, []byte("%{"), []byte(hcl1DirectivePlaceholder), -1)
+
+	return bytes.Replace(hclwrite.Format(src), []byte(hcl1DirectivePlaceholder), []byte("%{"), -1)
+}
+
+// Formats HCL1 config with the HCL1 printer in the canonical layout, see hcl1CanonicalLayout.
+func formatHCL1(src []byte) ([]byte, error) {
+	b, err := printer.Format(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return hcl1CanonicalLayout(b), nil
+}
+
+// Returns the separator between blocks formatted one by one, e.g. the resources of an exporter.
+func (g *Generator) blockSeparator() string {
+	n := g.opts.Format.BlankLinesBetweenBlocks
+	if n <= 0 {
+		n = 1
+	}
+
+	return strings.Repeat(g.newline(), n)
+}
+
+// Applies the format options to printer output with LF line endings. Heredoc contents are left
+// untouched.
+func (f *FormatOptions) apply(s string) string {
+	if *f == (FormatOptions{}) {
+		return s
+	}
+
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+
+	heredocDelimiter := ""
+	pendingBlankLines := 0
+
+	for i, line := range lines {
+		if heredocDelimiter != "" {
+			out = append(out, line)
+			if strings.TrimSpace(line) == heredocDelimiter {
+				heredocDelimiter = ""
+			}
+			continue
+		}
+
+		// Blank lines are only written once the next line shows whether they follow a top level block.
+		if strings.TrimSpace(line) == "" && i < len(lines)-1 {
+			pendingBlankLines++
+			continue
+		}
+		if pendingBlankLines > 0 {
+			if f.BlankLinesBetweenBlocks > 0 && len(out) > 0 && out[len(out)-1] == "}" {
+				pendingBlankLines = f.BlankLinesBetweenBlocks
+			}
+			for ; pendingBlankLines > 0; pendingBlankLines-- {
+				out = append(out, "")
+			}
+		}
+
+		if f.IndentWidth > 0 {
+			trimmed := strings.TrimLeft(line, " ")
+			levels := (len(line) - len(trimmed)) / 2
+			line = strings.Repeat(" ", levels*f.IndentWidth) + trimmed
+		}
+
+		if f.NoAlignEquals {
+			line = alignedEquals.ReplaceAllString(line, "$1 = ")
+		}
+
+		if f.NoTrailingCommas && listClosingBracket.MatchString(line) && len(out) > 0 {
+			out[len(out)-1] = strings.TrimSuffix(out[len(out)-1], ",")
+		}
+
+		if m := heredocStart.FindStringSubmatch(line); m != nil {
+			heredocDelimiter = m[1]
+		}
+
+		out = append(out, line)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// Returns the paths of the .tf files among files that `terraform fmt` would rewrite, in the order given,
+// e.g. to check generated or hand-edited config in CI. Files are checked against the layout of hclwrite,
+// the formatter of `terraform fmt`, for both syntaxes, with `%{` kept literal for HCL1. Generated files
+// pass unless format options are set, which deviate from `terraform fmt` by design.
+func (g *Generator) CheckFormat(files []*OutputFile) ([]string, error) {
+	unformatted := []string{}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path, ".tf") {
+			continue
+		}
+
+		// terraform fmt keeps line endings, so they are left out of the comparison.
+		content := strings.Replace(f.Content, "\r\n", "\n", -1)
+
+		formatted := hclwrite.Format([]byte(content))
+		if g.opts.Syntax != SyntaxHCL2 {
+			formatted = hcl1CanonicalLayout([]byte(content))
+		}
+
+		if string(formatted) != content {
+			unformatted = append(unformatted, f.Path)
+		}
+	}
+
+	return unformatted, nil
+}
@@ -0,0 +1,151 @@
+package terraconf_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Builds a state exercising the layouts the writers produce: trailing comments, commented attributes,
+// heredocs, counted resources, lists, nested blocks and stubbed secrets.
+func formattingState() *terraform.State {
+	resources := map[string]*terraform.ResourceState{
+		"aws_instance.web": terraconftest.NewResourceState("aws_instance", "i-0123456789", map[string]interface{}{
+			"ami":                    "ami-0c55b159cbfafe1f0",
+			"instance_type":          "t3.micro",
+			"user_data_base64":       "IyEvYmluL3NoCmVjaG8gIiR7SE9NRX0iCg==",
+			"vpc_security_group_ids": []interface{}{"sg-0000000b", "sg-0000000a"},
+			"root_block_device": []interface{}{
+				map[string]interface{}{"volume_size": "8", "volume_type": "gp2"},
+			},
+			"tags": map[string]interface{}{"Name": "web", "Cost Center": "100"},
+		}),
+		"kubernetes_config_map.app": terraconftest.NewResourceState("kubernetes_config_map", "default/app", map[string]interface{}{
+			"data": map[string]interface{}{"config.yaml": "listen: 8080\nlog: debug\n"},
+		}),
+		"aws_db_instance.main": terraconftest.NewResourceState("aws_db_instance", "main", map[string]interface{}{
+			"engine":   "aurora-postgresql",
+			"password": "hunter2",
+			"username": "admin",
+		}),
+	}
+	for i := 0; i < 3; i++ {
+		resources[fmt.Sprintf("aws_eip.nat.%d", i)] = terraconftest.NewResourceState("aws_eip", "eipalloc-0123", map[string]interface{}{"vpc": "true"})
+	}
+
+	return terraconftest.NewState(resources)
+}
+
+// Writers emit config `terraform fmt` leaves alone, whichever syntax they render.
+func TestOutputFilesFormat(t *testing.T) {
+	for name, syntax := range map[string]terraconf.Syntax{"hcl1": terraconf.SyntaxHCL1, "hcl2": terraconf.SyntaxHCL2} {
+		t.Run(name, func(t *testing.T) {
+			opts := terraconf.NewOptions()
+			opts.Syntax = syntax
+			opts.StubSecrets = true
+			opts.Secrets["password"] = struct{}{}
+			opts.AnnotateDefaults = true
+			opts.TypeDefaults["aws_instance"] = terraconf.ResourceDefaults{"monitoring": false, "ebs_optimized": false}
+			opts.TypeDefaults["aws_db_instance"] = terraconf.ResourceDefaults{"apply_immediately": false}
+			opts.Rules["ami"] = terraconf.AttributeRule{Action: terraconf.AttributeActionComment, Note: "pinned"}
+
+			g := terraconf.NewGenerator(opts)
+			files, err := g.OutputFiles(formattingState(), terraconf.GroupByType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files = append(files,
+				&terraconf.OutputFile{Path: "variables.tf", Content: g.SecretVariablesString(g.SecretVariables())},
+				&terraconf.OutputFile{Path: "locals.tf", Content: g.ExtractLocals(mapHeavyState(), 2).LocalsString()},
+			)
+
+			for _, f := range files {
+				if f.Content == "" {
+					t.Errorf("%s is empty", f.Path)
+				}
+				if formatted := string(hclwrite.Format([]byte(f.Content))); formatted != f.Content {
+					t.Errorf("%s is not laid out like terraform fmt:\n--- generated\n%s\n--- formatted\n%s", f.Path, f.Content, formatted)
+				}
+			}
+
+			unformatted, err := g.CheckFormat(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(unformatted) != 0 {
+				t.Errorf("expected every file to pass, got %v", unformatted)
+			}
+		})
+	}
+}
+
+// The HCL1 writer keeps `%{` literal, as HCL1 has no template directives.
+func TestFormatHCL1Directive(t *testing.T) {
+	state := terraconftest.NewState(map[string]*terraform.ResourceState{
+		"aws_eip.nat": terraconftest.NewResourceState("aws_eip", "eipalloc-0123", map[string]interface{}{
+			"tags": map[string]interface{}{"Share": "100%{x+y}"},
+		}),
+	})
+
+	config, err := terraconf.NewGenerator(terraconf.NewOptions()).OutputFiles(state, terraconf.GroupByType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "resource \"aws_eip\" \"nat\" {\n  tags {\n    Share = \"100%{x+y}\"\n  }\n}\n"
+	if len(config) != 1 || config[0].Content != expected {
+		t.Errorf("expected:\n%s\ngot:\n%v", expected, config)
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	files := []*terraconf.OutputFile{
+		{Path: "formatted.tf", Content: "resource \"aws_eip\" \"nat\" {\n  vpc = true\n}\n"},
+		{Path: "equals.tf", Content: "resource \"aws_eip\" \"nat\" {\n  vpc=true\n}\n"},
+		// The trailing comments the HCL1 printer aligns.
+		{Path: "comments.tf", Content: "resource \"aws_eip\" \"nat\" {\n  a   = \"1\"              # default\n  bcd = \"a longer value\"\n}\n"},
+		{Path: "list.tf", Content: "resource \"aws_eip\" \"nat\" {\n  ids = [\"a\",\"b\"]\n}\n"},
+		{Path: "README.md", Content: "a=b\n"},
+	}
+	expected := []string{"equals.tf", "comments.tf", "list.tf"}
+
+	for name, syntax := range map[string]terraconf.Syntax{"hcl1": terraconf.SyntaxHCL1, "hcl2": terraconf.SyntaxHCL2} {
+		opts := terraconf.NewOptions()
+		opts.Syntax = syntax
+
+		unformatted, err := terraconf.NewGenerator(opts).CheckFormat(files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(unformatted, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, unformatted)
+		}
+	}
+
+	// `%{` is literal text in HCL1, so the spacing after it is kept, while HCL2 reads a directive.
+	directive := []*terraconf.OutputFile{{Path: "main.tf", Content: "resource \"aws_eip\" \"nat\" {\n  a = \"100%{x+y}\"\n}\n"}}
+
+	hcl1, err := terraconf.NewGenerator(terraconf.NewOptions()).CheckFormat(directive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hcl1) != 0 {
+		t.Errorf("hcl1: expected no files, got %v", hcl1)
+	}
+
+	opts := terraconf.NewOptions()
+	opts.Syntax = terraconf.SyntaxHCL2
+	hcl2, err := terraconf.NewGenerator(opts).CheckFormat(directive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hcl2, []string{"main.tf"}) {
+		t.Errorf("hcl2: expected main.tf, got %v", hcl2)
+	}
+}
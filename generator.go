@@ -0,0 +1,491 @@
+package terraconf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Generates config from state with a fixed set of options. The configuration for each resource type
+// is compiled from the options once and reused for every resource of the type. A generator is safe
+// for concurrent use by multiple goroutines, provided transformers are.
+type Generator struct {
+	opts *Options
+
+	typesMu sync.Mutex
+	types   map[string]*typeConfig
+
+	secretsMu sync.Mutex
+	secrets   map[string]*SecretVariable
+
+	// Names allocated to resources by type.
+	namesMu sync.Mutex
+	names   map[string]*allocatedNames
+
+	// Include files by path.
+	includesMu sync.Mutex
+	includes   map[string]*IncludeFile
+
+	violationsMu sync.Mutex
+	violations   []*PolicyViolation
+}
+
+// The options that apply to a single resource type, merged once per generator.
+type typeConfig struct {
+	defaults      ResourceDefaults
+	excludes      ResourceExcludes
+	rules         ResourceRules
+	secrets       ResourceSecrets
+	valueExcludes map[string][]*regexp.Regexp
+	manifests     map[string]bool
+	base64        map[string]bool
+	transformers  []ResourceTransformer
+	preHooks      []*ExecHook
+	postHooks     []*ExecHook
+	schemaBlock   *SchemaBlock
+
+	order          *AttributeOrder
+	firstPositions map[string]int
+	lastPositions  map[string]int
+	blockSortKeys  BlockSortKeys
+}
+
+// Creates a generator with a copy of the given options, or the defaults of NewOptions when nil, so
+// modifying the options afterwards doesn't affect the generator.
+func NewGenerator(opts *Options) *Generator {
+	if opts == nil {
+		opts = NewOptions()
+	} else {
+		opts = opts.Clone()
+	}
+
+	return &Generator{
+		opts:     opts,
+		types:    map[string]*typeConfig{},
+		secrets:  map[string]*SecretVariable{},
+		names:    map[string]*allocatedNames{},
+		includes: map[string]*IncludeFile{},
+	}
+}
+
+func (g *Generator) typeConfig(resourceType string) *typeConfig {
+	g.typesMu.Lock()
+	defer g.typesMu.Unlock()
+
+	if tc, ok := g.types[resourceType]; ok {
+		return tc
+	}
+
+	tc := &typeConfig{
+		defaults: ResourceDefaults{},
+		// The id attribute should always be excluded.
+		excludes:      ResourceExcludes{"id": struct{}{}},
+		rules:         ResourceRules{},
+		secrets:       ResourceSecrets{},
+		valueExcludes: map[string][]*regexp.Regexp{},
+		manifests:     map[string]bool{},
+		base64:        map[string]bool{},
+	}
+
+	// Type specific defaults and rules take precedence.
+	for k, v := range g.opts.Defaults {
+		tc.defaults[k] = v
+	}
+	for k, v := range g.opts.TypeDefaults[resourceType] {
+		tc.defaults[k] = v
+	}
+
+	for k := range g.opts.Excludes {
+		tc.excludes[k] = struct{}{}
+	}
+	for k := range g.opts.TypeExcludes[resourceType] {
+		tc.excludes[k] = struct{}{}
+	}
+
+	for k, v := range g.opts.Rules {
+		tc.rules[k] = v
+	}
+	for k, v := range g.opts.TypeRules[resourceType] {
+		tc.rules[k] = v
+	}
+
+	for k := range g.opts.Secrets {
+		tc.secrets[k] = struct{}{}
+	}
+	for k := range g.opts.TypeSecrets[resourceType] {
+		tc.secrets[k] = struct{}{}
+	}
+
+	for _, exclude := range g.opts.ValueExcludes {
+		tc.valueExcludes[exclude.Attribute] = append(tc.valueExcludes[exclude.Attribute], exclude.Pattern)
+	}
+	for _, exclude := range g.opts.TypeValueExcludes[resourceType] {
+		tc.valueExcludes[exclude.Attribute] = append(tc.valueExcludes[exclude.Attribute], exclude.Pattern)
+	}
+
+	for _, attrName := range g.opts.ManifestAttributes[resourceType] {
+		tc.manifests[attrName] = true
+	}
+
+	for _, attrName := range g.opts.Base64Attributes[AllResourceTypes] {
+		tc.base64[attrName] = true
+	}
+	for _, attrName := range g.opts.Base64Attributes[resourceType] {
+		tc.base64[attrName] = true
+	}
+
+	tc.transformers = append(tc.transformers, g.opts.Transformers[AllResourceTypes]...)
+	if resourceType != AllResourceTypes {
+		tc.transformers = append(tc.transformers, g.opts.Transformers[resourceType]...)
+	}
+
+	for _, hook := range g.opts.Hooks {
+		if hook.ResourceType != AllResourceTypes && hook.ResourceType != resourceType {
+			continue
+		}
+		if hook.Stage == HookPre {
+			tc.preHooks = append(tc.preHooks, hook)
+		} else {
+			tc.postHooks = append(tc.postHooks, hook)
+		}
+	}
+
+	if schema := g.opts.Schemas.ResourceSchema(resourceType); schema != nil {
+		tc.schemaBlock = schema.Block
+
+		if schema.Block != nil {
+			for k, attr := range schema.Block.Attributes {
+				if attr.Sensitive {
+					tc.secrets[k] = struct{}{}
+				}
+			}
+		}
+	}
+
+	order, ok := g.opts.AttributeOrders[resourceType]
+	if !ok {
+		order = g.opts.AttributeOrders[AllResourceTypes]
+	}
+	if order != nil {
+		tc.order = order
+		tc.firstPositions = attributePositions(order.First)
+		tc.lastPositions = attributePositions(order.Last)
+	}
+
+	tc.blockSortKeys = BlockSortKeys{}
+	for blockName, keys := range g.opts.BlockSortKeys[AllResourceTypes] {
+		tc.blockSortKeys[blockName] = keys
+	}
+	for blockName, keys := range g.opts.BlockSortKeys[resourceType] {
+		tc.blockSortKeys[blockName] = keys
+	}
+
+	g.types[resourceType] = tc
+
+	return tc
+}
+
+// Whether an exclude by value matches the value of an attribute. Only primitive values are matched.
+func (tc *typeConfig) isValueExcluded(attrName string, v interface{}) bool {
+	if !IsPrimitive(v) {
+		return false
+	}
+
+	s := fmt.Sprintf("%v", v)
+	for _, pattern := range tc.valueExcludes[attrName] {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Renders a resource block for a resource state, named after its sanitized ID, or reports why the
+// resource could not be generated. IDs sanitizing to the name of a resource generated before get a
+// suffix, see ResourceNames.
+func (g *Generator) ResourceConfig(state *terraform.ResourceState) (string, error) {
+	if state.Primary == nil {
+		return "", fmt.Errorf("%s resource has no primary instance", state.Type)
+	}
+
+	// Note: The ID field for an individual resource state may not be safe and may contain periods,
+	// slashes or colons. At this point we do not have the safe ID anymore and must sanitize it. The
+	// only place the safe ID exists is in the full state file as the keys of modules[].resources.
+	resolved, err := g.resolveAttributes(state)
+	if err != nil {
+		return "", err
+	}
+	if err := g.checkValueTypes(resolved); err != nil {
+		return "", err
+	}
+
+	name := g.allocateResourceName(state.Type, state.Primary.ID)
+	if t := g.opts.ResourceTemplates[state.Type]; t != nil {
+		return g.templateBlock(t, g.stateIR(state, state.Type+tfStateKeyDelimiter+name, "resource", name, resolved))
+	}
+
+	return g.resourceBlock("resource", state.Type, name, g.resourceBody(state, name, resolved))
+}
+
+// Reports attributes holding values that cannot be rendered when the StrictTypes option is set.
+func (g *Generator) checkValueTypes(resolved []*resolvedAttribute) error {
+	if !g.opts.StrictTypes {
+		return nil
+	}
+
+	for _, attr := range resolved {
+		if attr.source == attributeSecret {
+			continue
+		}
+		if err := checkValueType(attr.name, attr.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Renders the block of a resource instance named after its state key with the remap rules applied,
+// checked against the Rego policy of the options and preceded by its source annotation and estimated
+// cost when enabled. Attributes are resolved once, so hooks run once per resource.
+func (g *Generator) instanceBlock(r *ResourceInstance) (string, error) {
+	resolved, err := g.resolveAttributes(r.State)
+	if err != nil {
+		return "", err
+	}
+
+	return g.resolvedInstanceBlock(r, resolved)
+}
+
+// Renders the block of a resource instance like instanceBlock from its resolved attributes.
+func (g *Generator) resolvedInstanceBlock(r *ResourceInstance, resolved []*resolvedAttribute) (string, error) {
+	ir := g.resourceIR(r, resolved)
+
+	policyComments, err := g.checkPolicy(r, ir)
+	if err != nil {
+		return "", err
+	}
+
+	comments := ""
+	if g.opts.AnnotateSource {
+		comments = g.convertNewlines(ResourceAnnotationString(r.Address(), r.State, g.opts.GeneratedAt))
+	}
+	comments += policyComments
+
+	cost, err := g.costComment(ir)
+	if err != nil {
+		return "", fmt.Errorf("estimating cost: %s", err)
+	}
+	comments += cost
+
+	if err := g.checkValueTypes(resolved); err != nil {
+		return "", err
+	}
+
+	var block string
+	if t := g.opts.ResourceTemplates[r.Key.Type]; t != nil {
+		block, err = g.templateBlock(t, ir)
+	} else {
+		block, err = g.resourceBlock(r.Key.BlockType(), r.Key.Type, ir.Name, g.resourceBody(r.State, ir.Name, resolved))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return comments + block, nil
+}
+
+func (g *Generator) isExcluded(resourceType string, attrName string) bool {
+	_, ok := g.typeConfig(resourceType).excludes[attrName]
+	return ok
+}
+
+// Renders the unformatted attributes and dependencies of a resource from its resolved attributes,
+// without the enclosing block. The name of the resource in config is used to name the variables of
+// stubbed secrets.
+func (g *Generator) resourceBody(state *terraform.ResourceState, name string, resolved []*resolvedAttribute) string {
+	var s strings.Builder
+	s.WriteString(g.providerAttributeString(state))
+
+	attrNames, rendered := g.resourceAttributeStrings(state, name, resolved)
+	for _, attrName := range attrNames {
+		s.WriteString(rendered[attrName])
+	}
+
+	s.WriteString(g.timeoutsString(state))
+
+	if len(state.Dependencies) > 0 {
+		s.WriteString("depends_on = [\n")
+		for _, v := range state.Dependencies {
+			if g.opts.Syntax == SyntaxHCL2 {
+				// HCL2 takes references rather than strings, which can't have the legacy splat.
+				s.WriteString(strings.TrimSuffix(v, ".*") + ",\n")
+			} else {
+				s.WriteString(g.primitiveValueString(v) + ",\n")
+			}
+		}
+		s.WriteString("]\n")
+	}
+
+	s.WriteString(g.lifecycleString(state))
+
+	return s.String()
+}
+
+// Where the value of a resolved attribute comes from.
+type attributeSource int
+
+const (
+	attributeFromState attributeSource = iota
+	attributeFromDefault
+	// Rendered as a comment with its state value, see AttributeActionComment.
+	attributeComment
+	// Replaced with a variable, see Options.StubSecrets. The value is never set.
+	attributeSecret
+)
+
+// An attribute of a resource after transformers, excludes, defaults and rules have been applied.
+type resolvedAttribute struct {
+	name   string
+	value  interface{}
+	source attributeSource
+	note   string
+}
+
+// Resolves the attributes of a resource in output order, leaving out excluded attributes. Fails when a
+// hook fails.
+func (g *Generator) resolveAttributes(state *terraform.ResourceState) ([]*resolvedAttribute, error) {
+	tc := g.typeConfig(state.Type)
+	attrs, err := g.runPreHooks(state, expandAttributes(state.Primary.Attributes))
+	if err != nil {
+		return nil, err
+	}
+	attrs = g.transformAttributes(state.Type, attrs)
+	defaults := tc.defaults
+
+	if len(tc.blockSortKeys) > 0 {
+		sortBlocks(attrs, tc.blockSortKeys)
+	}
+
+	// Schemas know the real types, heuristics are only a fallback.
+	if g.opts.CoerceTypes && tc.schemaBlock == nil {
+		for attrName, v := range attrs {
+			attrs[attrName] = g.coerceAttribute(attrName, v)
+		}
+	}
+
+	attrNames := map[string]bool{}
+	for attrName := range attrs {
+		attrNames[attrName] = false
+	}
+
+	// Add the default if the attribute doesn't exist in the resource state.
+	for attrName := range defaults {
+		if _, ok := attrNames[attrName]; !ok {
+			attrNames[attrName] = true
+		}
+	}
+
+	// We sort attribute names to make change diffs more consistent and easier to read.
+	sortedAttrNames := []string{}
+	for k := range attrNames {
+		sortedAttrNames = append(sortedAttrNames, k)
+	}
+	sort.Strings(sortedAttrNames)
+	sortedAttrNames = g.orderAttributeNames(state.Type, sortedAttrNames, attrs, tc.schemaBlock)
+
+	resolved := []*resolvedAttribute{}
+
+	for _, attrName := range sortedAttrNames {
+		fromDefaults := attrNames[attrName]
+
+		// Secrets are checked first so their values never end up in comments.
+		if _, ok := tc.secrets[attrName]; ok && g.opts.StubSecrets {
+			if _, ok := tc.excludes[attrName]; ok {
+				continue
+			}
+			resolved = append(resolved, &resolvedAttribute{name: attrName, source: attributeSecret})
+		} else if rule, ok := tc.rules[attrName]; ok && rule.Action == AttributeActionComment {
+			// Attributes only present through defaults have no state value to comment.
+			if !fromDefaults {
+				resolved = append(resolved, &resolvedAttribute{name: attrName, value: attrs[attrName], source: attributeComment, note: rule.Note})
+			}
+		} else if _, ok := tc.excludes[attrName]; ok {
+			continue
+		} else if !fromDefaults && tc.isValueExcluded(attrName, attrs[attrName]) {
+			continue
+		} else if defaultValue, ok := defaults[attrName]; ok && fromDefaults {
+			if conditional, ok := defaultValue.(*ConditionalDefault); ok {
+				if !conditional.matches(attrs) {
+					continue
+				}
+				defaultValue = conditional.Value
+			}
+			resolved = append(resolved, &resolvedAttribute{name: attrName, value: defaultValue, source: attributeFromDefault})
+		} else {
+			resolved = append(resolved, &resolvedAttribute{name: attrName, value: attrs[attrName], source: attributeFromState})
+		}
+	}
+
+	return g.runPostHooks(state, resolved)
+}
+
+// Renders each resolved attribute of a resource, unformatted. Returns the names of the rendered
+// attributes in output order along with their rendered strings.
+func (g *Generator) resourceAttributeStrings(state *terraform.ResourceState, name string, resolved []*resolvedAttribute) ([]string, map[string]string) {
+	tc := g.typeConfig(state.Type)
+	schemaBlock := tc.schemaBlock
+
+	renderedNames := []string{}
+	rendered := map[string]string{}
+
+	for _, attr := range resolved {
+		s := ""
+
+		switch attr.source {
+		case attributeSecret:
+			s = g.secretAttributeString(state.Type, name, attr.name)
+		case attributeComment:
+			s = g.commentAttributeString(attr.name, attr.value, attr.note)
+		case attributeFromDefault:
+			s = g.schemaAttributeString(attr.name, attr.value, schemaBlock)
+			if g.opts.AnnotateDefaults {
+				s = annotateDefaultString(s)
+			}
+		default:
+			if tc.base64[attr.name] {
+				if s = g.base64AttributeString(attr.name, attr.value); s != "" {
+					break
+				}
+			}
+
+			if include := g.includeFileAttributeString(state.Type, name, attr.name, attr.value); include != "" {
+				s = include
+			} else if tc.manifests[attr.name] {
+				s = g.manifestAttributeString(attr.name, attr.value, schemaBlock)
+			} else {
+				s = g.schemaAttributeString(attr.name, attr.value, schemaBlock)
+			}
+		}
+
+		if s == "" {
+			continue
+		}
+
+		if g.opts.AnnotateDeprecated && attr.source != attributeComment && schemaBlock != nil {
+			if schemaAttr, ok := schemaBlock.Attributes[attr.name]; ok && schemaAttr.Deprecated {
+				s = deprecatedAttributeString(s, schemaAttr)
+			}
+		}
+
+		renderedNames = append(renderedNames, attr.name)
+		rendered[attr.name] = s
+	}
+
+	return renderedNames, rendered
+}
@@ -0,0 +1,137 @@
+package terraconf_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Copies the flat attributes of every resource of a state, to check that rendering leaves them alone.
+func stateAttributes(state *terraform.State) map[string]map[string]string {
+	attrs := map[string]map[string]string{}
+	for _, module := range state.Modules {
+		for key, r := range module.Resources {
+			copied := map[string]string{}
+			for k, v := range r.Primary.Attributes {
+				copied[k] = v
+			}
+			attrs[strings.Join(module.Path, ".")+"/"+key] = copied
+		}
+	}
+
+	return attrs
+}
+
+// Renders a state from many goroutines sharing one Options and one Generator. Run with -race.
+func TestGeneratorConcurrentUse(t *testing.T) {
+	opts := terraconf.NewOptions()
+	opts.Defaults["monitoring"] = false
+	opts.Excludes["arn"] = struct{}{}
+	opts.TypeDefaults["aws_instance"] = terraconf.ResourceDefaults{"ebs_optimized": false}
+	opts.TypeExcludes["aws_security_group"] = terraconf.ResourceExcludes{"owner_id": struct{}{}}
+	opts.ManifestAttributes["aws_instance"] = []string{"user_data"}
+
+	defaults := terraconf.ResourceDefaults{"monitoring": false}
+	excludes := terraconf.ResourceExcludes{"arn": struct{}{}}
+	vpcSecurityGroupIDs := []interface{}{"sg-0000000b", "sg-0000000a"}
+
+	state := mapHeavyState()
+	instance := terraconftest.NewResourceState("aws_instance", "i-0123456789", map[string]interface{}{
+		"ami":                    "ami-0c55b159cbfafe1f0",
+		"vpc_security_group_ids": vpcSecurityGroupIDs,
+	})
+
+	expectedOpts := terraconf.NewOptions()
+	expectedOpts.Defaults["monitoring"] = false
+	expectedOpts.Excludes["arn"] = struct{}{}
+	expectedOpts.TypeDefaults["aws_instance"] = terraconf.ResourceDefaults{"ebs_optimized": false}
+	expectedOpts.TypeExcludes["aws_security_group"] = terraconf.ResourceExcludes{"owner_id": struct{}{}}
+	expectedOpts.ManifestAttributes["aws_instance"] = []string{"user_data"}
+	expectedAttrs := stateAttributes(state)
+	expectedInstanceAttrs := stateAttributes(terraconftest.NewState(map[string]*terraform.ResourceState{"aws_instance.web": instance}))
+
+	shared := terraconf.NewGenerator(opts)
+	expected, err := terraconf.NewGenerator(opts).OutputFiles(state, terraconf.GroupByType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(4)
+
+		go func() {
+			defer wg.Done()
+			files, err := terraconf.NewGenerator(opts).OutputFiles(state, terraconf.GroupByType())
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !reflect.DeepEqual(files, expected) {
+				t.Error("output of a concurrent generator differs")
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			if _, err := shared.OutputFiles(state, terraconf.GroupByType()); err != nil {
+				errs <- err
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			if _, err := terraconf.GetStateConfigString(state, opts); err != nil {
+				errs <- err
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			terraconf.ResourceStateToConfigString(instance, defaults, excludes)
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(opts.Defaults, expectedOpts.Defaults) || !reflect.DeepEqual(opts.Excludes, expectedOpts.Excludes) {
+		t.Errorf("defaults or excludes of the options were modified: %v, %v", opts.Defaults, opts.Excludes)
+	}
+	if !reflect.DeepEqual(opts.TypeDefaults, expectedOpts.TypeDefaults) || !reflect.DeepEqual(opts.TypeExcludes, expectedOpts.TypeExcludes) {
+		t.Errorf("type defaults or excludes of the options were modified: %v, %v", opts.TypeDefaults, opts.TypeExcludes)
+	}
+	if !reflect.DeepEqual(opts.ManifestAttributes, expectedOpts.ManifestAttributes) {
+		t.Errorf("manifest attributes of the options were modified: %v", opts.ManifestAttributes)
+	}
+	if len(opts.Transformers) != 0 {
+		t.Errorf("transformers were added to the options: %v", opts.Transformers)
+	}
+
+	if !reflect.DeepEqual(defaults, terraconf.ResourceDefaults{"monitoring": false}) {
+		t.Errorf("defaults were modified: %v", defaults)
+	}
+	if !reflect.DeepEqual(excludes, terraconf.ResourceExcludes{"arn": struct{}{}}) {
+		t.Errorf("excludes were modified: %v", excludes)
+	}
+	if !reflect.DeepEqual(vpcSecurityGroupIDs, []interface{}{"sg-0000000b", "sg-0000000a"}) {
+		t.Errorf("attribute values were modified: %v", vpcSecurityGroupIDs)
+	}
+
+	if !reflect.DeepEqual(stateAttributes(state), expectedAttrs) {
+		t.Error("attributes of the state were modified")
+	}
+	if !reflect.DeepEqual(stateAttributes(terraconftest.NewState(map[string]*terraform.ResourceState{"aws_instance.web": instance})), expectedInstanceAttrs) {
+		t.Error("attributes of the resource were modified")
+	}
+}
@@ -0,0 +1,85 @@
+package terraconf
+
+import (
+	"strings"
+)
+
+// Attributes of google resources usually set once in the provider block, see ExtractProviderConfig.
+var GoogleProviderAttributes = []string{"project", "region"}
+
+// Prefixes of the self links of google resources, which the provider also accepts as relative paths.
+var googleSelfLinkPrefixes = []string{
+	"https://www.googleapis.com/compute/v1/",
+	"https://www.googleapis.com/compute/beta/",
+	"https://compute.googleapis.com/compute/v1/",
+}
+
+// Rendering rules for the google provider: references to other resources given as self links, e.g.
+// the network of an instance, are rendered as relative paths like `projects/p/global/networks/default`,
+// and output only fields of common resource types are excluded. Use ExtractProviderConfig with
+// GoogleProviderAttributes to move the project and region into the provider block.
+var GoogleProfile = &Profile{
+	Name: "google",
+	TypeExcludes: map[string]ResourceExcludes{
+		"google_compute_instance":      googleOutputOnly("cpu_platform", "current_status", "instance_id", "label_fingerprint", "metadata_fingerprint", "tags_fingerprint"),
+		"google_compute_disk":          googleOutputOnly("label_fingerprint", "last_attach_timestamp", "last_detach_timestamp", "source_image_id", "users"),
+		"google_compute_address":       googleOutputOnly("users"),
+		"google_compute_firewall":      googleOutputOnly(),
+		"google_compute_network":       googleOutputOnly("gateway_ipv4"),
+		"google_compute_subnetwork":    googleOutputOnly("fingerprint", "gateway_address"),
+		"google_container_cluster":     googleOutputOnly("endpoint", "label_fingerprint", "master_version", "operation", "services_ipv4_cidr"),
+		"google_container_node_pool":   googleOutputOnly("instance_group_urls", "operation"),
+		"google_sql_database_instance": googleOutputOnly("connection_name", "first_ip_address", "private_ip_address", "public_ip_address", "server_ca_cert", "service_account_email_address"),
+		"google_storage_bucket":        googleOutputOnly("url"),
+	},
+	Transformers: map[string][]ResourceTransformer{
+		AllResourceTypes: {googleSelfLinkTransformer},
+	},
+}
+
+// Excludes the output only fields most google resources have along with the given ones.
+func googleOutputOnly(attrNames ...string) ResourceExcludes {
+	excludes := ResourceExcludes{"creation_timestamp": struct{}{}, "self_link": struct{}{}}
+	for _, attrName := range attrNames {
+		excludes[attrName] = struct{}{}
+	}
+
+	return excludes
+}
+
+func googleSelfLinkTransformer(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+	if providerName(resourceType) != "google" {
+		return attrs
+	}
+
+	for attrName, v := range attrs {
+		// The resource's own self link stays as it is, unless excluded.
+		if attrName != "self_link" {
+			attrs[attrName] = relativeSelfLinks(v)
+		}
+	}
+
+	return attrs
+}
+
+// Replaces the self links within a value with relative paths.
+func relativeSelfLinks(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		for _, prefix := range googleSelfLinkPrefixes {
+			if strings.HasPrefix(v, prefix) {
+				return strings.TrimPrefix(v, prefix)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = relativeSelfLinks(item)
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = relativeSelfLinks(item)
+		}
+	}
+
+	return v
+}
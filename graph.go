@@ -0,0 +1,112 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Renders a Graphviz DOT graph of the resources of a state, with an edge from every resource to the
+// resources it depends on according to the state, plus a labelled dashed edge for every reference, e.g.
+// those found by Generator.LinkResources. Nodes are clustered by module and sorted for stable output.
+func DependencyGraphString(state *terraform.State, references []*Reference) (string, error) {
+	type edge struct {
+		from  string
+		to    string
+		label string
+	}
+
+	modules := map[string][]string{}
+	edges := map[edge]bool{}
+
+	for _, module := range state.Modules {
+		moduleAddress := ModuleAddress(module.Path)
+
+		// Dependencies name resources without their instance index, e.g. `aws_instance.web.*`.
+		byGroupKey := map[string][]string{}
+		keys := map[string]*ResourceKey{}
+		for rawKey := range module.Resources {
+			k, err := ParseResourceKey(rawKey)
+			if err != nil {
+				return "", err
+			}
+			keys[rawKey] = k
+
+			address := ResourceAddress(module.Path, k)
+			byGroupKey[k.GroupKey()] = append(byGroupKey[k.GroupKey()], address)
+			modules[moduleAddress] = append(modules[moduleAddress], address)
+		}
+
+		for rawKey, resource := range module.Resources {
+			from := ResourceAddress(module.Path, keys[rawKey])
+
+			for _, dep := range resource.Dependencies {
+				for _, to := range byGroupKey[strings.TrimSuffix(dep, ".*")] {
+					if to != from {
+						edges[edge{from: from, to: to}] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, r := range references {
+		edges[edge{from: r.From, to: r.To, label: r.Attribute}] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph terraconf {\n  rankdir = \"LR\";\n  node [shape = \"box\"];\n")
+
+	moduleAddresses := []string{}
+	for moduleAddress := range modules {
+		moduleAddresses = append(moduleAddresses, moduleAddress)
+	}
+	sort.Strings(moduleAddresses)
+
+	for i, moduleAddress := range moduleAddresses {
+		addresses := modules[moduleAddress]
+		sort.Strings(addresses)
+
+		indent := "  "
+		if moduleAddress != "" {
+			fmt.Fprintf(&b, "  subgraph \"cluster_%d\" {\n    label = %s;\n", i, strconv.Quote(moduleAddress))
+			indent = "    "
+		}
+		for _, address := range addresses {
+			fmt.Fprintf(&b, "%s%s;\n", indent, strconv.Quote(address))
+		}
+		if moduleAddress != "" {
+			b.WriteString("  }\n")
+		}
+	}
+
+	sortedEdges := []edge{}
+	for e := range edges {
+		sortedEdges = append(sortedEdges, e)
+	}
+	sort.Slice(sortedEdges, func(i, j int) bool {
+		a, b := sortedEdges[i], sortedEdges[j]
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		if a.to != b.to {
+			return a.to < b.to
+		}
+		return a.label < b.label
+	})
+
+	for _, e := range sortedEdges {
+		if e.label == "" {
+			fmt.Fprintf(&b, "  %s -> %s;\n", strconv.Quote(e.from), strconv.Quote(e.to))
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s [label = %s, style = \"dashed\"];\n", strconv.Quote(e.from), strconv.Quote(e.to), strconv.Quote(e.label))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	return b.String(), nil
+}
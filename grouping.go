@@ -0,0 +1,123 @@
+package terraconf
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Returns the name of the group, e.g. the output file, a resource is placed in.
+type GroupingStrategy func(r *ResourceInstance) string
+
+var unsafeGroupNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Groups resources by type, e.g. into one file per type.
+func GroupByType() GroupingStrategy {
+	return func(r *ResourceInstance) string {
+		return r.Key.Type
+	}
+}
+
+// Groups resources by the module they were in, named after the module path, e.g. `vpc` for
+// `module.vpc` and `root` for the root module.
+func GroupByModule() GroupingStrategy {
+	return func(r *ResourceInstance) string {
+		names := []string{}
+		for i, name := range r.ModulePath {
+			if i == 0 && name == "root" {
+				continue
+			}
+			names = append(names, name)
+		}
+
+		if len(names) == 0 {
+			return "root"
+		}
+
+		return sanitizeGroupName(strings.Join(names, "_"))
+	}
+}
+
+// Groups resources by the value of a tag, e.g. `Environment`, using fallback for untagged resources.
+func GroupByTag(tag string, fallback string) GroupingStrategy {
+	return func(r *ResourceInstance) string {
+		if r.State.Primary == nil {
+			return fallback
+		}
+
+		tags, ok := expandAttributes(r.State.Primary.Attributes)["tags"].(map[string]interface{})
+		if !ok {
+			return fallback
+		}
+
+		value, ok := tags[tag].(string)
+		if !ok || value == "" {
+			return fallback
+		}
+
+		return sanitizeGroupName(value)
+	}
+}
+
+// Splits resources into groups by strategy. Resources keep their relative order within a group.
+func GroupResources(resources []*ResourceInstance, strategy GroupingStrategy) map[string][]*ResourceInstance {
+	groups := map[string][]*ResourceInstance{}
+
+	for _, r := range resources {
+		name := strategy(r)
+		groups[name] = append(groups[name], r)
+	}
+
+	return groups
+}
+
+// Splits groups with more than max resources into chunks of at most max resources named after the
+// group and their position, e.g. `aws_route53_record_001`, keeping editors and code review tools
+// usable. Smaller groups keep their name. A max of zero or less leaves every group whole.
+func ChunkGroups(groups map[string][]*ResourceInstance, max int) map[string][]*ResourceInstance {
+	if max <= 0 {
+		return groups
+	}
+
+	chunked := map[string][]*ResourceInstance{}
+	for name, resources := range groups {
+		if len(resources) <= max {
+			chunked[name] = resources
+			continue
+		}
+
+		n := (len(resources) + max - 1) / max
+		width := len(strconv.Itoa(n))
+		if width < 3 {
+			width = 3
+		}
+
+		for i := 0; i < n; i++ {
+			end := (i + 1) * max
+			if end > len(resources) {
+				end = len(resources)
+			}
+			chunked[fmt.Sprintf("%s_%0*d", name, width, i+1)] = resources[i*max : end]
+		}
+	}
+
+	return chunked
+}
+
+// Groups resources by strategy, chunking groups larger than the MaxResourcesPerFile option.
+func (g *Generator) groupResources(resources []*ResourceInstance, strategy GroupingStrategy) map[string][]*ResourceInstance {
+	return ChunkGroups(GroupResources(resources, strategy), g.opts.MaxResourcesPerFile)
+}
+
+// Makes a group name safe to use as a file name.
+func sanitizeGroupName(name string) string {
+	return unsafeGroupNameChars.ReplaceAllString(name, "_")
+}
+
+// Returns the path of the file a group is written to within dir, e.g. `out/aws_instance.tf`, using the
+// path separator of the platform.
+func GroupFilePath(dir string, group string) string {
+	return filepath.Join(dir, group+".tf")
+}
@@ -0,0 +1,230 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Renders an attribute in HCL2 syntax. Values and nested blocks are built with hclwrite, which takes
+// care of escaping, rather than by formatting strings.
+func (g *Generator) hcl2AttributeString(attrName string, attrRawVal interface{}, block *SchemaBlock) string {
+	f := hclwrite.NewEmptyFile()
+	g.appendHCL2Attribute(f.Body(), attrName, attrRawVal, block)
+
+	return string(f.Bytes())
+}
+
+func (g *Generator) appendHCL2Attribute(body *hclwrite.Body, attrName string, attrRawVal interface{}, block *SchemaBlock) {
+	asBlock, nestedBlock := hcl2IsBlock(attrName, attrRawVal, block)
+
+	switch v := attrRawVal.(type) {
+	case nil:
+		return
+	case string:
+		// Same special case as PrimitiveAttributeToString.
+		if attrName == "date" && v == "" {
+			return
+		}
+	case map[string]interface{}:
+		// Empty maps are skipped by default since state has them for attributes never set.
+		if len(v) == 0 && g.opts.EmptyValues == SkipEmptyValues {
+			return
+		}
+
+		if asBlock {
+			g.appendHCL2Block(body, attrName, v, nestedBlock)
+			return
+		}
+		attrRawVal = g.typedMapValues(attrName, v, block)
+	case []interface{}:
+		// Empty lists/sets are skipped by default since state has them for attributes never set.
+		if len(v) == 0 && g.opts.EmptyValues == SkipEmptyValues {
+			return
+		}
+
+		if asBlock {
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					g.appendHCL2Block(body, attrName, m, nestedBlock)
+				}
+			}
+			return
+		}
+	}
+
+	body.SetAttributeRaw(attrName, g.hcl2ValueTokens(attrRawVal))
+}
+
+func (g *Generator) appendHCL2Block(body *hclwrite.Body, blockName string, m map[string]interface{}, block *SchemaBlock) {
+	nested := body.AppendNewBlock(blockName, nil)
+
+	for _, k := range sortedKeys(m) {
+		g.appendHCL2Attribute(nested.Body(), k, m[k], block)
+	}
+}
+
+// Number literals that render back to the same string.
+var hcl2NumberLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// An interpolated string holding nothing but a reference, e.g. `${local.tags}` or
+// `${aws_vpc.main.id}`.
+var hcl2InterpolatedReference = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_-]*(?:\.[A-Za-z_][A-Za-z0-9_-]*|\[[0-9]+\])*)\}$`)
+
+// Converts the string values of a map argument, which the state stores as strings whatever their
+// type, back to numbers or booleans so they aren't rendered quoted. The element type comes from the
+// schema when it knows the attribute. Otherwise values are only converted with the CoerceTypes option,
+// when every one of them looks like a number, or every one like a boolean, see CoerceValue, and the
+// attribute isn't denied coercion.
+func (g *Generator) typedMapValues(attrName string, m map[string]interface{}, block *SchemaBlock) map[string]interface{} {
+	elemType := ""
+	if block != nil {
+		if attr, ok := block.Attributes[attrName]; ok {
+			if attr.TypeName() != "map" {
+				return m
+			}
+			elemType = attr.ElementTypeName()
+			if elemType != "number" && elemType != "bool" {
+				return m
+			}
+		}
+	}
+	if elemType == "" && (!g.opts.CoerceTypes || !g.isCoerced(attrName)) {
+		return m
+	}
+
+	typed := map[string]interface{}{}
+	kind := ""
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			if elemType == "" {
+				return m
+			}
+			typed[k] = v
+			continue
+		}
+
+		switch elemType {
+		case "number":
+			if hcl2NumberLiteral.MatchString(s) {
+				typed[k] = json.Number(s)
+			} else {
+				typed[k] = s
+			}
+		case "bool":
+			if b, err := strconv.ParseBool(s); err == nil {
+				typed[k] = b
+			} else {
+				typed[k] = s
+			}
+		default:
+			coerced := CoerceValue(s)
+			valueKind := "number"
+			switch coerced.(type) {
+			case string:
+				return m
+			case bool:
+				valueKind = "bool"
+			}
+			if kind != "" && kind != valueKind {
+				return m
+			}
+			kind = valueKind
+			typed[k] = coerced
+		}
+	}
+
+	return typed
+}
+
+// Whether an attribute is rendered as nested blocks, returning the schema of the nested block when
+// known. Without a schema, maps with complex values and lists of maps are assumed to be blocks while
+// maps of primitives are assumed to be map arguments such as tags.
+func hcl2IsBlock(attrName string, attrRawVal interface{}, block *SchemaBlock) (bool, *SchemaBlock) {
+	if block != nil {
+		if blockType, ok := block.BlockTypes[attrName]; ok {
+			return true, blockType.Block
+		}
+		if _, ok := block.Attributes[attrName]; ok {
+			return false, nil
+		}
+	}
+
+	switch v := attrRawVal.(type) {
+	case map[string]interface{}:
+		for _, item := range v {
+			if !IsPrimitive(item) {
+				return true, nil
+			}
+		}
+	case []interface{}:
+		return len(v) > 0 && !IsPrimitive(v[0]), nil
+	}
+
+	return false, nil
+}
+
+func (g *Generator) hcl2ValueTokens(rawValue interface{}) hclwrite.Tokens {
+	switch v := rawValue.(type) {
+	case string:
+		return hclwrite.TokensForValue(cty.StringVal(v))
+	case InterpolatedString:
+		// Interpolation-only strings are deprecated in HCL2, a lone reference is rendered bare instead.
+		if m := hcl2InterpolatedReference.FindStringSubmatch(string(v)); m != nil {
+			return hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte(m[1])}}
+		}
+
+		// The template sequences are kept, only quotes, backslashes and control characters are escaped.
+		quoted := quoteHCLString(string(v))
+		return hclwrite.Tokens{
+			{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)},
+			{Type: hclsyntax.TokenQuotedLit, Bytes: []byte(quoted[1 : len(quoted)-1])},
+			{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)},
+		}
+	case bool:
+		return hclwrite.TokensForValue(cty.BoolVal(v))
+	case []byte:
+		return hclwrite.TokensForValue(cty.StringVal(string(v)))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return hclwrite.Tokens{{Type: hclsyntax.TokenNumberLit, Bytes: []byte(fmt.Sprintf("%d", v))}}
+	case float32:
+		return hclwrite.Tokens{{Type: hclsyntax.TokenNumberLit, Bytes: []byte(g.floatString(float64(v), 32))}}
+	case float64:
+		return hclwrite.Tokens{{Type: hclsyntax.TokenNumberLit, Bytes: []byte(g.floatString(v, 64))}}
+	case json.Number:
+		return hclwrite.Tokens{{Type: hclsyntax.TokenNumberLit, Bytes: []byte(g.jsonNumberString(v))}}
+	case []interface{}:
+		elems := []hclwrite.Tokens{}
+		for _, item := range v {
+			elems = append(elems, g.hcl2ValueTokens(item))
+		}
+		return hclwrite.TokensForTuple(elems)
+	case map[string]interface{}:
+		attrs := []hclwrite.ObjectAttrTokens{}
+		for _, k := range sortedKeys(v) {
+			attrs = append(attrs, hclwrite.ObjectAttrTokens{
+				Name:  hcl2ObjectKeyTokens(k),
+				Value: g.hcl2ValueTokens(v[k]),
+			})
+		}
+		return hclwrite.TokensForObject(attrs)
+	}
+
+	// Unsupported types fail to plan, see primitiveValueString.
+	return hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte("unknown")}}
+}
+
+// Object keys that are not valid identifiers, e.g. `kubernetes.io/role`, have to be quoted.
+func hcl2ObjectKeyTokens(k string) hclwrite.Tokens {
+	if hclsyntax.ValidIdentifier(k) {
+		return hclwrite.TokensForIdentifier(k)
+	}
+
+	return hclwrite.TokensForValue(cty.StringVal(k))
+}
@@ -0,0 +1,40 @@
+package terraconf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Map values that look like numbers stay strings unless types are coerced, as the state can't tell
+// `"8080"` from `8080` without a schema.
+func TestHCL2MapValuesCoercion(t *testing.T) {
+	state := terraconftest.NewResourceState("aws_lambda_function", "api", map[string]interface{}{
+		"environment": []interface{}{
+			map[string]interface{}{
+				"variables": map[string]interface{}{"PORT": "8080", "WORKERS": "4"},
+			},
+		},
+	})
+
+	for _, coerce := range []bool{false, true} {
+		opts := terraconf.NewOptions()
+		opts.Syntax = terraconf.SyntaxHCL2
+		opts.CoerceTypes = coerce
+
+		config, err := terraconf.NewGenerator(opts).ResourceConfig(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `PORT    = "8080"`
+		if coerce {
+			expected = `PORT    = 8080`
+		}
+		if !strings.Contains(config, expected) {
+			t.Errorf("coerce %t: expected %s in:\n%s", coerce, expected, config)
+		}
+	}
+}
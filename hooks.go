@@ -0,0 +1,225 @@
+package terraconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// When an ExecHook runs during generation.
+type HookStage int
+
+const (
+	// Before transformers, receiving the attributes of the state with source "state".
+	HookPre HookStage = iota
+	// After transformers, excludes, defaults and rules, receiving the attributes in output order with
+	// their sources.
+	HookPost
+)
+
+// An external command run for every generated resource of a type, to plug in organization specific
+// policy or transformation scripts. The command receives the resource as IRResource JSON on stdin and
+// writes the modified resource in the same form to stdout. Only the attributes of the output are used;
+// pre hooks may add or remove attributes, post hooks may also reorder them or change their sources.
+// Address, Mode and Name are left empty, since hooks run on resource states before resources are
+// named. Values of stubbed secrets are never passed to hooks, see Options.StubSecrets. A hook failing
+// fails the generation of the resource, so hooks can act as policy gates.
+type ExecHook struct {
+	Stage HookStage
+	// Resource type the hook runs for, or AllResourceTypes.
+	ResourceType string
+
+	Command string
+	Args    []string
+
+	// Kills the command after the timeout, or 0 for none.
+	Timeout time.Duration
+}
+
+// Runs the hook for a resource, returning the IR written by the command.
+func (h *ExecHook) run(ir *IRResource) (*IRResource, error) {
+	in, err := json.Marshal(ir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("hook %s: %s: %s", h.Command, err, msg)
+		}
+		return nil, fmt.Errorf("hook %s: %s", h.Command, err)
+	}
+
+	// Numbers are kept as written, integers may be too large for a float.
+	out := &IRResource{}
+	decoder := json.NewDecoder(&stdout)
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return nil, fmt.Errorf("hook %s: reading output: %s", h.Command, err)
+	}
+
+	return out, nil
+}
+
+// Runs the hook on the attributes of a resource, returning the attributes written by the command.
+// JSON doesn't tell interpolated strings from plain ones, so attributes whose values the hook didn't
+// change keep their values as they were, e.g. references set by Generator.LinkResources.
+func (h *ExecHook) runAttributes(ir *IRResource) ([]*IRAttribute, error) {
+	out, err := h.run(ir)
+	if err != nil {
+		return nil, err
+	}
+
+	before := map[string]interface{}{}
+	for _, attr := range ir.Attributes {
+		before[attr.Name] = attr.Value
+	}
+
+	for _, attr := range out.Attributes {
+		if v, ok := before[attr.Name]; ok && sameJSON(v, attr.Value) {
+			attr.Value = v
+		}
+	}
+
+	return out.Attributes, nil
+}
+
+// Whether two values have the same JSON encoding.
+func sameJSON(a interface{}, b interface{}) bool {
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(aJSON, bJSON)
+}
+
+// Describes a resource state for a hook, without attributes.
+func hookIR(state *terraform.ResourceState) *IRResource {
+	return &IRResource{
+		Type:         state.Type,
+		ID:           state.Primary.ID,
+		Provider:     state.Provider,
+		Attributes:   []*IRAttribute{},
+		Dependencies: state.Dependencies,
+	}
+}
+
+// Runs the pre hooks of a resource type in order on the expanded attributes of a resource state.
+// Stubbed secrets are passed with source "secret" and without their value, which is restored for the
+// secrets the hooks keep.
+func (g *Generator) runPreHooks(state *terraform.ResourceState, attrs map[string]interface{}) (map[string]interface{}, error) {
+	tc := g.typeConfig(state.Type)
+	if len(tc.preHooks) == 0 {
+		return attrs, nil
+	}
+
+	attrNames := []string{}
+	for attrName := range attrs {
+		attrNames = append(attrNames, attrName)
+	}
+	sort.Strings(attrNames)
+
+	ir := hookIR(state)
+	secrets := map[string]interface{}{}
+	for _, attrName := range attrNames {
+		if _, ok := tc.secrets[attrName]; ok && g.opts.StubSecrets {
+			secrets[attrName] = attrs[attrName]
+			ir.Attributes = append(ir.Attributes, &IRAttribute{Name: attrName, Source: irAttributeSources[attributeSecret]})
+			continue
+		}
+		ir.Attributes = append(ir.Attributes, &IRAttribute{Name: attrName, Value: attrs[attrName], Source: irAttributeSources[attributeFromState]})
+	}
+
+	for _, hook := range tc.preHooks {
+		out, err := hook.runAttributes(ir)
+		if err != nil {
+			return nil, err
+		}
+		ir.Attributes = out
+	}
+
+	result := map[string]interface{}{}
+	for _, attr := range ir.Attributes {
+		if v, ok := secrets[attr.Name]; ok && attr.Source == irAttributeSources[attributeSecret] {
+			result[attr.Name] = v
+			continue
+		}
+		result[attr.Name] = attr.Value
+	}
+
+	return result, nil
+}
+
+// Runs the post hooks of a resource type in order on the resolved attributes of a resource state.
+func (g *Generator) runPostHooks(state *terraform.ResourceState, resolved []*resolvedAttribute) ([]*resolvedAttribute, error) {
+	hooks := g.typeConfig(state.Type).postHooks
+
+	for _, hook := range hooks {
+		ir := hookIR(state)
+		for _, attr := range resolved {
+			ir.Attributes = append(ir.Attributes, &IRAttribute{Name: attr.name, Value: attr.value, Source: irAttributeSources[attr.source], Note: attr.note})
+		}
+
+		out, err := hook.runAttributes(ir)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved, err = resolvedHookAttributes(out)
+		if err != nil {
+			return nil, fmt.Errorf("hook %s: %s", hook.Command, err)
+		}
+	}
+
+	return resolved, nil
+}
+
+// Converts the attributes written by a post hook back into resolved attributes.
+func resolvedHookAttributes(attrs []*IRAttribute) ([]*resolvedAttribute, error) {
+	sources := map[string]attributeSource{}
+	for source, name := range irAttributeSources {
+		sources[name] = source
+	}
+
+	resolved := []*resolvedAttribute{}
+	for _, attr := range attrs {
+		source, ok := sources[attr.Source]
+		if !ok {
+			return nil, fmt.Errorf("unknown source %q of attribute %s", attr.Source, attr.Name)
+		}
+
+		resolvedAttr := &resolvedAttribute{name: attr.Name, value: attr.Value, source: source, note: attr.Note}
+		if source == attributeSecret {
+			resolvedAttr.value = nil
+		}
+		resolved = append(resolved, resolvedAttr)
+	}
+
+	return resolved, nil
+}
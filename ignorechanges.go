@@ -0,0 +1,389 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Lines of unchanged context around the changes of a patch.
+const patchContextLines = 3
+
+// Attributes to add to the ignore_changes of a resource so the plan of the generated config no longer
+// changes it.
+type IgnoreChangesSuggestion struct {
+	// Address of the resource in the generated config, as in PlanDrift.
+	Address    string
+	Attributes []string
+}
+
+// Suggests ignore_changes for the drifts reported by VerifyPlan, e.g. for attributes set by the
+// platform that the generated config cannot express. Resources terraform plans to create or destroy
+// can't be silenced by ignore_changes and are left out.
+func SuggestIgnoreChanges(drifts []*PlanDrift) []*IgnoreChangesSuggestion {
+	suggestions := []*IgnoreChangesSuggestion{}
+
+	for _, drift := range drifts {
+		if len(drift.Actions) == 1 && (drift.Actions[0] == "create" || drift.Actions[0] == "delete") {
+			continue
+		}
+
+		s := &IgnoreChangesSuggestion{Address: drift.Address}
+		for _, attr := range drift.Attributes {
+			s.Attributes = append(s.Attributes, attr.Name)
+		}
+		if len(s.Attributes) > 0 {
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	return suggestions
+}
+
+// Renders a unified diff adding the suggested ignore_changes to the generated files, e.g. from
+// OutputFilesWithManifest, to apply with `patch -p1` in the output directory. A lifecycle block is
+// added to resources without one, otherwise the ignore_changes argument is added to the existing block.
+// Files are read and patched in the syntax of the options. Resources are looked up in the file the
+// manifest records for them, which also finds resources in modules. Without a manifest, only root
+// module resources are patched, in whichever file declares them. Resources not found in the files and
+// those already having ignore_changes are left out of the patch.
+func (g *Generator) IgnoreChangesPatch(files []*OutputFile, manifest *Manifest, suggestions []*IgnoreChangesSuggestion) (string, error) {
+	// Suggestions by file, or by "" for any file, and by block address within the file. Every
+	// instance of a counted resource shares the block.
+	byFile := map[string]map[string]*IgnoreChangesSuggestion{}
+	for _, s := range suggestions {
+		a, err := ParseAddress(s.Address)
+		if err != nil {
+			return "", err
+		}
+		if a.Resource == nil {
+			continue
+		}
+		k := &ResourceKey{Data: a.Resource.Data, Type: a.Resource.Type, Name: a.Resource.Name}
+
+		paths := []string{}
+		if manifest != nil {
+			paths = manifestFiles(manifest, ResourceAddress(a.Module, k))
+		} else if len(a.Module) == 0 {
+			paths = []string{""}
+		}
+
+		for _, path := range paths {
+			if byFile[path] == nil {
+				byFile[path] = map[string]*IgnoreChangesSuggestion{}
+			}
+			existing := byFile[path][k.String()]
+			if existing == nil {
+				existing = &IgnoreChangesSuggestion{Address: ResourceAddress(a.Module, k)}
+			}
+			byFile[path][k.String()] = &IgnoreChangesSuggestion{Address: existing.Address, Attributes: mergeAttributeNames(existing.Attributes, s.Attributes)}
+		}
+	}
+
+	var b strings.Builder
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path, ".tf") {
+			continue
+		}
+
+		byAddress := byFile[f.Path]
+		if manifest == nil {
+			byAddress = byFile[""]
+		}
+		if len(byAddress) == 0 {
+			continue
+		}
+
+		insertions, err := g.ignoreChangesInsertions(f, byAddress)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(insertionPatch(f.Path, f.Content, insertions))
+	}
+
+	return b.String(), nil
+}
+
+// Returns the files the manifest records the instances of a resource in, given its address without
+// index.
+func manifestFiles(manifest *Manifest, address string) []string {
+	seen := map[string]bool{}
+	paths := []string{}
+	for _, entry := range manifest.Resources {
+		a, err := ParseAddress(entry.Address)
+		if err != nil || a.Resource == nil {
+			continue
+		}
+
+		k := &ResourceKey{Data: a.Resource.Data, Type: a.Resource.Type, Name: a.Resource.Name}
+		if ResourceAddress(a.Module, k) == address && !seen[entry.File] {
+			seen[entry.File] = true
+			paths = append(paths, entry.File)
+		}
+	}
+
+	return paths
+}
+
+// The position of a resource block in a file, as needed to add ignore_changes to it.
+type blockLayout struct {
+	// Address of the resource within the file, without module.
+	address string
+
+	// Indentation of one level within the block.
+	indent string
+
+	// Line and column of the closing brace of the block, and of its lifecycle block when it has one.
+	closeLine, closeColumn                   int
+	lifecycle                                bool
+	lifecycleCloseLine, lifecycleCloseColumn int
+	ignoreChanges                            bool
+}
+
+// Finds the lines to insert into a file, by the line they are inserted before.
+func (g *Generator) ignoreChangesInsertions(f *OutputFile, suggestions map[string]*IgnoreChangesSuggestion) (map[int][]string, error) {
+	var layouts []*blockLayout
+	var err error
+	if g.opts.Syntax == SyntaxHCL2 {
+		layouts, err = hcl2BlockLayouts(f)
+	} else {
+		layouts, err = hcl1BlockLayouts(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	insertions := map[int][]string{}
+	for _, block := range layouts {
+		s, ok := suggestions[block.address]
+		if !ok || block.ignoreChanges {
+			continue
+		}
+
+		ignoreChanges := "ignore_changes = " + g.ignoreChangesList(s.Attributes)
+
+		if !block.lifecycle {
+			prefix := strings.Repeat(" ", block.closeColumn-1)
+			insertions[block.closeLine] = []string{
+				"",
+				prefix + block.indent + "lifecycle {",
+				prefix + block.indent + block.indent + ignoreChanges,
+				prefix + block.indent + "}",
+			}
+			continue
+		}
+
+		insertions[block.lifecycleCloseLine] = []string{strings.Repeat(" ", block.lifecycleCloseColumn-1) + block.indent + ignoreChanges}
+	}
+
+	return insertions, nil
+}
+
+// Renders the list of attributes to ignore, as references in HCL2 and as strings in HCL1.
+func (g *Generator) ignoreChangesList(attrNames []string) string {
+	if g.opts.Syntax == SyntaxHCL2 {
+		return "[" + strings.Join(attrNames, ", ") + "]"
+	}
+
+	quoted := []string{}
+	for _, attrName := range attrNames {
+		quoted = append(quoted, strconv.Quote(attrName))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// Returns the layout of the resource and data blocks of a file in HCL2 syntax.
+func hcl2BlockLayouts(f *OutputFile) ([]*blockLayout, error) {
+	parsed, diags := hclsyntax.ParseConfig([]byte(f.Content), f.Path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing %s: %s", f.Path, diags)
+	}
+
+	layouts := []*blockLayout{}
+	for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+		if (block.Type != "resource" && block.Type != "data") || len(block.Labels) != 2 {
+			continue
+		}
+
+		k := &ResourceKey{Data: block.Type == "data", Type: block.Labels[0], Name: block.Labels[1]}
+		closing := block.CloseBraceRange.Start
+		layout := &blockLayout{address: k.String(), closeLine: closing.Line, closeColumn: closing.Column}
+
+		column := 0
+		for _, attr := range block.Body.Attributes {
+			if column == 0 || attr.SrcRange.Start.Column < column {
+				column = attr.SrcRange.Start.Column
+			}
+		}
+		for _, nested := range block.Body.Blocks {
+			if column == 0 || nested.TypeRange.Start.Column < column {
+				column = nested.TypeRange.Start.Column
+			}
+
+			if nested.Type == "lifecycle" {
+				closing := nested.CloseBraceRange.Start
+				layout.lifecycle = true
+				layout.lifecycleCloseLine, layout.lifecycleCloseColumn = closing.Line, closing.Column
+				_, layout.ignoreChanges = nested.Body.Attributes["ignore_changes"]
+			}
+		}
+		layout.indent = levelIndent(column, layout.closeColumn)
+
+		layouts = append(layouts, layout)
+	}
+
+	return layouts, nil
+}
+
+// Returns the layout of the resource and data blocks of a file in HCL1 syntax.
+func hcl1BlockLayouts(f *OutputFile) ([]*blockLayout, error) {
+	parsed, err := parser.Parse([]byte(f.Content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", f.Path, err)
+	}
+
+	root, ok := parsed.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, nil
+	}
+
+	layouts := []*blockLayout{}
+	for _, item := range root.Items {
+		if len(item.Keys) != 3 {
+			continue
+		}
+		keys := []string{}
+		for _, key := range item.Keys {
+			keys = append(keys, hcl1KeyString(key))
+		}
+		if keys[0] != "resource" && keys[0] != "data" {
+			continue
+		}
+		body, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			continue
+		}
+
+		k := &ResourceKey{Data: keys[0] == "data", Type: keys[1], Name: keys[2]}
+		layout := &blockLayout{address: k.String(), closeLine: body.Rbrace.Line, closeColumn: body.Rbrace.Column}
+
+		column := 0
+		for _, nested := range body.List.Items {
+			if len(nested.Keys) == 0 {
+				continue
+			}
+			if column == 0 || nested.Pos().Column < column {
+				column = nested.Pos().Column
+			}
+
+			lifecycle, ok := nested.Val.(*ast.ObjectType)
+			if !ok || hcl1KeyString(nested.Keys[0]) != "lifecycle" {
+				continue
+			}
+			layout.lifecycle = true
+			layout.lifecycleCloseLine, layout.lifecycleCloseColumn = lifecycle.Rbrace.Line, lifecycle.Rbrace.Column
+			layout.ignoreChanges = len(lifecycle.List.Filter("ignore_changes").Items) > 0
+		}
+		layout.indent = levelIndent(column, layout.closeColumn)
+
+		layouts = append(layouts, layout)
+	}
+
+	return layouts, nil
+}
+
+// Returns the text of an HCL1 object key, without quotes for string keys.
+func hcl1KeyString(key *ast.ObjectKey) string {
+	if s, err := strconv.Unquote(key.Token.Text); err == nil {
+		return s
+	}
+
+	return key.Token.Text
+}
+
+// Returns the indentation of one level within a block, given the column of its least indented
+// attribute or nested block, if any, and of its closing brace, or two spaces.
+func levelIndent(column int, closeColumn int) string {
+	if width := column - closeColumn; column > 0 && width > 0 {
+		return strings.Repeat(" ", width)
+	}
+
+	return "  "
+}
+
+// Merges attribute names, sorted and without duplicates.
+func mergeAttributeNames(a []string, b []string) []string {
+	seen := map[string]bool{}
+	merged := []string{}
+	for _, name := range append(append([]string{}, a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			merged = append(merged, name)
+		}
+	}
+	sort.Strings(merged)
+
+	return merged
+}
+
+// Renders a unified diff of a file only gaining lines, given by the line they are inserted before.
+func insertionPatch(path string, content string, insertions map[int][]string) string {
+	if len(insertions) == 0 {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	points := []int{}
+	for line := range insertions {
+		points = append(points, line)
+	}
+	sort.Ints(points)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+
+	inserted := 0
+	for i := 0; i < len(points); {
+		start := points[i] - patchContextLines
+		if start < 1 {
+			start = 1
+		}
+
+		// Insertions whose context overlaps share a hunk.
+		j := i
+		end := points[j] + patchContextLines - 1
+		for j+1 < len(points) && points[j+1]-patchContextLines <= end+1 {
+			j++
+			end = points[j] + patchContextLines - 1
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		added := 0
+		var hunk strings.Builder
+		for line := start; line <= end; line++ {
+			for _, l := range insertions[line] {
+				hunk.WriteString("+" + l + "\n")
+				added++
+			}
+			hunk.WriteString(" " + lines[line-1] + "\n")
+		}
+
+		oldCount := end - start + 1
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n%s", start, oldCount, start+inserted, oldCount+added, hunk.String())
+
+		inserted += added
+		i = j + 1
+	}
+
+	return b.String()
+}
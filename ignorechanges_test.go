@@ -0,0 +1,116 @@
+package terraconf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Builds a state with a counted resource in the root module and a resource of the same name in the
+// root module and in a module.
+func ignoreChangesState() *terraform.State {
+	instance := func(id string) *terraform.ResourceState {
+		return terraconftest.NewResourceState("aws_instance", id, map[string]interface{}{"instance_type": "t3.micro"})
+	}
+
+	return &terraform.State{
+		Version: terraform.StateVersion,
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.web": instance("i-web"),
+					"aws_eip.nat.0":    terraconftest.NewResourceState("aws_eip", "eipalloc-0", map[string]interface{}{"vpc": "true"}),
+					"aws_eip.nat.1":    terraconftest.NewResourceState("aws_eip", "eipalloc-1", map[string]interface{}{"vpc": "true"}),
+				},
+			},
+			{
+				Path:      []string{"root", "app"},
+				Resources: map[string]*terraform.ResourceState{"aws_instance.web": instance("i-app")},
+			},
+		},
+	}
+}
+
+// Suggestions are added to the block of the resource in the syntax of the options, in the file the
+// manifest records, once for all instances of a counted resource.
+func TestIgnoreChangesPatch(t *testing.T) {
+	drifts := []*terraconf.PlanDrift{
+		{Address: "module.app.aws_instance.web", Actions: []string{"update"}, Attributes: []*terraconf.PlanAttributeDrift{{Name: "tags"}, {Name: "instance_type"}}},
+		{Address: "aws_eip.nat[0]", Actions: []string{"update"}, Attributes: []*terraconf.PlanAttributeDrift{{Name: "tags"}}},
+		{Address: "aws_eip.nat[1]", Actions: []string{"update"}, Attributes: []*terraconf.PlanAttributeDrift{{Name: "public_ipv4_pool"}}},
+		{Address: "aws_instance.db", Actions: []string{"create"}, Attributes: []*terraconf.PlanAttributeDrift{{Name: "ami"}}},
+	}
+
+	suggestions := terraconf.SuggestIgnoreChanges(drifts)
+	if len(suggestions) != 3 {
+		t.Fatalf("expected no suggestion for the created resource, got %d suggestions", len(suggestions))
+	}
+
+	// Context lines keep their leading space, so the blank line between blocks is a single space.
+	expectedPatch := func(module string, root string) string {
+		return strings.Join([]string{
+			"--- a/app.tf",
+			"+++ b/app.tf",
+			"@@ -1,3 +1,7 @@",
+			` resource "aws_instance" "web" {`,
+			`   instance_type = "t3.micro"`,
+			"+",
+			"+  lifecycle {",
+			"+    ignore_changes = " + module,
+			"+  }",
+			" }",
+			"--- a/root.tf",
+			"+++ b/root.tf",
+			"@@ -1,6 +1,10 @@",
+			` resource "aws_eip" "nat" {`,
+			"   count = 2",
+			`   vpc   = "true"`,
+			"+",
+			"+  lifecycle {",
+			"+    ignore_changes = " + root,
+			"+  }",
+			" }",
+			" ",
+			` resource "aws_instance" "web" {`,
+			"",
+		}, "\n")
+	}
+
+	expected := map[terraconf.Syntax]string{
+		terraconf.SyntaxHCL1: expectedPatch(`["instance_type", "tags"]`, `["public_ipv4_pool", "tags"]`),
+		terraconf.SyntaxHCL2: expectedPatch(`[instance_type, tags]`, `[public_ipv4_pool, tags]`),
+	}
+
+	for syntax, patch := range expected {
+		opts := terraconf.NewOptions()
+		opts.Syntax = syntax
+		opts.CollapseCounts = true
+		g := terraconf.NewGenerator(opts)
+
+		files, manifest, err := g.OutputFilesWithManifest(ignoreChangesState(), terraconf.GroupByModule())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := g.IgnoreChangesPatch(files, manifest, suggestions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != patch {
+			t.Errorf("syntax %v: expected patch:\n%s\ngot:\n%s", syntax, patch, actual)
+		}
+
+		// Without a manifest, the resource in the module can't be told from the root one.
+		actual, err = g.IgnoreChangesPatch(files, nil, suggestions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(actual, "app.tf") || !strings.Contains(actual, "root.tf") {
+			t.Errorf("syntax %v: expected only root.tf to be patched without a manifest, got:\n%s", syntax, actual)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package terraconf
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Directory, relative to the generated config, include files are written to unless
+// Options.IncludeFileDir is set.
+const DefaultIncludeFileDir = "files"
+
+// The function referencing an include file in the generated config.
+type IncludeFunction int
+
+const (
+	// Reference include files with file(), which reads them literally.
+	IncludeWithFile IncludeFunction = iota
+	// Reference include files with `templatefile(path, {})`, escaping template sequences in the files so
+	// the value is unchanged, e.g. to replace parts of the value with template variables later. Falls
+	// back to file() for HCL1 which has no templatefile().
+	IncludeWithTemplateFile
+)
+
+// The contents of an attribute moved out of the generated config into a separate file.
+type IncludeFile struct {
+	// Slash separated path relative to the generated config, e.g. `files/aws_instance.web.user_data`.
+	Path    string
+	Content string
+
+	ResourceType string
+	ResourceName string
+	Attribute    string
+}
+
+// Renders a string attribute longer than Options.IncludeFileThreshold bytes as a reference to an include
+// file holding its value and records the file, returning "" for other attributes. Content that isn't
+// valid UTF-8 stays inline since file() only reads UTF-8.
+func (g *Generator) includeFileAttributeString(resourceType string, resourceName string, attrName string, attrRawVal interface{}) string {
+	v, ok := attrRawVal.(string)
+	if !ok || g.opts.IncludeFileThreshold <= 0 || len(v) <= g.opts.IncludeFileThreshold || !utf8.ValidString(v) {
+		return ""
+	}
+
+	dir := g.opts.IncludeFileDir
+	if dir == "" {
+		dir = DefaultIncludeFileDir
+	}
+
+	parts := []string{}
+	for _, part := range []string{resourceType, resourceName, attrName} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	f := &IncludeFile{
+		Path:         path.Join(dir, strings.Join(parts, tfStateKeyDelimiter)),
+		Content:      v,
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		Attribute:    attrName,
+	}
+
+	if g.opts.Syntax != SyntaxHCL2 {
+		g.recordIncludeFile(f)
+		return fmt.Sprintf("%s = \"${file(\"${path.module}/%s\")}\"\n", attrName, f.Path)
+	}
+
+	if g.opts.IncludeFunction == IncludeWithTemplateFile {
+		f.Path += ".tftpl"
+		f.Content = strings.Replace(f.Content, "${", "$${", -1)
+		f.Content = strings.Replace(f.Content, "%{", "%%{", -1)
+		g.recordIncludeFile(f)
+		return fmt.Sprintf("%s = templatefile(\"${path.module}/%s\", {})\n", attrName, f.Path)
+	}
+
+	g.recordIncludeFile(f)
+	return fmt.Sprintf("%s = file(\"${path.module}/%s\")\n", attrName, f.Path)
+}
+
+func (g *Generator) recordIncludeFile(f *IncludeFile) {
+	g.includesMu.Lock()
+	g.includes[f.Path] = f
+	g.includesMu.Unlock()
+}
+
+// Returns the include files referenced by the config generated so far, sorted by path. The contents
+// must be written unchanged, without converting line endings, for the config to reproduce the state.
+func (g *Generator) IncludeFiles() []*IncludeFile {
+	g.includesMu.Lock()
+	defer g.includesMu.Unlock()
+
+	files := []*IncludeFile{}
+	for _, f := range g.includes {
+		files = append(files, f)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
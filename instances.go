@@ -0,0 +1,410 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Identifies a resource instance within a module, as used for the keys of modules[].resources in the
+// state file, e.g. `aws_instance.web`, `aws_instance.web.0` or `aws_instance.web["a"]`.
+type ResourceKey struct {
+	Data bool
+	Type string
+	Name string
+
+	// Index is nil for single instance resources, an int for count and a string for for_each.
+	Index interface{}
+}
+
+func ParseResourceKey(key string) (*ResourceKey, error) {
+	k := &ResourceKey{}
+	rest := key
+
+	// Bracketed index, e.g. `aws_instance.web[0]` or `aws_instance.web["a"]`. String keys may contain
+	// periods so the index has to be split off before splitting on the delimiter.
+	if i := strings.Index(rest, "["); i >= 0 {
+		if !strings.HasSuffix(rest, "]") {
+			return nil, fmt.Errorf("invalid resource key %q: unterminated index", key)
+		}
+
+		rawIndex := rest[i+1 : len(rest)-1]
+		rest = rest[:i]
+
+		if s, err := strconv.Unquote(rawIndex); err == nil {
+			k.Index = s
+		} else if n, err := strconv.Atoi(rawIndex); err == nil {
+			k.Index = n
+		} else {
+			return nil, fmt.Errorf("invalid resource key %q: index must be a number or quoted string", key)
+		}
+	}
+
+	parts := strings.Split(rest, tfStateKeyDelimiter)
+	if parts[0] == "data" {
+		k.Data = true
+		parts = parts[1:]
+	}
+
+	switch len(parts) {
+	case 2:
+	case 3:
+		// Legacy count index, e.g. `aws_instance.web.0`.
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || k.Index != nil {
+			return nil, fmt.Errorf("invalid resource key %q: unexpected %q", key, parts[2])
+		}
+		k.Index = n
+	default:
+		return nil, fmt.Errorf("invalid resource key %q: expected type and name", key)
+	}
+
+	k.Type = parts[0]
+	k.Name = parts[1]
+
+	return k, nil
+}
+
+// Returns the key in terraform address form, e.g. `aws_instance.web[0]`.
+func (k *ResourceKey) String() string {
+	s := k.GroupKey()
+
+	switch v := k.Index.(type) {
+	case int:
+		s += fmt.Sprintf("[%d]", v)
+	case string:
+		s += fmt.Sprintf("[%s]", strconv.Quote(v))
+	}
+
+	return s
+}
+
+// Returns the key without the instance index, shared by all instances of a resource.
+func (k *ResourceKey) GroupKey() string {
+	s := k.Type + tfStateKeyDelimiter + k.Name
+	if k.Data {
+		s = "data" + tfStateKeyDelimiter + s
+	}
+
+	return s
+}
+
+// Returns the config block type of the resource, either resource or data.
+func (k *ResourceKey) BlockType() string {
+	if k.Data {
+		return "data"
+	}
+
+	return "resource"
+}
+
+// Returns a resource name unique to this instance, e.g. `web_0` or `web_a`.
+func (k *ResourceKey) InstanceName() string {
+	if k.Index == nil {
+		return k.Name
+	}
+
+	return sanitizeResourceID(fmt.Sprintf("%s_%v", k.Name, k.Index))
+}
+
+type ResourceInstance struct {
+	// Set for instances listed by Generator.StateResources.
+	ModulePath []string
+
+	Key   *ResourceKey
+	State *terraform.ResourceState
+}
+
+// Returns the full terraform address of the instance.
+func (r *ResourceInstance) Address() string {
+	return ResourceAddress(r.ModulePath, r.Key)
+}
+
+// Lists the resource instances of every module in a state, sorted by address. Data resources are only
+// included when the IncludeDataResources option is set, and resources rejected by the ResourceFilter
+// option are left out.
+func (g *Generator) StateResources(state *terraform.State) ([]*ResourceInstance, error) {
+	return g.filterStateResources(state, true)
+}
+
+// Lists the resource instances of a state the ResourceFilter option accepts, or those it rejects.
+func (g *Generator) filterStateResources(state *terraform.State, accepted bool) ([]*ResourceInstance, error) {
+	resources := []*ResourceInstance{}
+
+	for _, module := range state.Modules {
+		for key, resourceState := range module.Resources {
+			k, err := ParseResourceKey(key)
+			if err != nil {
+				return nil, err
+			}
+
+			if k.Data && !g.opts.IncludeDataResources {
+				continue
+			}
+
+			r := &ResourceInstance{ModulePath: module.Path, Key: k, State: resourceState}
+			if keep := g.opts.ResourceFilter == nil || g.opts.ResourceFilter(r); keep != accepted {
+				continue
+			}
+
+			resources = append(resources, r)
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Address() < resources[j].Address()
+	})
+
+	return resources, nil
+}
+
+// All instances of a single resource, sorted by index.
+type ResourceInstanceGroup struct {
+	Key       string
+	Data      bool
+	Type      string
+	Name      string
+	Instances []*ResourceInstance
+}
+
+// Groups the resources of a module (keyed as in modules[].resources) by resource, so count and
+// for_each instances can be rendered together. Groups are sorted by key for deterministic output.
+// Data resources are only included when the IncludeDataResources option is set.
+func (g *Generator) GroupResourceInstances(resources map[string]*terraform.ResourceState) ([]*ResourceInstanceGroup, error) {
+	groupsByKey := map[string]*ResourceInstanceGroup{}
+
+	for rawKey, state := range resources {
+		k, err := ParseResourceKey(rawKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if k.Data && !g.opts.IncludeDataResources {
+			continue
+		}
+
+		group, ok := groupsByKey[k.GroupKey()]
+		if !ok {
+			group = &ResourceInstanceGroup{Key: k.GroupKey(), Data: k.Data, Type: k.Type, Name: k.Name}
+			groupsByKey[k.GroupKey()] = group
+		}
+
+		group.Instances = append(group.Instances, &ResourceInstance{Key: k, State: state})
+	}
+
+	groups := []*ResourceInstanceGroup{}
+	for _, group := range groupsByKey {
+		sort.Slice(group.Instances, func(i, j int) bool {
+			return lessInstanceIndex(group.Instances[i].Key.Index, group.Instances[j].Key.Index)
+		})
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Key < groups[j].Key
+	})
+
+	return groups, nil
+}
+
+// Orders instances without an index first, then count indexes numerically, then for_each keys.
+func lessInstanceIndex(a interface{}, b interface{}) bool {
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case int:
+			return 1
+		}
+		return 2
+	}
+
+	if rank(a) != rank(b) {
+		return rank(a) < rank(b)
+	}
+
+	switch v := a.(type) {
+	case int:
+		return v < b.(int)
+	case string:
+		return v < b.(string)
+	}
+
+	return false
+}
+
+// Renders all instances of a resource in index order like OutputFiles, see Options.CollapseCounts.
+// Instances that cannot be generated are left out and returned as GenerationFailures along with the
+// config of the others.
+func (g *Generator) ResourceGroupConfig(group *ResourceInstanceGroup) (string, error) {
+	var s strings.Builder
+	err := g.renderBlocks(group.Instances, nil, func(resources []*ResourceInstance, block string) error {
+		if s.Len() > 0 {
+			s.WriteString(g.blockSeparator())
+		}
+		s.WriteString(block)
+
+		return nil
+	})
+
+	return s.String(), err
+}
+
+// Resource instances rendered as a single block: one instance, or every instance of a counted
+// resource collapsed into a block with count.
+type renderUnit struct {
+	instances []*ResourceInstance
+
+	// Resolved attributes of the instances, or the errors resolving them, for the leading instances
+	// resolved to compare them.
+	resolved [][]*resolvedAttribute
+	errs     []error
+
+	// Body shared by collapsed instances, without count.
+	countBody string
+}
+
+func (u *renderUnit) counted() bool {
+	return u.countBody != ""
+}
+
+// Splits resources sorted by address, or the instances of a single resource, into the units they
+// are rendered in. With the CollapseCounts option, the instances of a counted resource that render
+// identically are collapsed into one unit.
+func (g *Generator) renderUnits(resources []*ResourceInstance) []*renderUnit {
+	units := []*renderUnit{}
+
+	for start := 0; start < len(resources); {
+		// Instances of a resource are adjacent when sorted by address.
+		end := start + 1
+		if g.opts.CollapseCounts {
+			for end < len(resources) && sameResource(resources[start], resources[end]) {
+				end++
+			}
+		}
+
+		if end-start == 1 {
+			units = append(units, &renderUnit{instances: resources[start:end]})
+			start = end
+			continue
+		}
+
+		unit := g.countUnit(resources[start:end])
+		if unit.counted() {
+			units = append(units, unit)
+			start = end
+			continue
+		}
+
+		// Instances keep what was resolved to compare them, in index order.
+		for i, r := range unit.instances {
+			single := &renderUnit{instances: []*ResourceInstance{r}}
+			if i < len(unit.resolved) {
+				single.resolved = unit.resolved[i : i+1]
+				single.errs = unit.errs[i : i+1]
+			}
+			units = append(units, single)
+		}
+		start = end
+	}
+
+	return units
+}
+
+// Whether two resource instances are instances of the same resource.
+func sameResource(a *ResourceInstance, b *ResourceInstance) bool {
+	return a.Key.GroupKey() == b.Key.GroupKey() && ModuleAddress(a.ModulePath) == ModuleAddress(b.ModulePath)
+}
+
+// Tries to collapse the instances of a resource into a block with count, which requires the instances
+// to be exactly the count indexes 0..n-1 and to render identically. Instances carrying comments of
+// their own, e.g. policy violations, costs or source annotations, or rendered by a template are never
+// collapsed.
+func (g *Generator) countUnit(instances []*ResourceInstance) *renderUnit {
+	instances = append([]*ResourceInstance(nil), instances...)
+	sort.Slice(instances, func(i, j int) bool {
+		return lessInstanceIndex(instances[i].Key.Index, instances[j].Key.Index)
+	})
+
+	unit := &renderUnit{instances: instances}
+	if !isCountGroup(instances) || !g.canCollapse(instances[0].Key.Type) {
+		return unit
+	}
+
+	bodies := []string{}
+	for _, r := range instances {
+		if r.State.Primary == nil {
+			return unit
+		}
+	}
+	for _, r := range instances {
+		resolved, err := g.resolveAttributes(r.State)
+		unit.resolved = append(unit.resolved, resolved)
+		unit.errs = append(unit.errs, err)
+		if err != nil {
+			return unit
+		}
+		bodies = append(bodies, g.resourceBody(r.State, g.configName(r), resolved))
+	}
+
+	for _, body := range bodies[1:] {
+		if body != bodies[0] {
+			return unit
+		}
+	}
+
+	unit.countBody = bodies[0]
+	return unit
+}
+
+// Whether the instances of a resource type can be collapsed into a block with count.
+func (g *Generator) canCollapse(resourceType string) bool {
+	return g.opts.RegoPolicy == nil && g.opts.Pricing == nil && !g.opts.AnnotateSource && g.opts.ResourceTemplates[resourceType] == nil
+}
+
+// Renders the block with count of collapsed instances, named after their resource with the remap
+// rules applied.
+func (g *Generator) countBlock(unit *renderUnit) (string, error) {
+	r := unit.instances[0]
+	_, name := g.remapName(r, r.Key.Name)
+	body := fmt.Sprintf("count = %d\n", len(unit.instances)) + unit.countBody
+
+	return g.resourceBlock(r.Key.BlockType(), r.Key.Type, name, body)
+}
+
+// Whether instances are exactly the count indexes 0..n-1, assuming they are sorted.
+func isCountGroup(instances []*ResourceInstance) bool {
+	for i, instance := range instances {
+		if index, ok := instance.Key.Index.(int); !ok || index != i {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the terraform address of a legacy module path, e.g. `module.vpc` for ["root", "vpc"], or an
+// empty string for the root module.
+func ModuleAddress(path []string) string {
+	parts := []string{}
+	for i, name := range path {
+		if i == 0 && name == "root" {
+			continue
+		}
+		parts = append(parts, "module"+tfStateKeyDelimiter+name)
+	}
+
+	return strings.Join(parts, tfStateKeyDelimiter)
+}
+
+// Returns the full terraform address of a resource instance within a module.
+func ResourceAddress(modulePath []string, k *ResourceKey) string {
+	if moduleAddress := ModuleAddress(modulePath); moduleAddress != "" {
+		return moduleAddress + tfStateKeyDelimiter + k.String()
+	}
+
+	return k.String()
+}
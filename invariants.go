@@ -0,0 +1,153 @@
+package terraconf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// How invariants are emitted.
+type InvariantStyle int
+
+const (
+	// A lifecycle postcondition within each resource. Postconditions are used rather than
+	// preconditions since only postconditions can refer to the resource's own attributes.
+	InvariantPostconditions InvariantStyle = iota
+	// Top level check blocks, rendered separately by Generator.CheckBlocksString.
+	InvariantChecks
+)
+
+// An attribute whose state value is asserted in generated config, e.g. as a guardrail when handing the
+// config to another team.
+type AttributeInvariant struct {
+	Attribute string
+
+	// Only assert the value up to and including the first occurrence of the separator, e.g. "." to
+	// assert the instance type family `m5.` of `m5.large`. Empty asserts the whole value.
+	PrefixSeparator string
+}
+
+// Returns the condition and error message asserting an invariant for a state value, referring to the
+// resource as ref, or false when the value can't be asserted.
+func (g *Generator) invariantCondition(inv *AttributeInvariant, ref string, v interface{}) (string, string, bool) {
+	if !IsPrimitive(v) {
+		return "", "", false
+	}
+	if _, ok := v.(InterpolatedString); ok {
+		return "", "", false
+	}
+
+	attrRef := ref + tfStateKeyDelimiter + inv.Attribute
+
+	if s, ok := v.(string); ok && inv.PrefixSeparator != "" {
+		i := strings.Index(s, inv.PrefixSeparator)
+		if i < 0 {
+			return "", "", false
+		}
+		prefix := s[:i+len(inv.PrefixSeparator)]
+
+		return fmt.Sprintf("startswith(%s, %s)", attrRef, g.valueString(prefix)),
+			fmt.Sprintf("%s must start with %s as recorded in state.", inv.Attribute, prefix), true
+	}
+
+	return fmt.Sprintf("%s == %s", attrRef, g.valueString(v)),
+		fmt.Sprintf("%s must be %v as recorded in state.", inv.Attribute, v), true
+}
+
+// Whether any invariant only asserts a prefix, which conditions check with startswith.
+func (g *Generator) hasPrefixInvariants() bool {
+	for _, invariants := range g.opts.Invariants {
+		for _, inv := range invariants {
+			if inv.PrefixSeparator != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns the invariants for a resource type, those for AllResourceTypes first.
+func (g *Generator) typeInvariants(resourceType string) []*AttributeInvariant {
+	invariants := append([]*AttributeInvariant{}, g.opts.Invariants[AllResourceTypes]...)
+	if resourceType != AllResourceTypes {
+		invariants = append(invariants, g.opts.Invariants[resourceType]...)
+	}
+
+	return invariants
+}
+
+// Renders the lifecycle block with a postcondition for every invariant of the resource, unformatted.
+// Invariants are only emitted for HCL2 since HCL1 has no conditions.
+func (g *Generator) lifecycleString(state *terraform.ResourceState) string {
+	if g.opts.Syntax != SyntaxHCL2 || g.opts.InvariantStyle != InvariantPostconditions || state.Primary == nil {
+		return ""
+	}
+
+	attrs := expandAttributes(state.Primary.Attributes)
+
+	var b strings.Builder
+	for _, inv := range g.typeInvariants(state.Type) {
+		condition, message, ok := g.invariantCondition(inv, "self", attrs[inv.Attribute])
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(&b, "postcondition {\ncondition = %s\nerror_message = %s\n}\n", condition, g.valueString(message))
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	return "\nlifecycle {\n" + b.String() + "}\n"
+}
+
+// Renders a check block for every invariant of the given resources when the InvariantChecks style is
+// set, named after the resource and attribute, e.g. `web_instance_type`.
+func (g *Generator) CheckBlocksString(resources []*ResourceInstance) string {
+	if g.opts.Syntax != SyntaxHCL2 || g.opts.InvariantStyle != InvariantChecks {
+		return ""
+	}
+
+	// Resources are generated under their instance name with the remap rules applied, or indexed when
+	// collapsed into a block with count, see HCLExporter.
+	refs := map[*ResourceInstance]*ResourceKey{}
+	for _, unit := range g.renderUnits(resources) {
+		for _, r := range unit.instances {
+			_, refs[r] = g.unitKey(r, unit.counted())
+		}
+	}
+
+	var b strings.Builder
+	for _, r := range resources {
+		if r.State.Primary == nil {
+			continue
+		}
+
+		ref := refs[r]
+
+		attrs := expandAttributes(r.State.Primary.Attributes)
+		for _, inv := range g.typeInvariants(r.Key.Type) {
+			condition, message, ok := g.invariantCondition(inv, ref.String(), attrs[inv.Attribute])
+			if !ok {
+				continue
+			}
+
+			name := sanitizeGroupName(g.configName(r) + "_" + inv.Attribute)
+			fmt.Fprintf(&b, "check %q {\nassert {\ncondition = %s\nerror_message = %s\n}\n}\n\n", name, condition, g.valueString(message))
+		}
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	s, err := g.format(b.String())
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
@@ -0,0 +1,140 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const irVersion = 1
+
+// The resources of a generation run after transformers, excludes, defaults and rules have been
+// applied, so other tools can consume the normalized values without parsing the generated HCL.
+type IR struct {
+	Version   int           `json:"version"`
+	Resources []*IRResource `json:"resources"`
+}
+
+type IRResource struct {
+	// Address of the resource in the state it was generated from.
+	Address  string `json:"address"`
+	Mode     string `json:"mode"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	ID       string `json:"id"`
+	Provider string `json:"provider,omitempty"`
+
+	// Module names from the root of the module the resource is generated in, with the remap rules of
+	// the options applied like Name. Empty for the root module.
+	Module []string `json:"module,omitempty"`
+
+	// Count index of the instance in the state and the name of the block with count its instances are
+	// rendered as when collapsed, see Options.CollapseCounts. Unset for resources without count.
+	Index     interface{} `json:"index,omitempty"`
+	CountName string      `json:"count_name,omitempty"`
+
+	// Attributes in output order.
+	Attributes   []*IRAttribute `json:"attributes"`
+	Dependencies []string       `json:"depends_on,omitempty"`
+}
+
+type IRAttribute struct {
+	Name string `json:"name"`
+	// Expanded value, unset for secrets.
+	Value interface{} `json:"value,omitempty"`
+	// One of "state", "default", "comment" or "secret".
+	Source string `json:"source"`
+	// Note of the comment rule, for commented attributes.
+	Note string `json:"note,omitempty"`
+	// Variable a stubbed secret is replaced with.
+	Variable string `json:"variable,omitempty"`
+}
+
+var irAttributeSources = map[attributeSource]string{
+	attributeFromState:   "state",
+	attributeFromDefault: "default",
+	attributeComment:     "comment",
+	attributeSecret:      "secret",
+}
+
+// Returns the intermediate representation of a resource as it would be generated, named after its
+// state key with the remap rules applied. Returns nil for resources without a primary instance, and
+// fails when a hook fails.
+func (g *Generator) ResourceIR(r *ResourceInstance) (*IRResource, error) {
+	if r.State.Primary == nil {
+		return nil, nil
+	}
+
+	resolved, err := g.resolveAttributes(r.State)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.resourceIR(r, resolved), nil
+}
+
+// Describes a resource instance with a primary instance from its resolved attributes.
+func (g *Generator) resourceIR(r *ResourceInstance, resolved []*resolvedAttribute) *IRResource {
+	module, name := g.remapResource(r)
+
+	ir := g.stateIR(r.State, r.Address(), r.Key.BlockType(), name, resolved)
+	ir.Module = module
+	if _, ok := r.Key.Index.(int); ok {
+		ir.Index = r.Key.Index
+		_, ir.CountName = g.remapName(r, r.Key.Name)
+	}
+
+	return ir
+}
+
+// Describes a resource state with a primary instance rendered under the given name from its resolved
+// attributes.
+func (g *Generator) stateIR(state *terraform.ResourceState, address string, mode string, name string, resolved []*resolvedAttribute) *IRResource {
+	ir := &IRResource{
+		Address:      address,
+		Mode:         mode,
+		Type:         state.Type,
+		Name:         name,
+		ID:           state.Primary.ID,
+		Provider:     state.Provider,
+		Attributes:   []*IRAttribute{},
+		Dependencies: state.Dependencies,
+	}
+
+	for _, attr := range resolved {
+		irAttr := &IRAttribute{Name: attr.name, Value: attr.value, Source: irAttributeSources[attr.source], Note: attr.note}
+		if attr.source == attributeSecret {
+			irAttr.Variable = secretVariableName(state.Type, name, attr.name)
+		}
+
+		ir.Attributes = append(ir.Attributes, irAttr)
+	}
+
+	return ir
+}
+
+// Writes the intermediate representation of resources as indented JSON, skipping resources without a
+// primary instance. Nothing is written when a hook fails.
+func (g *Generator) WriteIR(resources []*ResourceInstance, w io.Writer) error {
+	ir := &IR{Version: irVersion, Resources: []*IRResource{}}
+
+	for _, r := range resources {
+		resourceIR, err := g.ResourceIR(r)
+		if err != nil {
+			return fmt.Errorf("%s: %s", r.Address(), err)
+		}
+		if resourceIR != nil {
+			ir.Resources = append(ir.Resources, resourceIR)
+		}
+	}
+
+	b, err := json.MarshalIndent(ir, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,434 @@
+package terraconf
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Name of the journal written to the output directory by WriteResumable.
+const JournalFileName = ".terraconf-journal"
+
+// A resource recorded as written by an earlier, possibly interrupted, run. Entries without an address
+// record the run itself: the creation of File, or without File the hash of the options the output is
+// rendered with.
+type JournalEntry struct {
+	Address string `json:"address"`
+	// Hash of the resource state the block was generated from, see ResourceStateHash, or of the options.
+	Hash string `json:"hash"`
+	// Output file the block was appended to, relative to the output directory, and its size after.
+	File string `json:"file"`
+	End  int64  `json:"end"`
+}
+
+// An append-only record of the resources written to an output directory, one JSON entry per line, so
+// that an interrupted run can resume without writing any resource twice.
+type Journal struct {
+	f           *os.File
+	entries     map[string]*JournalEntry
+	files       map[string]bool
+	optionsHash string
+}
+
+// Opens the journal at path, creating it if needed and reading the entries of earlier runs. A line
+// cut off by an interruption is dropped so new entries start on a line of their own.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{f: f, entries: map[string]*JournalEntry{}, files: map[string]bool{}}
+
+	// Size of the complete lines read.
+	var valid int64
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		entry := &JournalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			break
+		}
+		j.add(entry)
+		valid += int64(len(scanner.Bytes())) + 1
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading journal: %s", err)
+	}
+
+	info, err := f.Stat()
+	if err == nil && info.Size() > valid {
+		err = f.Truncate(valid)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (j *Journal) add(entry *JournalEntry) {
+	switch {
+	case entry.Address != "":
+		j.entries[entry.Address] = entry
+		j.files[entry.File] = true
+	case entry.File != "":
+		j.files[entry.File] = true
+	default:
+		j.optionsHash = entry.Hash
+	}
+}
+
+// Returns the entry of a resource address, or nil if the resource hasn't been written.
+func (j *Journal) Entry(address string) *JournalEntry {
+	return j.entries[address]
+}
+
+// Whether the journal records the creation of a file, relative to the output directory, by an earlier
+// run.
+func (j *Journal) Created(file string) bool {
+	return j.files[file]
+}
+
+// Returns the hash of the options recorded by an earlier run, or an empty string if none is recorded.
+func (j *Journal) OptionsHash() string {
+	return j.optionsHash
+}
+
+// Drops every entry, e.g. to start over after removing the files of earlier runs.
+func (j *Journal) Reset() error {
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+
+	j.entries = map[string]*JournalEntry{}
+	j.files = map[string]bool{}
+	j.optionsHash = ""
+
+	return nil
+}
+
+// Appends entries to the journal, each on its own line, in a single write so the entries of a block
+// holding several resources are recorded together.
+func (j *Journal) Record(entries ...*JournalEntry) error {
+	var b []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		b = append(append(b, line...), '\n')
+	}
+
+	if _, err := j.f.Write(b); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		j.add(entry)
+	}
+
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// Hashes everything about a resource state that affects its generated config: the type, provider,
+// dependencies and primary instance attributes and meta data.
+func ResourceStateHash(state *terraform.ResourceState) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%q %q %q\n", state.Type, state.Provider, state.Dependencies)
+
+	if state.Primary != nil {
+		fmt.Fprintf(h, "%q\n", state.Primary.ID)
+
+		for _, attrs := range []map[string]string{state.Primary.Attributes, metaStrings(state.Primary.Meta)} {
+			keys := []string{}
+			for k := range attrs {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				fmt.Fprintf(h, "%q=%q\n", k, attrs[k])
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Hashes the options rendering depends on, so resuming with other options is noticed. Functions, e.g.
+// transformers, and compiled policies and templates can't be compared and only count by whether they
+// are set.
+func optionsHash(o *Options) string {
+	h := sha1.New()
+	hashValue(h, reflect.ValueOf(o).Elem())
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashValue(w io.Writer, v reflect.Value) {
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case time.Time:
+			fmt.Fprintf(w, "%q", x.UTC().Format(time.RFC3339Nano))
+			return
+		case *regexp.Regexp:
+			if x != nil {
+				fmt.Fprintf(w, "%q", x.String())
+				return
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan:
+		fmt.Fprintf(w, "%t", !v.IsNil())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return
+		}
+		hashValue(w, v.Elem())
+	case reflect.Struct:
+		// Structs of other packages are opaque, e.g. compiled policies.
+		if v.Type().PkgPath() != reflect.TypeOf(Options{}).PkgPath() {
+			fmt.Fprintf(w, "%s{}", v.Type())
+			return
+		}
+
+		fmt.Fprint(w, "{")
+		for i := 0; i < v.NumField(); i++ {
+			fmt.Fprintf(w, "%s:", v.Type().Field(i).Name)
+			hashValue(w, v.Field(i))
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "}")
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprintf("%#v", keys[i]) < fmt.Sprintf("%#v", keys[j])
+		})
+
+		fmt.Fprint(w, "{")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%#v:", k)
+			hashValue(w, v.MapIndex(k))
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "}")
+	case reflect.Slice, reflect.Array:
+		fmt.Fprint(w, "[")
+		for i := 0; i < v.Len(); i++ {
+			hashValue(w, v.Index(i))
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, "]")
+	default:
+		fmt.Fprintf(w, "%#v", v)
+	}
+}
+
+func metaStrings(meta map[string]interface{}) map[string]string {
+	s := map[string]string{}
+	for k, v := range meta {
+		s[k] = fmt.Sprintf("%#v", v)
+	}
+
+	return s
+}
+
+// Appends the resources of a state to one file per group in dir, rendered like OutputFiles, recording
+// each written resource in JournalFileName so an interrupted run can be resumed by calling WriteResumable
+// again. Resources in the journal are skipped and anything appended after the last journaled resource
+// of a file is truncated, so no resource is written twice. Only files the journal records as created
+// by WriteResumable are touched; nothing is written when another file is in the way. Resuming with
+// other options starts over, removing the files earlier runs created. Fails when a journaled resource
+// has changed since it was written; remove the journal and the output files to start over. The
+// journal is left in place once every resource is written. Resources that cannot be generated are
+// skipped and returned as GenerationFailures.
+func (g *Generator) WriteResumable(dir string, state *terraform.State, strategy GroupingStrategy) error {
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	journal, err := OpenJournal(filepath.Join(dir, JournalFileName))
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	if hash := optionsHash(g.opts); journal.OptionsHash() != hash {
+		// Journals of earlier versions record no options and are resumed as they are.
+		if journal.OptionsHash() != "" {
+			for file := range journal.files {
+				if err := os.Remove(filepath.Join(dir, filepath.FromSlash(file))); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			if err := journal.Reset(); err != nil {
+				return err
+			}
+		}
+
+		if err := journal.Record(&JournalEntry{Hash: hash}); err != nil {
+			return err
+		}
+	}
+
+	// Files are decided up front since chunking depends on the size of the whole group.
+	groups := g.groupResources(resources, strategy)
+	groupNames := []string{}
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+
+	ends := map[string]int64{}
+	for _, r := range resources {
+		entry := journal.Entry(r.Address())
+		if entry == nil {
+			continue
+		}
+
+		if entry.Hash != ResourceStateHash(r.State) {
+			return fmt.Errorf("%s changed since it was written, remove %s to start over", r.Address(), filepath.Join(dir, JournalFileName))
+		}
+		if entry.End > ends[entry.File] {
+			ends[entry.File] = entry.End
+		}
+	}
+
+	foreign := []string{}
+	pending := map[string][]*ResourceInstance{}
+	for _, group := range groupNames {
+		file := filepath.ToSlash(GroupFilePath("", group))
+
+		for _, r := range groups[group] {
+			if journal.Entry(r.Address()) == nil {
+				pending[group] = append(pending[group], r)
+			}
+		}
+		if len(pending[group]) == 0 || journal.Created(file) {
+			continue
+		}
+
+		_, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(file)))
+		if err == nil {
+			foreign = append(foreign, file)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("refusing to overwrite files not generated by terraconf: %s", strings.Join(foreign, ", "))
+	}
+
+	files := map[string]*os.File{}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	failures := GenerationFailures{}
+	progress := g.newProgress(len(resources))
+
+	for _, group := range groupNames {
+		file := filepath.ToSlash(GroupFilePath("", group))
+
+		for _, r := range groups[group] {
+			if journal.Entry(r.Address()) != nil {
+				progress.Step(r.Address(), nil)
+			}
+		}
+
+		err := g.renderBlocks(pending[group], progress, func(rs []*ResourceInstance, block string) error {
+			f, ok := files[file]
+			if !ok {
+				// Recorded first, so a file created by an interrupted run is known as generated.
+				if !journal.Created(file) {
+					if err := journal.Record(&JournalEntry{File: file}); err != nil {
+						return err
+					}
+				}
+
+				var err error
+				f, err = openResumableFile(filepath.Join(dir, filepath.FromSlash(file)), ends[file])
+				if err != nil {
+					return err
+				}
+				files[file] = f
+			}
+
+			end, err := f.Seek(0, io.SeekEnd)
+			if err != nil {
+				return err
+			}
+			if end > 0 {
+				block = g.blockSeparator() + block
+			}
+
+			n, err := io.WriteString(f, block)
+			if err != nil {
+				return err
+			}
+
+			entries := []*JournalEntry{}
+			for _, r := range rs {
+				entries = append(entries, &JournalEntry{Address: r.Address(), Hash: ResourceStateHash(r.State), File: file, End: end + int64(n)})
+			}
+
+			return journal.Record(entries...)
+		})
+		if err != nil {
+			groupFailures, ok := err.(GenerationFailures)
+			if !ok {
+				return err
+			}
+			failures = append(failures, groupFailures...)
+		}
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+
+	return nil
+}
+
+// Opens an output file for appending, dropping anything written after end by an interrupted run.
+func openResumableFile(path string, end int64) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,126 @@
+package terraconf_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jmseaton/terraconf"
+)
+
+// Reads the output files of a directory, without the journal.
+func readOutputDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, info := range infos {
+		if info.Name() == terraconf.JournalFileName {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[info.Name()] = string(b)
+	}
+
+	return files
+}
+
+// Renders the files WriteResumable is expected to write.
+func expectedOutputFiles(t *testing.T, opts *terraconf.Options) map[string]string {
+	t.Helper()
+
+	files, err := terraconf.NewGenerator(opts).OutputFiles(mapHeavyState(), terraconf.GroupByType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{}
+	for _, f := range files {
+		expected[f.Path] = f.Content
+	}
+
+	return expected
+}
+
+func TestWriteResumable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terraconf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := terraconf.NewOptions()
+	expected := expectedOutputFiles(t, opts)
+
+	if err := terraconf.NewGenerator(opts).WriteResumable(dir, mapHeavyState(), terraconf.GroupByType()); err != nil {
+		t.Fatal(err)
+	}
+	if files := readOutputDir(t, dir); !reflect.DeepEqual(files, expected) {
+		t.Fatal("written files differ from OutputFiles")
+	}
+
+	// A run interrupted after writing the last resource, but before journaling it, is resumed by
+	// writing the resource again.
+	journalPath := filepath.Join(dir, terraconf.JournalFileName)
+	journal, err := ioutil.ReadFile(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(journal), "\n"), "\n")
+	if err := ioutil.WriteFile(journalPath, []byte(strings.Join(lines[:len(lines)-1], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := terraconf.NewGenerator(opts).WriteResumable(dir, mapHeavyState(), terraconf.GroupByType()); err != nil {
+		t.Fatal(err)
+	}
+	if files := readOutputDir(t, dir); !reflect.DeepEqual(files, expected) {
+		t.Fatal("resuming changed the files")
+	}
+
+	// Other options render everything again.
+	opts.Syntax = terraconf.SyntaxHCL2
+	expected = expectedOutputFiles(t, opts)
+
+	if err := terraconf.NewGenerator(opts).WriteResumable(dir, mapHeavyState(), terraconf.GroupByType()); err != nil {
+		t.Fatal(err)
+	}
+	if files := readOutputDir(t, dir); !reflect.DeepEqual(files, expected) {
+		t.Fatal("written files differ from OutputFiles with the new options")
+	}
+}
+
+// Files in the way that WriteResumable didn't create are never touched.
+func TestWriteResumableForeignFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terraconf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	foreign := "# written by hand\n"
+	path := filepath.Join(dir, "aws_security_group.tf")
+	if err := ioutil.WriteFile(path, []byte(foreign), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = terraconf.NewGenerator(terraconf.NewOptions()).WriteResumable(dir, mapHeavyState(), terraconf.GroupByType())
+	if err == nil || !strings.Contains(err.Error(), "aws_security_group.tf") {
+		t.Fatalf("expected an error naming aws_security_group.tf, got %v", err)
+	}
+
+	files := readOutputDir(t, dir)
+	if len(files) != 1 || files["aws_security_group.tf"] != foreign {
+		t.Errorf("expected only the untouched file, got %v", files)
+	}
+}
@@ -0,0 +1,67 @@
+package terraconf
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Attributes of the kubernetes, kubectl and helm providers holding manifests, set by NewOptions.
+var KubernetesManifestAttributes = map[string][]string{
+	"kubernetes_manifest": {"manifest"},
+	"kubectl_manifest":    {"yaml_body"},
+	"helm_release":        {"values"},
+}
+
+// Renders an attribute holding a manifest. Multi-line strings, or lists of them for HCL2, are rendered
+// as heredocs and objects as an object expression, or `yamldecode(...)` with ManifestYAML, rather than
+// as nested blocks. Other values are rendered as usual.
+func (g *Generator) manifestAttributeString(attrName string, attrRawVal interface{}, block *SchemaBlock) string {
+	switch v := attrRawVal.(type) {
+	case string:
+		if heredoc, ok := g.heredocString(v); ok {
+			return fmt.Sprintf("%s = %s\n", attrName, heredoc)
+		}
+	case []interface{}:
+		// The HCL1 printer can't format heredocs within lists.
+		if g.opts.Syntax != SyntaxHCL2 || len(v) == 0 {
+			break
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s = [\n", attrName)
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return g.schemaAttributeString(attrName, attrRawVal, block)
+			}
+
+			heredoc, ok := g.heredocString(str)
+			if !ok {
+				heredoc = g.valueString(str)
+			}
+			b.WriteString(heredoc + ",\n")
+		}
+		b.WriteString("]\n")
+
+		return b.String()
+	case map[string]interface{}:
+		if len(v) == 0 {
+			break
+		}
+
+		if g.opts.Syntax == SyntaxHCL2 && g.opts.ManifestStyle == ManifestYAML {
+			b, err := yaml.Marshal(v)
+			if err == nil {
+				if heredoc, ok := g.heredocString(string(b)); ok {
+					return fmt.Sprintf("%s = yamldecode(%s\n)\n", attrName, heredoc)
+				}
+			}
+		}
+
+		return fmt.Sprintf("%s = %s\n", attrName, g.valueString(v))
+	}
+
+	return g.schemaAttributeString(attrName, attrRawVal, block)
+}
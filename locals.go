@@ -0,0 +1,174 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// A complex value shared by several resources, extracted into a local value.
+type LocalValue struct {
+	Name      string
+	Attribute string
+	Value     interface{}
+
+	// Number of resources the value was found on.
+	Occurrences int
+}
+
+// The local values extracted from a state by ExtractLocals.
+type LocalsExtraction struct {
+	Locals []*LocalValue
+
+	generator  *Generator
+	namesByKey map[string]string
+}
+
+// Finds complex values (non-empty maps and lists, e.g. the same tags or ingress rules) set for the
+// same attribute on at least minOccurrences resources of the state. Locals are named after their
+// attribute, with a numeric suffix when an attribute has several, ordered by occurrences.
+// Attributes rendered as nested blocks, according to the provider schemas or, without them, lists of
+// maps and maps of complex values, are skipped since blocks cannot be set from a reference.
+func (g *Generator) ExtractLocals(state *terraform.State, minOccurrences int) *LocalsExtraction {
+	candidates := map[string]*LocalValue{}
+
+	for _, module := range state.Modules {
+		for _, resource := range module.Resources {
+			if resource.Primary == nil {
+				continue
+			}
+
+			attrs := g.transformAttributes(resource.Type, expandAttributes(resource.Primary.Attributes))
+			for attrName, v := range attrs {
+				key, ok := g.localValueKey(resource.Type, attrName, v)
+				if !ok {
+					continue
+				}
+
+				candidate, ok := candidates[key]
+				if !ok {
+					candidate = &LocalValue{Attribute: attrName, Value: v}
+					candidates[key] = candidate
+				}
+				candidate.Occurrences++
+			}
+		}
+	}
+
+	keys := []string{}
+	for key, candidate := range candidates {
+		if candidate.Occurrences >= minOccurrences {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := candidates[keys[i]], candidates[keys[j]]
+		if a.Attribute != b.Attribute {
+			return a.Attribute < b.Attribute
+		}
+		if a.Occurrences != b.Occurrences {
+			return a.Occurrences > b.Occurrences
+		}
+		return keys[i] < keys[j]
+	})
+
+	perAttribute := map[string]int{}
+	for _, key := range keys {
+		perAttribute[candidates[key].Attribute]++
+	}
+
+	e := &LocalsExtraction{generator: g, namesByKey: map[string]string{}}
+	seen := map[string]int{}
+
+	for _, key := range keys {
+		local := candidates[key]
+
+		local.Name = local.Attribute
+		if perAttribute[local.Attribute] > 1 {
+			seen[local.Attribute]++
+			local.Name = fmt.Sprintf("%s_%d", local.Attribute, seen[local.Attribute])
+		}
+
+		e.Locals = append(e.Locals, local)
+		e.namesByKey[key] = local.Name
+	}
+
+	return e
+}
+
+// Returns a transformer replacing extracted values with references to their local, to be added for
+// AllResourceTypes to the options of the generator rendering the resources.
+func (e *LocalsExtraction) Transformer() ResourceTransformer {
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		for attrName, v := range attrs {
+			key, ok := e.generator.localValueKey(resourceType, attrName, v)
+			if !ok {
+				continue
+			}
+
+			if name, ok := e.namesByKey[key]; ok {
+				attrs[attrName] = InterpolatedString(fmt.Sprintf("${local.%s}", name))
+			}
+		}
+
+		return attrs
+	}
+}
+
+// Renders the locals block declaring the extracted values.
+func (e *LocalsExtraction) LocalsString() string {
+	if len(e.Locals) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("locals {\n")
+	for _, local := range e.Locals {
+		fmt.Fprintf(&b, "%s = %s\n", local.Name, e.generator.valueString(local.Value))
+	}
+	b.WriteString("}\n")
+
+	s, err := e.generator.format(b.String())
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// Identifies a complex attribute value, returning false for values that are not extracted.
+func (g *Generator) localValueKey(resourceType string, attrName string, v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "", false
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	// Stubbed secrets must not be copied into locals.
+	if _, ok := g.typeConfig(resourceType).secrets[attrName]; ok && g.opts.StubSecrets {
+		return "", false
+	}
+
+	if asBlock, _ := hcl2IsBlock(attrName, v, g.resourceSchemaBlock(resourceType)); asBlock {
+		return "", false
+	}
+
+	// Maps are marshalled with sorted keys, giving a canonical form of the value.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+
+	return attrName + "\x00" + string(b), true
+}
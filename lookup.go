@@ -0,0 +1,134 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Finds the resource instances whose ID is id, e.g. `i-0abc123`, sorted by address. Several resources
+// can share an ID, e.g. a resource and the policy or association attached to it.
+func (g *Generator) FindResourcesByID(state *terraform.State, id string) ([]*ResourceInstance, error) {
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	found := []*ResourceInstance{}
+	for _, r := range resources {
+		if r.State.Primary != nil && r.State.Primary.ID == id {
+			found = append(found, r)
+		}
+	}
+
+	return found, nil
+}
+
+// Renders the config of just the resources with the given ID, see FindResourcesByID, e.g. to look a
+// resource up in a large state while investigating an incident. Fails when no resource has the ID.
+func (g *Generator) ResourceConfigByID(state *terraform.State, id string) (string, error) {
+	resources, err := g.FindResourcesByID(state, id)
+	if err != nil {
+		return "", err
+	}
+	if len(resources) == 0 {
+		return "", fmt.Errorf("no resource with ID %q", id)
+	}
+
+	var b strings.Builder
+	err = g.renderBlocks(resources, nil, func(_ []*ResourceInstance, block string) error {
+		if b.Len() > 0 {
+			b.WriteString(g.blockSeparator())
+		}
+		b.WriteString(block)
+
+		return nil
+	})
+
+	return b.String(), err
+}
+
+// An attribute value found by SearchState.
+type SearchMatch struct {
+	Address string
+	// Path of the value within the resource, e.g. `ingress[0].cidr_blocks[1]` or `tags["Name"]`.
+	Path  string
+	Value string
+}
+
+// Searches the attribute values of every resource in a state for a pattern, e.g. `10\.0\.1\.` to find
+// what uses a subnet. Attributes are expanded the same way as for generation so matches are reported
+// by the path of the value in the config rather than its flatmap key. Matches are in address order and
+// in attribute order within a resource. Values of secrets are matched but reported as `(sensitive)`.
+func (g *Generator) SearchState(state *terraform.State, pattern *regexp.Regexp) ([]*SearchMatch, error) {
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*SearchMatch{}
+	for _, r := range resources {
+		if r.State.Primary == nil {
+			continue
+		}
+
+		tc := g.typeConfig(r.Key.Type)
+		attrs := expandAttributes(r.State.Primary.Attributes)
+		for _, attrName := range sortedKeys(attrs) {
+			_, secret := tc.secrets[attrName]
+			searchValue(attrs[attrName], attrName, pattern, func(path string, value string) {
+				if secret {
+					value = "(sensitive)"
+				}
+				matches = append(matches, &SearchMatch{Address: r.Address(), Path: path, Value: value})
+			})
+		}
+	}
+
+	return matches, nil
+}
+
+// Calls fn with the path and value of every primitive within v matching the pattern, in order.
+func searchValue(v interface{}, path string, pattern *regexp.Regexp, fn func(path string, value string)) {
+	switch v := v.(type) {
+	case nil:
+	case []interface{}:
+		for i, item := range v {
+			searchValue(item, fmt.Sprintf("%s[%d]", path, i), pattern, fn)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			keyPath := path + tfStateKeyDelimiter + k
+			if !hclIdentifier.MatchString(k) {
+				keyPath = fmt.Sprintf("%s[%q]", path, k)
+			}
+			searchValue(v[k], keyPath, pattern, fn)
+		}
+	default:
+		if s := fmt.Sprint(v); pattern.MatchString(s) {
+			fn(path, s)
+		}
+	}
+}
+
+// Renders search matches as a table with one row per matching value.
+func SearchMatchesString(matches []*SearchMatch) string {
+	if len(matches) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ADDRESS\tPATH\tVALUE")
+	for _, m := range matches {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Address, m.Path, m.Value)
+	}
+	w.Flush()
+
+	return b.String()
+}
@@ -1,25 +1,68 @@
 package terraconf
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/hashicorp/terraform/terraform"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/terraform/flatmap"
-	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/hashicorp/terraform/terraform"
 )
 
 const (
 	tfStateKeyDelimiter = "."
+
+	defaultProvenanceComment = "# default (not in state)"
 )
 
+// Used by the package level rendering functions, which render with the default options.
+var defaultGenerator = NewGenerator(nil)
+
+// Values rendered for attributes missing from the state, either the value itself or a
+// *ConditionalDefault.
 type ResourceDefaults map[string]interface{}
+
 type ResourceExcludes map[string]struct{}
+type ResourceRules map[string]AttributeRule
+type ResourceSecrets map[string]struct{}
+
+// Excludes an attribute only when its value matches, e.g. a `kms_key_id` holding the ARN of the
+// account's default key. Values other than strings are matched in their state form, e.g. `true`.
+type ValueExclude struct {
+	Attribute string
+	Pattern   *regexp.Regexp
+}
+
+// A string value rendered without escaping interpolation sequences, e.g. a default of
+// `${aws_vpc.main.id}` to link resources. Plain strings are always escaped.
+type InterpolatedString string
+
+// AttributeAction determines how a single attribute is emitted in the generated config.
+type AttributeAction int
 
+const (
+	// Emit the attribute as a comment containing its state value, so reviewers keep the
+	// context of a value without it affecting plans.
+	AttributeActionComment AttributeAction = iota + 1
+)
+
+type AttributeRule struct {
+	Action AttributeAction
+
+	// Optional note appended to the emitted comment, e.g. "computed".
+	Note string
+}
+
+// Makes an ID a valid resource name, replacing every character not allowed in HCL identifiers with an
+// underscore.
 func sanitizeResourceID(id string) string {
-	return strings.Replace(id, tfStateKeyDelimiter, "_", -1)
+	return sanitizeIdentifier(id, "_")
 }
 
 func uniqueAttributeNames(attrMap map[string]string) map[string]bool {
@@ -35,43 +78,109 @@ func uniqueAttributeNames(attrMap map[string]string) map[string]bool {
 
 func IsPrimitive(rawValue interface{}) bool {
 	switch rawValue.(type) {
-	case string:
+	case string, InterpolatedString, []byte:
 		return true
 	case bool:
 		return true
-	case int:
+	case int, int8, int16, int32, int64:
 		return true
-	case int32:
+	case uint, uint8, uint16, uint32, uint64:
 		return true
-	case int64:
+	case float32, float64, json.Number:
 		return true
 	}
 
 	return false
 }
 
+// Reports values that cannot be rendered, e.g. structs or nil, in a value and the lists and maps it
+// holds, see Options.StrictTypes.
+func checkValueType(path string, rawValue interface{}) error {
+	switch v := rawValue.(type) {
+	case []interface{}:
+		for i, item := range v {
+			if err := checkValueType(fmt.Sprintf("%s%s%d", path, tfStateKeyDelimiter, i), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			if err := checkValueType(path+tfStateKeyDelimiter+k, v[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !IsPrimitive(rawValue) {
+		return fmt.Errorf("%s has a value of unsupported type %T", path, rawValue)
+	}
+
+	return nil
+}
+
 func PrimitiveValueToString(rawValue interface{}) string {
+	return defaultGenerator.primitiveValueString(rawValue)
+}
+
+func (g *Generator) primitiveValueString(rawValue interface{}) string {
 	switch v := rawValue.(type) {
 	case string:
-		// TODO: is it valid to always quote hcl strings?
-		return strconv.Quote(v)
+		return quoteHCLString(g.escapeTemplates(v))
+	case InterpolatedString:
+		return quoteHCLString(string(v))
+	case []byte:
+		return quoteHCLString(g.escapeTemplates(string(v)))
 	case bool:
 		return fmt.Sprintf("\"%t\"", v)
-	case int:
-		return fmt.Sprintf("%d", v)
-	case int32:
-		return fmt.Sprintf("%d", v)
-	case int64:
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 		return fmt.Sprintf("%d", v)
+	case float32:
+		return g.floatString(float64(v), 32)
+	case float64:
+		return g.floatString(v, 64)
+	case json.Number:
+		return g.jsonNumberString(v)
 	}
 
-	// TODO: handle unknown type
+	// Unsupported types fail to plan rather than silently rendering a wrong value. Options.StrictTypes
+	// reports them before rendering instead.
 	return "unknown"
 }
 
+// Renders a float with the FloatPrecision option. Integral values are rendered without a decimal point,
+// since decoding JSON, e.g. of defaults or newer states, turns every number into a float.
+func (g *Generator) floatString(f float64, bitSize int) string {
+	if f == math.Trunc(f) && !math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'f', 0, bitSize)
+	}
+
+	return strconv.FormatFloat(f, 'f', g.opts.FloatPrecision, bitSize)
+}
+
+// Renders a number decoded from JSON. Integers are kept as they are, since they may be too large for
+// a float to represent exactly.
+func (g *Generator) jsonNumberString(n json.Number) string {
+	if !strings.ContainsAny(n.String(), ".eE") {
+		return n.String()
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return n.String()
+	}
+
+	return g.floatString(f, 64)
+}
+
 func PrimitiveAttributeToString(k string, rawValue interface{}) string {
+	return defaultGenerator.primitiveAttributeString(k, rawValue)
+}
+
+func (g *Generator) primitiveAttributeString(k string, rawValue interface{}) string {
 	// TODO: how to handle empty string values? need more expressive way to exclude attributes?
-	v := PrimitiveValueToString(rawValue)
+	v := g.primitiveValueString(rawValue)
 	if k == "date" && v == "\"\"" {
 		return ""
 	}
@@ -80,59 +189,137 @@ func PrimitiveAttributeToString(k string, rawValue interface{}) string {
 }
 
 func PrimitiveAttributeListToString(attrName string, list []interface{}) string {
-	s := fmt.Sprintf("%s = [\n", attrName)
+	return defaultGenerator.primitiveAttributeListString(attrName, list)
+}
+
+func (g *Generator) primitiveAttributeListString(attrName string, list []interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s = [\n", attrName)
 
 	for _, v := range list {
-		s += fmt.Sprintf("%s,", PrimitiveValueToString(v))
+		b.WriteString(g.primitiveValueString(v))
+		b.WriteString(",")
 	}
 
-	s += "]\n"
+	b.WriteString("]\n")
 
-	return s
+	return b.String()
 }
 
 func MapAttributeToString(attrName string, m map[string]interface{}) string {
-	s := fmt.Sprintf("%s {\n", attrName)
+	return defaultGenerator.mapAttributeString(attrName, m)
+}
+
+func (g *Generator) mapAttributeString(attrName string, m map[string]interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s {\n", attrName)
 
-	for k, v := range m {
+	// Keys are sorted so maps such as tags render in a stable order.
+	for _, k := range sortedKeys(m) {
+		v := m[k]
 		if IsPrimitive(v) {
-			s += PrimitiveAttributeToString(k, v)
+			b.WriteString(g.primitiveAttributeString(hclKeyString(k), v))
 		} else {
-			s += AttributeToString(k, v)
+			b.WriteString(g.attributeString(hclKeyString(k), v))
 		}
 	}
 
-	s += "}\n"
+	b.WriteString("}\n")
 
-	return s
+	return b.String()
 }
 
 func AttributeToString(attrName string, attrRawVal interface{}) string {
-	s := ""
+	return defaultGenerator.attributeString(attrName, attrRawVal)
+}
 
+func (g *Generator) attributeString(attrName string, attrRawVal interface{}) string {
 	switch v := attrRawVal.(type) {
 	case []interface{}:
-		// TODO: option to include empty list/set, may cause issues when state has them
-
+		// Empty lists/sets are skipped by default since state has them for attributes never set.
+		if len(v) == 0 && g.opts.EmptyValues == IncludeEmptyValues {
+			return fmt.Sprintf("%s = []\n", attrName)
+		}
 		if len(v) > 0 && IsPrimitive(v[0]) {
-			s += PrimitiveAttributeListToString(attrName, v)
-		} else {
-			for _, item := range v {
-				s += MapAttributeToString(attrName, item.(map[string]interface{}))
-			}
+			return g.primitiveAttributeListString(attrName, v)
 		}
-	case map[string]interface{}:
-		// TODO: option to skip empty maps, may cause issues when state has them
 
-		if len(v) > 0 {
-			s += MapAttributeToString(attrName, v)
+		var b strings.Builder
+		for _, item := range v {
+			b.WriteString(g.mapAttributeString(attrName, item.(map[string]interface{})))
+		}
+		return b.String()
+	case map[string]interface{}:
+		// Empty maps are skipped by default since state has them for attributes never set.
+		if len(v) > 0 || g.opts.EmptyValues == IncludeEmptyValues {
+			return g.mapAttributeString(attrName, v)
 		}
+		return ""
 	default:
 		// Assuming primitive type string, bool, int, etc ...
-		s += PrimitiveAttributeToString(attrName, v)
+		return g.primitiveAttributeString(attrName, v)
 	}
+}
 
-	return s
+// Renders the attribute as HCL and comments out every line, e.g. `# availability_zone = "us-east-1a" (computed)`.
+func CommentAttributeToString(attrName string, attrRawVal interface{}, note string) string {
+	return defaultGenerator.commentAttributeString(attrName, attrRawVal, note)
+}
+
+func (g *Generator) commentAttributeString(attrName string, attrRawVal interface{}, note string) string {
+	rendered := strings.TrimSuffix(g.schemaAttributeString(attrName, attrRawVal, nil), "\n")
+	if rendered == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for i, line := range strings.Split(rendered, "\n") {
+		b.WriteString("# " + line)
+		if i == 0 && note != "" {
+			fmt.Fprintf(&b, " (%s)", note)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// Expands the flatmapped state attributes into their top level values.
+func expandAttributes(flatAttrs map[string]string) map[string]interface{} {
+	// Expanding scans every key with the attribute's prefix, so the keys are split by attribute first
+	// rather than scanning all keys of the resource for every attribute.
+	byAttribute := map[string]map[string]string{}
+	for k, v := range flatAttrs {
+		attrName := strings.SplitN(k, tfStateKeyDelimiter, 2)[0]
+
+		attrKeys, ok := byAttribute[attrName]
+		if !ok {
+			attrKeys = map[string]string{}
+			byAttribute[attrName] = attrKeys
+		}
+		attrKeys[k] = v
+	}
+
+	attrs := map[string]interface{}{}
+	for attrName, attrKeys := range byAttribute {
+		attrs[attrName] = expandFlatValue(attrKeys, attrName)
+	}
+
+	return attrs
+}
+
+// Marks a rendered default value, inline for single line attributes and on the line above for blocks
+// and lists spanning multiple lines.
+func annotateDefaultString(rendered string) string {
+	if rendered == "" {
+		return ""
+	}
+
+	if strings.Count(rendered, "\n") > 1 {
+		return defaultProvenanceComment + "\n" + rendered
+	}
+
+	return strings.TrimSuffix(rendered, "\n") + " " + defaultProvenanceComment + "\n"
 }
 
 // Given a ResourceState, overwrite the specified list attribute with the specified values.
@@ -148,10 +335,10 @@ func OverwriteList(state *terraform.ResourceState, attrName string, values inter
 	state.Primary.Attributes = attrs
 }
 
-
 func ResourceAsString(state *terraform.ResourceState) string {
 	attrs := state.Primary.Attributes
-	s := fmt.Sprintf("resource \"%s\" \"%s\" {\n", state.Type, state.Primary.ID)
+	var s strings.Builder
+	fmt.Fprintf(&s, "resource \"%s\" \"%s\" {\n", state.Type, state.Primary.ID)
 
 	// We sort attribute names to make change diffs more consistent and easier to read.
 
@@ -164,21 +351,21 @@ func ResourceAsString(state *terraform.ResourceState) string {
 	sort.Strings(sortedAttrNames)
 
 	for _, attrName := range sortedAttrNames {
-		attrRawVal := flatmap.Expand(attrs, attrName)
-		s += AttributeToString(attrName, attrRawVal)
+		attrRawVal := expandFlatValue(attrs, attrName)
+		s.WriteString(AttributeToString(attrName, attrRawVal))
 	}
 
 	if len(state.Dependencies) > 0 {
-		s += "depends_on = [\n"
+		s.WriteString("depends_on = [\n")
 		for _, v := range state.Dependencies {
-			s += PrimitiveValueToString(v)
+			s.WriteString(PrimitiveValueToString(v) + ",\n")
 		}
-		s += "]\n"
+		s.WriteString("]\n")
 	}
 
-	s += "}\n"
+	s.WriteString("}\n")
 
-	b, err := printer.Format([]byte(s))
+	b, err := formatHCL1([]byte(s.String()))
 	if err != nil {
 		return ""
 	}
@@ -187,72 +374,78 @@ func ResourceAsString(state *terraform.ResourceState) string {
 }
 
 // features:
-//     - sorted output to allow easy diff after running multiple times
-//     - exclude map to exclude computed values
-//     - auto excludes id
-//     - default values allows config to generate correctly when the state doesn't have a value that will trigger change because default
-//     - allow resource linking through interpolation, to let terraform generate correct dependency graph
+//   - sorted output to allow easy diff after running multiple times
+//   - exclude map to exclude computed values
+//   - auto excludes id
+//   - default values allows config to generate correctly when the state doesn't have a value that will trigger change because default
+//   - transformers rewrite attributes per resource type before rendering
+//   - rules to emit attributes as comments holding their state value, e.g. to keep context for excluded values
+//   - allow resource linking through interpolation, to let terraform generate correct dependency graph
+//
 // note:
-//     - depends_on attributes not added since the state file lists calculated dependencies not just user set dependencies, maybe add option to generate
+//   - depends_on attributes not added since the state file lists calculated dependencies not just user set dependencies, maybe add option to generate
+//
+// Renders with the default options besides the given defaults and excludes. Use a Generator for the
+// remaining options, e.g. Options.Rules.
 func ResourceStateToConfigString(state *terraform.ResourceState, defaults ResourceDefaults, excludes ResourceExcludes) string {
-	attrs := state.Primary.Attributes
-
-	// Note: The ID field for an individual resource state may not be safe and may contain periods.
-	// At this point we do not have the safe ID anymore and must sanitize it. The only place the
-	// safe ID exists is in the full state file as the keys of modules[].resources.
-	s := fmt.Sprintf("resource \"%s\" \"%s\" {\n", state.Type, sanitizeResourceID(state.Primary.ID))
+	opts := NewOptions()
+	opts.Defaults = defaults
+	opts.Excludes = excludes
 
-	// The id attribute should always be excluded.
-	excludes["id"] = struct{}{}
-
-	attrNames := uniqueAttributeNames(attrs)
+	s, _ := NewGenerator(opts).ResourceConfig(state)
+	return s
+}
 
-	// Add the default if the attribute doesn't exist in the resource state.
-	for attrName := range defaults {
-		if _, ok := attrNames[attrName]; !ok {
-			attrNames[attrName] = true
-		}
-	}
+// Wraps an unformatted resource body in a resource or data block and formats it.
+func (g *Generator) resourceBlock(blockType string, resourceType string, name string, body string) (string, error) {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%s \"%s\" \"%s\" {\n", blockType, resourceType, name)
+	s.WriteString(body)
+	s.WriteString("}\n")
 
-	// We sort attribute names to make change diffs more consistent and easier to read.
-	sortedAttrNames := []string{}
-	for k := range attrNames {
-		sortedAttrNames = append(sortedAttrNames, k)
+	b, err := g.format(s.String())
+	if err != nil {
+		return "", fmt.Errorf("formatting %s.%s: %s", resourceType, name, err)
 	}
-	sort.Strings(sortedAttrNames)
-
-	for _, attrName := range sortedAttrNames {
-		if _, ok := excludes[attrName]; ok {
-			continue
-		}
 
-		attrRawVal := flatmap.Expand(attrs, attrName)
-
-		useDefault, _ := attrNames[attrName]
-		defaultValue, defaultExists := defaults[attrName]
+	return b, nil
+}
 
-		if useDefault && defaultExists {
-			s += AttributeToString(attrName, defaultValue)
-			continue
-		}
+// Formats generated config with the printer for the generator's syntax in the layout of `terraform fmt`
+// and the format options, using the generator's line endings.
+func (g *Generator) format(s string) (string, error) {
+	if g.opts.Syntax == SyntaxHCL2 {
+		return g.convertNewlines(g.opts.Format.apply(string(hclwrite.Format([]byte(s))))), nil
+	}
 
-		s += AttributeToString(attrName, attrRawVal)
+	b, err := formatHCL1([]byte(s))
+	if err != nil {
+		return "", err
 	}
 
-	if len(state.Dependencies) > 0 {
-		s += "depends_on = [\n"
-		for _, v := range state.Dependencies {
-			s += PrimitiveValueToString(v)
+	return g.convertNewlines(g.opts.Format.apply(string(b))), nil
+}
+
+// Returns the line ending for the generator's NewlineMode.
+func (g *Generator) newline() string {
+	switch g.opts.Newlines {
+	case NewlineCRLF:
+		return "\r\n"
+	case NewlineNative:
+		if runtime.GOOS == "windows" {
+			return "\r\n"
 		}
-		s += "]\n"
 	}
 
-	s += "}\n"
+	return "\n"
+}
 
-	b, err := printer.Format([]byte(s))
-	if err != nil {
-		return ""
+// Rewrites every line ending of s, LF or CRLF, to the generator's line ending.
+func (g *Generator) convertNewlines(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	if nl := g.newline(); nl != "\n" {
+		s = strings.Replace(s, "\n", nl, -1)
 	}
 
-	return string(b)
+	return s
 }
@@ -0,0 +1,131 @@
+package terraconf
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Name of the manifest written alongside generated files.
+const ManifestFileName = "terraconf-manifest.json"
+
+const manifestVersion = 1
+
+// A machine readable listing of generated resources, e.g. for bulk import tooling.
+type Manifest struct {
+	Version   int              `json:"version"`
+	Resources []*ManifestEntry `json:"resources"`
+	// Files written along with the manifest, relative to the output directory and slash separated. Tells
+	// generated files from others, see WriteOutput.
+	Files []string `json:"files,omitempty"`
+}
+
+type ManifestEntry struct {
+	// Address of the resource in the generated config.
+	Address string `json:"address"`
+	// Address of the resource in the state it was generated from.
+	StateAddress string `json:"state_address"`
+	Type         string `json:"type"`
+	ID           string `json:"id"`
+	// File the resource was written to, relative to the output directory. Always uses forward slashes so
+	// manifests written on Windows can be read elsewhere.
+	File string `json:"file"`
+	// Schema version the resource was stored with, when recorded in the state.
+	SchemaVersion *int `json:"schema_version,omitempty"`
+	// Number of deposed instances, which are not generated and are destroyed on the next apply.
+	Deposed int `json:"deposed,omitempty"`
+	// Hash of the rendered block, set by Generator.OutputFilesWithManifest, to tell which files
+	// changed since an earlier run.
+	Hash string `json:"hash,omitempty"`
+}
+
+func NewManifest() *Manifest {
+	return &Manifest{Version: manifestVersion}
+}
+
+// Returns the SHA-1 of a rendered block for ManifestEntry.Hash.
+func blockHash(block string) string {
+	sum := sha1.Sum([]byte(block))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the addresses and block hashes of the resources of every file, in a form comparable
+// between manifests. Files with a resource without hash are left out, since they cannot be compared.
+func (m *Manifest) fileHashes() map[string]string {
+	entries := map[string][]string{}
+	unhashed := map[string]bool{}
+	for _, entry := range m.Resources {
+		if entry.Hash == "" {
+			unhashed[entry.File] = true
+		}
+		entries[entry.File] = append(entries[entry.File], entry.Address+"="+entry.Hash)
+	}
+
+	hashes := map[string]string{}
+	for file, fileEntries := range entries {
+		if unhashed[file] {
+			continue
+		}
+		sort.Strings(fileEntries)
+		hashes[file] = strings.Join(fileEntries, "\n")
+	}
+
+	return hashes
+}
+
+// Records a resource generated into file under the given config address.
+func (m *Manifest) Add(file string, address string, r *ResourceInstance) {
+	entry := &ManifestEntry{
+		Address:      address,
+		StateAddress: r.Address(),
+		Type:         r.Key.Type,
+		File:         filepath.ToSlash(file),
+	}
+
+	if r.State.Primary != nil {
+		entry.ID = r.State.Primary.ID
+	}
+	if version, ok := StateSchemaVersion(r.State); ok {
+		entry.SchemaVersion = &version
+	}
+	entry.Deposed = len(r.State.Deposed)
+
+	m.Resources = append(m.Resources, entry)
+}
+
+// Writes the manifest as indented JSON with entries sorted by file and address.
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	sort.SliceStable(m.Resources, func(i, j int) bool {
+		a, b := m.Resources[i], m.Resources[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Address < b.Address
+	})
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("reading manifest: %s", err)
+	}
+
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+
+	return m, nil
+}
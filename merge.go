@@ -0,0 +1,104 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// A resource found in more than one of the merged states, identified by type and ID.
+type DuplicateResource struct {
+	Type string
+	ID   string
+
+	// Module path and key of the resource in each state it was found in, the first one being kept.
+	Addresses []string
+	// Indexes of the states, in the order given to MergeStates, that contain the resource.
+	Sources []int
+}
+
+// Merges the resources of several states into a single state, e.g. to generate a unified config when
+// consolidating small stacks. Resources are deduplicated by type and ID with the first occurrence kept.
+// When different resources share a key within the same module path, the later one is renamed with the
+// 1-based index of its state as suffix, e.g. `aws_instance.web_2`. Resource states are shared with the
+// inputs, not copied.
+func MergeStates(states ...*terraform.State) (*terraform.State, []*DuplicateResource, error) {
+	merged := &terraform.State{Version: terraform.StateVersion}
+	modulesByPath := map[string]*terraform.ModuleState{}
+
+	seen := map[string]*DuplicateResource{}
+	duplicates := []*DuplicateResource{}
+
+	for i, state := range states {
+		if state == nil {
+			continue
+		}
+
+		for _, module := range state.Modules {
+			modulePath := strings.Join(module.Path, tfStateKeyDelimiter)
+
+			mergedModule, ok := modulesByPath[modulePath]
+			if !ok {
+				mergedModule = &terraform.ModuleState{
+					Path:      module.Path,
+					Resources: map[string]*terraform.ResourceState{},
+				}
+				modulesByPath[modulePath] = mergedModule
+				merged.Modules = append(merged.Modules, mergedModule)
+			}
+
+			// Sorted so renames are deterministic.
+			keys := []string{}
+			for k := range module.Resources {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				resource := module.Resources[key]
+				address := modulePath + tfStateKeyDelimiter + key
+
+				if resource.Primary != nil {
+					identity := resource.Type + "\x00" + resource.Primary.ID
+
+					if dup, ok := seen[identity]; ok {
+						if len(dup.Sources) == 1 {
+							duplicates = append(duplicates, dup)
+						}
+						dup.Addresses = append(dup.Addresses, address)
+						dup.Sources = append(dup.Sources, i)
+						continue
+					}
+
+					seen[identity] = &DuplicateResource{
+						Type:      resource.Type,
+						ID:        resource.Primary.ID,
+						Addresses: []string{address},
+						Sources:   []int{i},
+					}
+				}
+
+				mergedKey := key
+				if _, exists := mergedModule.Resources[mergedKey]; exists {
+					k, err := ParseResourceKey(key)
+					if err != nil {
+						return nil, nil, err
+					}
+
+					k.Name = fmt.Sprintf("%s_%d", k.Name, i+1)
+					mergedKey = k.String()
+
+					if _, exists := mergedModule.Resources[mergedKey]; exists {
+						return nil, nil, fmt.Errorf("cannot merge %s from state %d: %s already exists", address, i+1, mergedKey)
+					}
+				}
+
+				mergedModule.Resources[mergedKey] = resource
+			}
+		}
+	}
+
+	return merged, duplicates, nil
+}
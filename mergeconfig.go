@@ -0,0 +1,87 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Merges regenerated config into an existing file that may have been edited by hand, so config can be
+// regenerated repeatedly. Attribute values of blocks found in both are updated from the generated
+// config in place, keeping comments and the order of attributes in the existing file. Attributes,
+// nested blocks and top level blocks only found in the existing file are kept, those only found in the
+// generated config are appended. Top level blocks are matched by type and labels, nested blocks by
+// type and position among the blocks of the same type. Both must be HCL2 native syntax.
+func MergeConfig(existing []byte, generated []byte, filename string) ([]byte, error) {
+	existingFile, diags := hclwrite.ParseConfig(existing, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing %s: %s", filename, diags.Error())
+	}
+
+	generatedFile, diags := hclwrite.ParseConfig(generated, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing generated config for %s: %s", filename, diags.Error())
+	}
+
+	body := existingFile.Body()
+	existingBlocks := map[string]*hclwrite.Block{}
+	for _, block := range body.Blocks() {
+		existingBlocks[mergeBlockKey(block)] = block
+	}
+
+	for _, block := range generatedFile.Body().Blocks() {
+		if existingBlock, ok := existingBlocks[mergeBlockKey(block)]; ok {
+			mergeBody(existingBlock.Body(), block.Body())
+			continue
+		}
+
+		if len(body.Blocks()) > 0 || len(body.Attributes()) > 0 {
+			body.AppendNewline()
+		}
+		body.AppendBlock(block)
+	}
+
+	return hclwrite.Format(existingFile.Bytes()), nil
+}
+
+// Identifies a top level block by its type and labels, e.g. `resource "aws_instance" "web"`.
+func mergeBlockKey(block *hclwrite.Block) string {
+	return block.Type() + " " + strings.Join(block.Labels(), " ")
+}
+
+// Updates the attributes and nested blocks of an existing body from a generated one.
+func mergeBody(existing *hclwrite.Body, generated *hclwrite.Body) {
+	attrs := generated.Attributes()
+	attrNames := []string{}
+	for attrName := range attrs {
+		attrNames = append(attrNames, attrName)
+	}
+	// Attributes new to the existing body are appended in a stable order.
+	sort.Strings(attrNames)
+
+	for _, attrName := range attrNames {
+		existing.SetAttributeRaw(attrName, attrs[attrName].Expr().BuildTokens(nil))
+	}
+
+	existingBlocks := map[string][]*hclwrite.Block{}
+	for _, block := range existing.Blocks() {
+		existingBlocks[block.Type()] = append(existingBlocks[block.Type()], block)
+	}
+
+	seen := map[string]int{}
+	for _, block := range generated.Blocks() {
+		i := seen[block.Type()]
+		seen[block.Type()]++
+
+		if i < len(existingBlocks[block.Type()]) {
+			mergeBody(existingBlocks[block.Type()][i].Body(), block.Body())
+			continue
+		}
+
+		existing.AppendNewline()
+		existing.AppendBlock(block)
+	}
+}
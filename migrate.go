@@ -0,0 +1,67 @@
+package terraconf
+
+type MigrationAction int
+
+const (
+	// Move the value to a new attribute name, keeping the new attribute if the state already has it.
+	MigrationRename MigrationAction = iota + 1
+	// Remove the attribute, e.g. for arguments removed from the provider.
+	MigrationDrop
+	// Replace the value with the result of the rule's Transform function.
+	MigrationTransform
+)
+
+// A rule upgrading an attribute of an old state to the current provider schema.
+type MigrationRule struct {
+	Attribute string
+	Action    MigrationAction
+
+	// Set for MigrationRename.
+	NewName string
+	// Set for MigrationTransform.
+	Transform func(v interface{}) interface{}
+}
+
+func RenameAttribute(attrName string, newName string) *MigrationRule {
+	return &MigrationRule{Attribute: attrName, Action: MigrationRename, NewName: newName}
+}
+
+func DropAttribute(attrName string) *MigrationRule {
+	return &MigrationRule{Attribute: attrName, Action: MigrationDrop}
+}
+
+func TransformAttribute(attrName string, transform func(v interface{}) interface{}) *MigrationRule {
+	return &MigrationRule{Attribute: attrName, Action: MigrationTransform, Transform: transform}
+}
+
+// Migration rules by resource type, applied in order, e.g.
+//
+//	MigrationRules{
+//		"aws_instance": {DropAttribute("network_interface_id")},
+//	}
+type MigrationRules map[string][]*MigrationRule
+
+func migrationTransformer(rules []*MigrationRule) ResourceTransformer {
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		for _, rule := range rules {
+			v, ok := attrs[rule.Attribute]
+			if !ok {
+				continue
+			}
+
+			switch rule.Action {
+			case MigrationRename:
+				delete(attrs, rule.Attribute)
+				if _, exists := attrs[rule.NewName]; !exists {
+					attrs[rule.NewName] = v
+				}
+			case MigrationDrop:
+				delete(attrs, rule.Attribute)
+			case MigrationTransform:
+				attrs[rule.Attribute] = rule.Transform(v)
+			}
+		}
+
+		return attrs
+	}
+}
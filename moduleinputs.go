@@ -0,0 +1,214 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// An attribute whose value differs between instances of similar modules, turned into a module variable.
+type ModuleVariable struct {
+	Name string
+
+	// Key of the resource within the module, as in modules[].resources, and the attribute.
+	ResourceKey string
+	Attribute   string
+
+	// The value of each module instance, by module address.
+	Values map[string]interface{}
+}
+
+// The module variables inferred by InferModuleInputs.
+type ModuleInputs struct {
+	Variables []*ModuleVariable
+
+	// Module addresses in the order the modules were given.
+	Modules []string
+
+	generator *Generator
+	// Last module path element by module address, naming the module calls.
+	moduleNames map[string]string
+	// Resource keys by resource ID, to tell the resources apart in the transformer.
+	keysByID map[string]string
+	// Variable names by resource key and attribute.
+	names map[string]map[string]string
+}
+
+// Compares the resources of several instances of similar modules, e.g. one stack per environment, to
+// export them as a reusable module. Resources are matched by their key, and every top level primitive
+// attribute whose value differs between the instances becomes a variable named after the resource and
+// attribute, e.g. `web_instance_type`. Resources missing from some of the modules are not compared.
+func (g *Generator) InferModuleInputs(modules []*terraform.ModuleState) (*ModuleInputs, error) {
+	inputs := &ModuleInputs{
+		generator:   g,
+		moduleNames: map[string]string{},
+		keysByID:    map[string]string{},
+		names:       map[string]map[string]string{},
+	}
+
+	// Values of every attribute by resource key and module address.
+	values := map[string]map[string]map[string]interface{}{}
+	counts := map[string]int{}
+
+	for _, module := range modules {
+		moduleAddress := ModuleAddress(module.Path)
+		inputs.Modules = append(inputs.Modules, moduleAddress)
+		if len(module.Path) > 0 {
+			inputs.moduleNames[moduleAddress] = module.Path[len(module.Path)-1]
+		}
+
+		for key, resource := range module.Resources {
+			if resource.Primary == nil {
+				continue
+			}
+
+			if _, err := ParseResourceKey(key); err != nil {
+				return nil, err
+			}
+
+			inputs.keysByID[resource.Primary.ID] = key
+			counts[key]++
+
+			if _, ok := values[key]; !ok {
+				values[key] = map[string]map[string]interface{}{}
+			}
+			values[key][moduleAddress] = g.transformAttributes(resource.Type, expandAttributes(resource.Primary.Attributes))
+		}
+	}
+
+	keys := []string{}
+	for key := range values {
+		if counts[key] == len(modules) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		k, _ := ParseResourceKey(key)
+
+		attrNames := map[string]bool{}
+		for _, attrs := range values[key] {
+			for attrName, v := range attrs {
+				if IsPrimitive(v) && attrName != "id" {
+					attrNames[attrName] = true
+				}
+			}
+		}
+
+		sortedAttrNames := []string{}
+		for attrName := range attrNames {
+			sortedAttrNames = append(sortedAttrNames, attrName)
+		}
+		sort.Strings(sortedAttrNames)
+
+		for _, attrName := range sortedAttrNames {
+			if g.isExcluded(k.Type, attrName) {
+				continue
+			}
+
+			v := &ModuleVariable{
+				Name:        sanitizeIdentifier(k.InstanceName()+"_"+attrName, "_"),
+				ResourceKey: key,
+				Attribute:   attrName,
+				Values:      map[string]interface{}{},
+			}
+
+			// Attributes missing from some of the instances would leave their module calls without a value.
+			differs, missing := false, false
+			for _, moduleAddress := range inputs.Modules {
+				value, ok := values[key][moduleAddress][attrName]
+				if !ok {
+					missing = true
+					break
+				}
+
+				v.Values[moduleAddress] = value
+				if value != v.Values[inputs.Modules[0]] {
+					differs = true
+				}
+			}
+
+			if missing || !differs {
+				continue
+			}
+
+			inputs.Variables = append(inputs.Variables, v)
+			if _, ok := inputs.names[key]; !ok {
+				inputs.names[key] = map[string]string{}
+			}
+			inputs.names[key][attrName] = v.Name
+		}
+	}
+
+	return inputs, nil
+}
+
+// Returns a transformer replacing the attributes that became variables with references to them, to be
+// added for AllResourceTypes to the options of the generator rendering the module's resources.
+func (m *ModuleInputs) Transformer() ResourceTransformer {
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		// The expanded attributes still hold the id, which identifies the resource.
+		id, _ := attrs["id"].(string)
+
+		for attrName, name := range m.names[m.keysByID[id]] {
+			if _, ok := attrs[attrName]; ok {
+				attrs[attrName] = InterpolatedString(fmt.Sprintf("${var.%s}", name))
+			}
+		}
+
+		return attrs
+	}
+}
+
+// Renders the variable blocks declaring the inferred variables.
+func (m *ModuleInputs) VariablesString() string {
+	g := m.generator
+	var b strings.Builder
+
+	for _, v := range m.Variables {
+		fmt.Fprintf(&b, "variable %q {\n", v.Name)
+		fmt.Fprintf(&b, "description = %s\n", g.primitiveValueString(fmt.Sprintf("%s of %s", v.Attribute, v.ResourceKey)))
+		b.WriteString("}\n\n")
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	s, err := g.format(b.String())
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// Renders a module call for every module instance with its value of each variable, named after the
+// last element of the module path and using the given module source.
+func (m *ModuleInputs) ModuleCallsString(source string) string {
+	g := m.generator
+	var b strings.Builder
+
+	for _, moduleAddress := range m.Modules {
+		fmt.Fprintf(&b, "module %q {\n", sanitizeIdentifier(m.moduleNames[moduleAddress], "_"))
+		fmt.Fprintf(&b, "source = %s\n", g.primitiveValueString(source))
+		for _, v := range m.Variables {
+			b.WriteString(g.schemaAttributeString(v.Name, v.Values[moduleAddress], nil))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	s, err := g.format(b.String())
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
@@ -0,0 +1,76 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Name of the file GetStateConfigString renders moved blocks into.
+const MovedFileName = "moved.tf"
+
+// Returns the resource name config is generated with for a resource instance in state, e.g.
+// Generator.NameByID.
+type ResourceNamer func(k *ResourceKey, state *terraform.ResourceState) string
+
+// Names resources by their state key, as ResourceGroupConfig does for instances it does not collapse
+// into a block with count.
+func NameByKey(k *ResourceKey, state *terraform.ResourceState) string {
+	return k.InstanceName()
+}
+
+// A change of address between the state and the generated config.
+type ResourceMove struct {
+	From string
+	To   string
+}
+
+// Returns the moves needed for terraform to adopt the resources of a module under the names given by
+// namer, sorted by their original address. Data resources are skipped since they cannot be moved.
+func ResourceMoves(modulePath []string, resources map[string]*terraform.ResourceState, namer ResourceNamer) ([]*ResourceMove, error) {
+	moves := []*ResourceMove{}
+
+	for key, state := range resources {
+		k, err := ParseResourceKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if k.Data || state.Primary == nil {
+			continue
+		}
+
+		to := &ResourceKey{Type: k.Type, Name: namer(k, state)}
+
+		from := ResourceAddress(modulePath, k)
+		toAddress := ResourceAddress(modulePath, to)
+		if from == toAddress {
+			continue
+		}
+
+		moves = append(moves, &ResourceMove{From: from, To: toAddress})
+	}
+
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].From < moves[j].From
+	})
+
+	return moves, nil
+}
+
+// Renders moved blocks for the given moves. The output is formatted by hand since the HCL printer
+// cannot parse the unquoted references moved blocks require.
+func MovedBlocksString(moves []*ResourceMove) string {
+	var b strings.Builder
+
+	for i, move := range moves {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "moved {\n  from = %s\n  to   = %s\n}\n", move.From, move.To)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package terraconf
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Extracts the part of a resource ID used to name the resource, e.g. the resource part of an ARN.
+type IDNameRule func(id string) string
+
+// ID rules by provider name for the IDNameRules option, which NewOptions leaves empty.
+var ProviderIDNameRules = map[string]IDNameRule{
+	"aws":    ARNResourceName,
+	"google": SelfLinkName,
+}
+
+var invalidIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Returns the resource part of an ARN, e.g. `role/admin` for `arn:aws:iam::123456789012:role/admin`,
+// or the ID unchanged when it is not an ARN.
+func ARNResourceName(id string) string {
+	parts := strings.SplitN(id, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return id
+	}
+
+	return parts[5]
+}
+
+// Returns the last segment of a self link or resource path, e.g. `web` for
+// `projects/p/zones/us-central1-a/instances/web`.
+func SelfLinkName(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// Returns the name of the provider of a resource type, e.g. `aws` for `aws_instance`.
+func providerName(resourceType string) string {
+	return strings.SplitN(resourceType, "_", 2)[0]
+}
+
+// Names a resource after its ID, applying the ID rule of its provider and sanitizing the result.
+func (g *Generator) resourceName(resourceType string, id string) string {
+	name := id
+	if rule, ok := g.opts.IDNameRules[providerName(resourceType)]; ok {
+		// Rules must not leave nothing to name the resource after.
+		if ruleName := rule(id); ruleName != "" {
+			name = ruleName
+		}
+	}
+
+	return sanitizeIdentifier(name, g.opts.NameReplacement)
+}
+
+// Makes s a valid HCL identifier by replacing every character other than letters, digits, underscores
+// and dashes with replacement, which must only contain valid characters itself. Identifiers must start
+// with a letter or underscore, so an underscore is prepended otherwise.
+func sanitizeIdentifier(s string, replacement string) string {
+	if invalidIdentifierChars.MatchString(replacement) {
+		replacement = "_"
+	}
+
+	s = invalidIdentifierChars.ReplaceAllString(s, replacement)
+
+	if s == "" {
+		return "_"
+	}
+
+	if c := s[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_') {
+		s = "_" + s
+	}
+
+	return s
+}
+
+// The name a resource was generated with.
+type ResourceName struct {
+	Type string
+	ID   string
+	Name string
+}
+
+// The names allocated to the resources of a type.
+type allocatedNames struct {
+	byID   map[string]string
+	byName map[string]string
+}
+
+// Returns the name for a resource ID, the same one for every call with the ID. When the sanitized ID
+// is already taken by a different ID of the same type, e.g. `a.b` and `a_b`, a short hash of the ID is
+// appended so the name doesn't depend on which other IDs collide.
+func (g *Generator) allocateResourceName(resourceType string, id string) string {
+	g.namesMu.Lock()
+	defer g.namesMu.Unlock()
+
+	names, ok := g.names[resourceType]
+	if !ok {
+		names = &allocatedNames{byID: map[string]string{}, byName: map[string]string{}}
+		g.names[resourceType] = names
+	}
+
+	if name, ok := names.byID[id]; ok {
+		return name
+	}
+
+	name := g.resourceName(resourceType, id)
+	if _, taken := names.byName[name]; taken {
+		sum := sha1.Sum([]byte(id))
+		base := name + "_" + hex.EncodeToString(sum[:])[:7]
+
+		name = base
+		for i := 2; ; i++ {
+			if _, taken := names.byName[name]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+	}
+
+	names.byID[id] = name
+	names.byName[name] = id
+
+	return name
+}
+
+// Returns the names allocated to the resources generated so far, sorted by type and name. Generate
+// resources in a stable order, e.g. the one of StateResources, for stable names when IDs collide.
+func (g *Generator) ResourceNames() []*ResourceName {
+	g.namesMu.Lock()
+	defer g.namesMu.Unlock()
+
+	names := []*ResourceName{}
+	for resourceType, typeNames := range g.names {
+		for id, name := range typeNames.byID {
+			names = append(names, &ResourceName{Type: resourceType, ID: id, Name: name})
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].Type != names[j].Type {
+			return names[i].Type < names[j].Type
+		}
+		return names[i].Name < names[j].Name
+	})
+
+	return names
+}
+
+// Names resources by ID like ResourceConfig, including the suffixes for colliding IDs, e.g. to
+// generate moved blocks matching the generated config.
+func (g *Generator) NameByID(k *ResourceKey, state *terraform.ResourceState) string {
+	return g.allocateResourceName(k.Type, state.Primary.ID)
+}
@@ -0,0 +1,38 @@
+package terraconf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Provider ID rules are opt-in, so resources named after ARNs keep the name of the whole ID by default.
+func TestIDNameRules(t *testing.T) {
+	role := terraconftest.NewResourceState("aws_iam_role", "arn:aws:iam::123456789012:role/admin", map[string]interface{}{"name": "admin"})
+
+	tests := []struct {
+		name     string
+		rules    map[string]terraconf.IDNameRule
+		expected string
+	}{
+		{"default", nil, `resource "aws_iam_role" "arn_aws_iam__123456789012_role_admin" {`},
+		{"provider rules", terraconf.ProviderIDNameRules, `resource "aws_iam_role" "role_admin" {`},
+	}
+
+	for _, test := range tests {
+		opts := terraconf.NewOptions()
+		for provider, rule := range test.rules {
+			opts.IDNameRules[provider] = rule
+		}
+
+		config, err := terraconf.NewGenerator(opts).ResourceConfig(role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(config, test.expected) {
+			t.Errorf("%s: expected the block to start with %q, got:\n%s", test.name, test.expected, config)
+		}
+	}
+}
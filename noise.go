@@ -0,0 +1,265 @@
+package terraconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// An attribute that renders differently between two snapshots of the same state.
+type NoisyAttribute struct {
+	Type      string
+	Attribute string
+
+	// Number of resources of the type whose attribute changed, out of those in both snapshots.
+	Changed   int
+	Resources int
+}
+
+// Ratio of resources of the type whose attribute changed.
+func (a *NoisyAttribute) Ratio() float64 {
+	if a.Resources == 0 {
+		return 0
+	}
+
+	return float64(a.Changed) / float64(a.Resources)
+}
+
+// Generates config for two snapshots of the same state, e.g. taken a day apart without changes to the
+// infrastructure, and reports the attributes that render differently. Attributes that change for many
+// resources without anyone touching them, such as timestamps or computed counters, are candidates for
+// excludes. Results are sorted by ratio of changed resources, most noisy first.
+func (g *Generator) AnalyzeDiffNoise(before *terraform.State, after *terraform.State) ([]*NoisyAttribute, error) {
+	comparisons, err := g.CompareStates(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeResources, err := resourcesByAddress(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterResources, err := resourcesByAddress(after)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceCounts := map[string]int{}
+	for address, r := range beforeResources {
+		if _, ok := afterResources[address]; ok {
+			resourceCounts[r.Type]++
+		}
+	}
+
+	noisy := map[string]*NoisyAttribute{}
+	for _, comparison := range comparisons {
+		for _, difference := range comparison.Differences {
+			key := comparison.Type + tfStateKeyDelimiter + difference.Name
+
+			a, ok := noisy[key]
+			if !ok {
+				a = &NoisyAttribute{Type: comparison.Type, Attribute: difference.Name, Resources: resourceCounts[comparison.Type]}
+				noisy[key] = a
+			}
+			a.Changed++
+		}
+	}
+
+	attributes := []*NoisyAttribute{}
+	for _, a := range noisy {
+		attributes = append(attributes, a)
+	}
+
+	sort.Slice(attributes, func(i, j int) bool {
+		a, b := attributes[i], attributes[j]
+		if a.Ratio() != b.Ratio() {
+			return a.Ratio() > b.Ratio()
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Attribute < b.Attribute
+	})
+
+	return attributes, nil
+}
+
+// Suggests type excludes for the noisy attributes that changed for at least minRatio of the resources
+// of their type, in the form of Options.TypeExcludes.
+func SuggestExcludes(attributes []*NoisyAttribute, minRatio float64) map[string]ResourceExcludes {
+	excludes := map[string]ResourceExcludes{}
+
+	for _, a := range attributes {
+		if a.Ratio() < minRatio {
+			continue
+		}
+
+		if _, ok := excludes[a.Type]; !ok {
+			excludes[a.Type] = ResourceExcludes{}
+		}
+		excludes[a.Type][a.Attribute] = struct{}{}
+	}
+
+	return excludes
+}
+
+// Renders the noisy attributes as a table, most noisy first.
+func NoiseReportString(attributes []*NoisyAttribute) string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "TYPE\tATTRIBUTE\tCHANGED")
+	for _, a := range attributes {
+		fmt.Fprintf(w, "%s\t%s\t%d/%d (%.0f%%)\n", a.Type, a.Attribute, a.Changed, a.Resources, a.Ratio()*100)
+	}
+	w.Flush()
+
+	return b.String()
+}
+
+// An attribute rendered from state that the provider schema marks optional and computed. The provider
+// fills in such attributes when they are not set, so rendering their current value is likely to cause
+// diffs once the provider computes a different one, e.g. `ipv6_cidr_block` of subnets.
+type ComputedAttribute struct {
+	Type      string
+	Attribute string
+
+	// Number of resources of the type the attribute was rendered for.
+	Resources int
+}
+
+// Checks the attributes rendered for resources against Options.Schemas and reports those that are
+// optional and computed, sorted by type and attribute. Resources without a schema are skipped.
+func (g *Generator) ComputedAttributes(resources []*ResourceInstance) []*ComputedAttribute {
+	computed := map[string]*ComputedAttribute{}
+
+	for _, r := range resources {
+		if r.State.Primary == nil {
+			continue
+		}
+
+		schemaBlock := g.typeConfig(r.Key.Type).schemaBlock
+		if schemaBlock == nil {
+			continue
+		}
+
+		// Resources failing a hook cannot be generated, so they have no attributes to report.
+		resolved, err := g.resolveAttributes(r.State)
+		if err != nil {
+			continue
+		}
+
+		for _, attr := range resolved {
+			if attr.source != attributeFromState {
+				continue
+			}
+
+			schemaAttr, ok := schemaBlock.Attributes[attr.name]
+			if !ok || !schemaAttr.Optional || !schemaAttr.Computed {
+				continue
+			}
+
+			key := r.Key.Type + tfStateKeyDelimiter + attr.name
+			a, ok := computed[key]
+			if !ok {
+				a = &ComputedAttribute{Type: r.Key.Type, Attribute: attr.name}
+				computed[key] = a
+			}
+			a.Resources++
+		}
+	}
+
+	attributes := []*ComputedAttribute{}
+	for _, a := range computed {
+		attributes = append(attributes, a)
+	}
+
+	sort.Slice(attributes, func(i, j int) bool {
+		a, b := attributes[i], attributes[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Attribute < b.Attribute
+	})
+
+	return attributes
+}
+
+// Suggests type excludes for optional and computed attributes, in the form of Options.TypeExcludes.
+func SuggestComputedExcludes(attributes []*ComputedAttribute) map[string]ResourceExcludes {
+	excludes := map[string]ResourceExcludes{}
+
+	for _, a := range attributes {
+		if _, ok := excludes[a.Type]; !ok {
+			excludes[a.Type] = ResourceExcludes{}
+		}
+		excludes[a.Type][a.Attribute] = struct{}{}
+	}
+
+	return excludes
+}
+
+// Renders the optional and computed attributes as a warning table, or an empty string when there are
+// none.
+func ComputedAttributesString(attributes []*ComputedAttribute) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "TYPE\tATTRIBUTE\tRESOURCES")
+	for _, a := range attributes {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", a.Type, a.Attribute, a.Resources)
+	}
+	w.Flush()
+
+	return fmt.Sprintf("%d attributes are optional and computed and may cause diffs, consider excluding them:\n%s", len(attributes), b.String())
+}
+
+// Writes type excludes, e.g. from SuggestComputedExcludes, as indented JSON mapping each type to its sorted
+// attribute names, to be reviewed and read back with ReadExcludesJSON.
+func WriteExcludesJSON(w io.Writer, excludes map[string]ResourceExcludes) error {
+	lists := map[string][]string{}
+	for resourceType, typeExcludes := range excludes {
+		attrNames := []string{}
+		for attrName := range typeExcludes {
+			attrNames = append(attrNames, attrName)
+		}
+		sort.Strings(attrNames)
+		lists[resourceType] = attrNames
+	}
+
+	b, err := json.MarshalIndent(lists, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Reads type excludes written by WriteExcludesJSON, in the form of Options.TypeExcludes.
+func ReadExcludesJSON(r io.Reader) (map[string]ResourceExcludes, error) {
+	lists := map[string][]string{}
+	if err := json.NewDecoder(r).Decode(&lists); err != nil {
+		return nil, fmt.Errorf("reading excludes: %s", err)
+	}
+
+	excludes := map[string]ResourceExcludes{}
+	for resourceType, attrNames := range lists {
+		excludes[resourceType] = ResourceExcludes{}
+		for _, attrName := range attrNames {
+			excludes[resourceType][attrName] = struct{}{}
+		}
+	}
+
+	return excludes, nil
+}
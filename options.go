@@ -0,0 +1,422 @@
+package terraconf
+
+import "time"
+
+// The syntax config is generated in.
+type Syntax int
+
+const (
+	// HCL1 syntax as understood by terraform 0.11, formatted with the HCL1 printer.
+	SyntaxHCL1 Syntax = iota
+	// HCL2 syntax as understood by terraform 0.12 and later, with values built by hclwrite.
+	SyntaxHCL2
+)
+
+// The line endings of generated config.
+type NewlineMode int
+
+const (
+	// Unix line endings, regardless of the platform.
+	NewlineLF NewlineMode = iota
+	// Windows line endings, regardless of the platform.
+	NewlineCRLF
+	// The line endings of the platform terraconf runs on, CRLF on Windows and LF elsewhere.
+	NewlineNative
+)
+
+// How object valued manifest attributes, e.g. the manifest of kubernetes_manifest, are rendered.
+type ManifestStyle int
+
+const (
+	// Render the manifest as an HCL object.
+	ManifestObject ManifestStyle = iota
+	// Render the manifest as `yamldecode(<<EOF ... EOF)`, falling back to an object for HCL1 which has
+	// no functions.
+	ManifestYAML
+)
+
+// Whether empty lists and maps in state are rendered.
+type EmptyValuePolicy int
+
+const (
+	// Skip empty lists and maps, since state records them for attributes that were never set.
+	SkipEmptyValues EmptyValuePolicy = iota
+	// Render empty lists and maps, e.g. to explicitly clear values that have a non-empty default.
+	IncludeEmptyValues
+)
+
+// Options controlling how config is generated from state, used to create a Generator. Create options
+// with NewOptions since the zero value does not use the default float precision.
+type Options struct {
+	// Defaults, excludes and rules applied to every resource type.
+	Defaults ResourceDefaults
+	Excludes ResourceExcludes
+	Rules    ResourceRules
+
+	// Defaults, excludes and rules applied to a single resource type, in addition to the ones above.
+	// Type specific defaults and rules take precedence.
+	TypeDefaults map[string]ResourceDefaults
+	TypeExcludes map[string]ResourceExcludes
+	TypeRules    map[string]ResourceRules
+
+	// Excludes by value applied to every resource type and to a single resource type.
+	ValueExcludes     []*ValueExclude
+	TypeValueExcludes map[string][]*ValueExclude
+
+	// Replace secret attributes with references to variables instead of rendering their state values,
+	// see Generator.SecretVariables. Attributes marked sensitive in the provider schemas are secrets
+	// along with the ones listed in Secrets and TypeSecrets. Every resource instance gets variables of
+	// its own, so counted resources with secrets are rendered as a block per instance even with
+	// CollapseCounts set.
+	StubSecrets bool
+	Secrets     ResourceSecrets
+	TypeSecrets map[string]ResourceSecrets
+
+	// Reference variables for the credentials of providers, see ProviderCredentialArguments, in the
+	// provider blocks rendered by the generator. The variables are returned with those of stubbed
+	// secrets by Generator.SecretVariables.
+	ProviderCredentialVariables bool
+
+	// Mark values that come from defaults rather than the state with a `# default (not in state)`
+	// comment so reviewers know which values were synthesized.
+	AnnotateDefaults bool
+
+	// Prepend every resource with comments tracing it back to its state entry, see
+	// ResourceAnnotationString. Only rendered by the exporters and writers that name resources after
+	// their state key. GeneratedAt is included as the generation time unless zero, which keeps
+	// regenerated output stable.
+	AnnotateSource bool
+	GeneratedAt    time.Time
+
+	// Mark attributes the provider schemas deprecate with a `# DEPRECATED` comment, naming the
+	// replacement when the schema describes one, so they can be cleaned up as the config is adopted.
+	AnnotateDeprecated bool
+
+	// Number of digits after the decimal point used to render floats, or -1 for the smallest number of
+	// digits necessary to represent the value exactly. Floats holding integers, e.g. numbers decoded
+	// from JSON, are always rendered without a decimal point.
+	FloatPrecision int
+
+	// Fail resources holding values of types that cannot be rendered, e.g. structs set by transformers
+	// or nulls, instead of rendering them as `unknown`.
+	StrictTypes bool
+
+	// Render the instances of a counted resource as a single block with count when they render
+	// identically, keeping their addresses in state, instead of a block per instance named after its
+	// index, e.g. `web_0`. Instances with stubbed secrets never render identically, as every instance
+	// references variables named after itself, see StubSecrets. Set by NewOptions.
+	CollapseCounts bool
+
+	// Generate data resources stored in state as data blocks instead of skipping them.
+	IncludeDataResources bool
+
+	// Leaves out the resources it returns false for, e.g. resources that should no longer be managed.
+	// See Generator.RemovedResources to generate the steps for dropping them from the state.
+	ResourceFilter ResourceFilter
+
+	EmptyValues EmptyValuePolicy
+
+	Syntax Syntax
+
+	// Adjustments to the output of the printers, e.g. the indentation width.
+	Format FormatOptions
+
+	// Line endings used for all generated config, so files don't end up with mixed line endings when
+	// generated output is combined with hand written config on Windows.
+	Newlines NewlineMode
+
+	// Render numeric and boolean strings of resources without a provider schema as numbers and
+	// booleans, see CoerceValue. Attributes in CoercionAllowlist are always coerced, those in
+	// CoercionDenylist, which NewOptions sets to DefaultCoercionDenylist, stay strings.
+	CoerceTypes       bool
+	CoercionAllowlist AttributeSet
+	CoercionDenylist  AttributeSet
+
+	// Provider schemas used to tell nested blocks apart from map and object typed arguments, e.g.
+	// rendering `tags = {...}` but `ebs_block_device {...}`. Without schemas maps are rendered as blocks.
+	Schemas *ProviderSchemas
+
+	// Replaces characters not allowed in resource names when naming resources after their ID.
+	// NewOptions sets an underscore.
+	NameReplacement string
+
+	// Rules by provider name, e.g. "aws", extracting the part of an ID used to name a resource before
+	// it is sanitized. Empty by default, as rules rename existing resources, e.g. copy
+	// ProviderIDNameRules in to name AWS resources after the resource part of their ARN.
+	IDNameRules map[string]IDNameRule
+
+	// Attributes by resource type holding large nested manifests, e.g. Kubernetes objects or Helm
+	// values. Multi-line strings are rendered as heredocs and objects according to ManifestStyle.
+	// NewOptions sets KubernetesManifestAttributes.
+	ManifestAttributes map[string][]string
+	ManifestStyle      ManifestStyle
+
+	// Attributes by resource type, or AllResourceTypes, stored base64 encoded in state, e.g. user data.
+	// Values decoding to text are rendered decoded, wrapped in `base64encode(...)`, for HCL2. NewOptions
+	// sets Base64EncodedAttributes.
+	Base64Attributes map[string][]string
+
+	// Move string attributes longer than IncludeFileThreshold bytes, e.g. OpenAPI bodies or user data,
+	// into files under IncludeFileDir referenced from the config, see Generator.IncludeFiles. Zero keeps
+	// every value inline.
+	IncludeFileThreshold int
+	IncludeFileDir       string
+	IncludeFunction      IncludeFunction
+
+	// Attributes by resource type, or AllResourceTypes, whose state values are asserted in the generated
+	// config. Only rendered for HCL2.
+	Invariants     map[string][]*AttributeInvariant
+	InvariantStyle InvariantStyle
+
+	// Transformers by resource type, or AllResourceTypes, run in order before rendering.
+	Transformers map[string][]ResourceTransformer
+
+	// External commands run for every resource, in order within their stage.
+	Hooks []*ExecHook
+
+	// Policies resources are checked against before they are rendered, and what happens to resources
+	// violating them. Only checked by exporters and writers that name resources after their state key.
+	RegoPolicy   *RegoPolicy
+	PolicyAction PolicyAction
+
+	// Rules rewriting the names resources are generated with and their module, applied in order, each
+	// to the address the rules before it produced. See Generator.RemapMoves for the matching moved
+	// blocks.
+	RemapRules []*RemapRule
+
+	// Prices resources for a comment with their estimated monthly cost, rendered by the same exporters
+	// and writers that check the Rego policy.
+	Pricing PricingProvider
+
+	// Templates rendering the blocks of resource types in place of the generic renderer, by type.
+	ResourceTemplates map[string]*ResourceTemplate
+
+	// Receives the progress of OutputFiles, WriteResumable and the exporters after every resource, e.g.
+	// to show a progress indicator or log failures for large states.
+	Progress ProgressFunc
+
+	// Maximum number of resources written to a file by OutputFiles and WriteResumable, see ChunkGroups.
+	// Zero writes every group to a single file.
+	MaxResourcesPerFile int
+
+	// Attribute order by resource type, or AllResourceTypes as a fallback. Attributes are sorted
+	// alphabetically for types without an order.
+	AttributeOrders map[string]*AttributeOrder
+
+	// Sort keys of repeated blocks by resource type, or AllResourceTypes for blocks of every type, with
+	// the keys of a type taking precedence. NewOptions sets AWSSecurityGroupBlockSortKeys.
+	BlockSortKeys map[string]BlockSortKeys
+}
+
+func NewOptions() *Options {
+	opts := &Options{
+		Defaults:           ResourceDefaults{},
+		Excludes:           ResourceExcludes{},
+		Rules:              ResourceRules{},
+		TypeDefaults:       map[string]ResourceDefaults{},
+		TypeExcludes:       map[string]ResourceExcludes{},
+		TypeRules:          map[string]ResourceRules{},
+		TypeValueExcludes:  map[string][]*ValueExclude{},
+		Secrets:            ResourceSecrets{},
+		TypeSecrets:        map[string]ResourceSecrets{},
+		FloatPrecision:     -1,
+		CollapseCounts:     true,
+		NameReplacement:    "_",
+		IDNameRules:        map[string]IDNameRule{},
+		ManifestAttributes: map[string][]string{},
+		Base64Attributes:   map[string][]string{},
+		Invariants:         map[string][]*AttributeInvariant{},
+		Transformers:       map[string][]ResourceTransformer{},
+		AttributeOrders:    map[string]*AttributeOrder{},
+		BlockSortKeys:      map[string]BlockSortKeys{},
+		ResourceTemplates:  map[string]*ResourceTemplate{},
+		CoercionAllowlist:  AttributeSet{},
+		CoercionDenylist:   AttributeSet{},
+	}
+
+	for resourceType, attrNames := range KubernetesManifestAttributes {
+		opts.ManifestAttributes[resourceType] = attrNames
+	}
+	for resourceType, attrNames := range Base64EncodedAttributes {
+		opts.Base64Attributes[resourceType] = attrNames
+	}
+	for attrName := range DefaultCoercionDenylist {
+		opts.CoercionDenylist[attrName] = struct{}{}
+	}
+	for resourceType, attrNames := range UtilityProviderOutputs {
+		opts.TypeExcludes[resourceType] = ResourceExcludes{}
+		for _, attrName := range attrNames {
+			opts.TypeExcludes[resourceType][attrName] = struct{}{}
+		}
+	}
+	for _, resourceType := range []string{"aws_security_group", "aws_default_security_group"} {
+		opts.BlockSortKeys[resourceType] = BlockSortKeys{}
+		for blockName, keys := range AWSSecurityGroupBlockSortKeys {
+			opts.BlockSortKeys[resourceType][blockName] = keys
+		}
+	}
+	for resourceType, attrNames := range UtilityProviderSecrets {
+		opts.TypeSecrets[resourceType] = ResourceSecrets{}
+		for _, attrName := range attrNames {
+			opts.TypeSecrets[resourceType][attrName] = struct{}{}
+		}
+	}
+
+	return opts
+}
+
+// Returns a copy of the options that shares no maps or slices with them, so either can be modified
+// without affecting the other. Schemas and the values of defaults are shared and must not be modified.
+func (o *Options) Clone() *Options {
+	c := *o
+
+	c.Defaults = ResourceDefaults{}
+	for k, v := range o.Defaults {
+		c.Defaults[k] = v
+	}
+	c.Excludes = copyAttributeSet(o.Excludes)
+	c.Rules = ResourceRules{}
+	for k, v := range o.Rules {
+		c.Rules[k] = v
+	}
+
+	c.TypeDefaults = map[string]ResourceDefaults{}
+	for resourceType, defaults := range o.TypeDefaults {
+		c.TypeDefaults[resourceType] = ResourceDefaults{}
+		for k, v := range defaults {
+			c.TypeDefaults[resourceType][k] = v
+		}
+	}
+	c.TypeExcludes = map[string]ResourceExcludes{}
+	for resourceType, excludes := range o.TypeExcludes {
+		c.TypeExcludes[resourceType] = copyAttributeSet(excludes)
+	}
+	c.TypeRules = map[string]ResourceRules{}
+	for resourceType, rules := range o.TypeRules {
+		c.TypeRules[resourceType] = ResourceRules{}
+		for k, v := range rules {
+			c.TypeRules[resourceType][k] = v
+		}
+	}
+
+	c.ValueExcludes = append([]*ValueExclude(nil), o.ValueExcludes...)
+	c.TypeValueExcludes = map[string][]*ValueExclude{}
+	for resourceType, excludes := range o.TypeValueExcludes {
+		c.TypeValueExcludes[resourceType] = append([]*ValueExclude(nil), excludes...)
+	}
+
+	c.Secrets = copyAttributeSet(o.Secrets)
+	c.TypeSecrets = map[string]ResourceSecrets{}
+	for resourceType, secrets := range o.TypeSecrets {
+		c.TypeSecrets[resourceType] = copyAttributeSet(secrets)
+	}
+
+	c.CoercionAllowlist = copyAttributeSet(o.CoercionAllowlist)
+	c.CoercionDenylist = copyAttributeSet(o.CoercionDenylist)
+
+	c.IDNameRules = map[string]IDNameRule{}
+	for provider, rule := range o.IDNameRules {
+		c.IDNameRules[provider] = rule
+	}
+
+	c.ManifestAttributes = map[string][]string{}
+	for resourceType, attrNames := range o.ManifestAttributes {
+		c.ManifestAttributes[resourceType] = append([]string(nil), attrNames...)
+	}
+
+	c.Base64Attributes = map[string][]string{}
+	for resourceType, attrNames := range o.Base64Attributes {
+		c.Base64Attributes[resourceType] = append([]string(nil), attrNames...)
+	}
+
+	c.Invariants = map[string][]*AttributeInvariant{}
+	for resourceType, invariants := range o.Invariants {
+		c.Invariants[resourceType] = append([]*AttributeInvariant(nil), invariants...)
+	}
+
+	c.Transformers = map[string][]ResourceTransformer{}
+	for resourceType, transformers := range o.Transformers {
+		c.Transformers[resourceType] = append([]ResourceTransformer(nil), transformers...)
+	}
+
+	c.Hooks = append([]*ExecHook(nil), o.Hooks...)
+	c.RemapRules = append([]*RemapRule(nil), o.RemapRules...)
+
+	c.AttributeOrders = map[string]*AttributeOrder{}
+	for resourceType, order := range o.AttributeOrders {
+		c.AttributeOrders[resourceType] = order
+	}
+
+	c.ResourceTemplates = map[string]*ResourceTemplate{}
+	for resourceType, t := range o.ResourceTemplates {
+		c.ResourceTemplates[resourceType] = t
+	}
+
+	c.BlockSortKeys = map[string]BlockSortKeys{}
+	for resourceType, sortKeys := range o.BlockSortKeys {
+		c.BlockSortKeys[resourceType] = BlockSortKeys{}
+		for blockName, keys := range sortKeys {
+			c.BlockSortKeys[resourceType][blockName] = append([]string(nil), keys...)
+		}
+	}
+
+	return &c
+}
+
+func copyAttributeSet(s map[string]struct{}) map[string]struct{} {
+	c := map[string]struct{}{}
+	for k := range s {
+		c[k] = struct{}{}
+	}
+
+	return c
+}
+
+// Adds a transformer for a resource type, or AllResourceTypes. Transformers run in the order they were
+// added, those for AllResourceTypes first.
+func (o *Options) AddTransformer(resourceType string, transformer ResourceTransformer) {
+	if o.Transformers == nil {
+		o.Transformers = map[string][]ResourceTransformer{}
+	}
+
+	o.Transformers[resourceType] = append(o.Transformers[resourceType], transformer)
+}
+
+// Adds a transformer applying the migration rules for each resource type.
+func (o *Options) AddMigrationRules(rules MigrationRules) {
+	for resourceType, typeRules := range rules {
+		o.AddTransformer(resourceType, migrationTransformer(typeRules))
+	}
+}
+
+// Sets the attribute order for a resource type, or AllResourceTypes as a fallback.
+func (o *Options) SetAttributeOrder(resourceType string, order *AttributeOrder) {
+	if o.AttributeOrders == nil {
+		o.AttributeOrders = map[string]*AttributeOrder{}
+	}
+
+	o.AttributeOrders[resourceType] = order
+}
+
+// Sets the attributes repeated blocks of a resource type, or AllResourceTypes, are sorted by.
+func (o *Options) SetBlockSortKeys(resourceType string, blockName string, keys ...string) {
+	if o.BlockSortKeys == nil {
+		o.BlockSortKeys = map[string]BlockSortKeys{}
+	}
+	if o.BlockSortKeys[resourceType] == nil {
+		o.BlockSortKeys[resourceType] = BlockSortKeys{}
+	}
+
+	o.BlockSortKeys[resourceType][blockName] = keys
+}
+
+// Adds a transformer applying value templates to a resource type, or AllResourceTypes.
+func (o *Options) AddValueTemplates(resourceType string, templates []*ValueTemplate) error {
+	transformer, err := ValueTemplateTransformer(templates)
+	if err != nil {
+		return err
+	}
+
+	o.AddTransformer(resourceType, transformer)
+	return nil
+}
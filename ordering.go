@@ -0,0 +1,165 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Controls the order attributes of a resource are rendered in. Attributes not listed keep the
+// alphabetical order, so output stays deterministic.
+type AttributeOrder struct {
+	// Attributes rendered first, in this order.
+	First []string
+	// Attributes rendered last, in this order.
+	Last []string
+	// Render nested blocks after arguments.
+	BlocksLast bool
+}
+
+// An order putting name-ish attributes first, tags last and blocks after arguments.
+var ConventionalAttributeOrder = &AttributeOrder{
+	First:      []string{"name", "name_prefix", "bucket", "identifier", "family"},
+	Last:       []string{"tags"},
+	BlocksLast: true,
+}
+
+// Reorders alphabetically sorted attribute names according to the order set for the type.
+func (g *Generator) orderAttributeNames(resourceType string, names []string, attrs map[string]interface{}, block *SchemaBlock) []string {
+	tc := g.typeConfig(resourceType)
+	if tc.order == nil {
+		return names
+	}
+
+	rank := func(name string) (int, int) {
+		if i, ok := tc.firstPositions[name]; ok {
+			return 0, i
+		}
+		if i, ok := tc.lastPositions[name]; ok {
+			return 3, i
+		}
+		if tc.order.BlocksLast && isBlockAttribute(name, attrs[name], block) {
+			return 2, 0
+		}
+		return 1, 0
+	}
+
+	ordered := append([]string{}, names...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, pi := rank(ordered[i])
+		rj, pj := rank(ordered[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return pi < pj
+	})
+
+	return ordered
+}
+
+// Maps each attribute name in the list to its position.
+func attributePositions(list []string) map[string]int {
+	positions := map[string]int{}
+	for i, name := range list {
+		positions[name] = i
+	}
+
+	return positions
+}
+
+// Whether an attribute renders as a nested block, by schema when available and otherwise the same way
+// AttributeToString decides.
+func isBlockAttribute(attrName string, v interface{}, block *SchemaBlock) bool {
+	if block != nil {
+		_, ok := block.BlockTypes[attrName]
+		return ok
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return true
+	case []interface{}:
+		return len(v) > 0 && !IsPrimitive(v[0])
+	}
+
+	return false
+}
+
+// Attributes by nested block name that repeated blocks are sorted by, e.g. the ingress rules of a
+// security group, which are stored in a set and come out of the state in a different order whenever
+// it is refreshed. Blocks are compared by the first key they differ in, numerically when both values
+// are numbers. Blocks without a difference keep their order in the state.
+type BlockSortKeys map[string][]string
+
+// Sort keys for the rules of AWS security groups, set by NewOptions.
+var AWSSecurityGroupBlockSortKeys = BlockSortKeys{
+	"ingress": {"from_port", "to_port", "protocol", "cidr_blocks", "ipv6_cidr_blocks", "security_groups", "self", "description"},
+	"egress":  {"from_port", "to_port", "protocol", "cidr_blocks", "ipv6_cidr_blocks", "security_groups", "self", "description"},
+}
+
+// Sorts the repeated blocks of the attributes, and of the blocks nested within them, by the sort keys
+// set for their name. Sorted lists are copies, so values shared with the state aren't modified.
+func sortBlocks(attrs map[string]interface{}, sortKeys BlockSortKeys) {
+	for attrName, v := range attrs {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			sortBlocks(v, sortKeys)
+		case []interface{}:
+			blocks := make([]map[string]interface{}, 0, len(v))
+			for _, element := range v {
+				if m, ok := element.(map[string]interface{}); ok {
+					sortBlocks(m, sortKeys)
+					blocks = append(blocks, m)
+				}
+			}
+
+			keys := sortKeys[attrName]
+			if len(keys) == 0 || len(blocks) != len(v) {
+				continue
+			}
+
+			sort.SliceStable(blocks, func(i, j int) bool {
+				for _, k := range keys {
+					if c := compareSortValues(blocks[i][k], blocks[j][k]); c != 0 {
+						return c < 0
+					}
+				}
+				return false
+			})
+
+			sorted := make([]interface{}, len(blocks))
+			for i, block := range blocks {
+				sorted[i] = block
+			}
+			attrs[attrName] = sorted
+		}
+	}
+}
+
+// Compares two values of a sort key, numerically when both are numbers. Missing values sort first.
+func compareSortValues(a, b interface{}) int {
+	as, bs := sortValueString(a), sortValueString(b)
+
+	af, aErr := strconv.ParseFloat(as, 64)
+	bf, bErr := strconv.ParseFloat(bs, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(as, bs)
+}
+
+func sortValueString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,505 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// A file of generated output, e.g. a group of resources or an include file.
+type OutputFile struct {
+	// Slash separated path relative to the output directory.
+	Path    string
+	Content string
+}
+
+// Renders the resources of a state into one file per group, or per chunk of a group with the
+// MaxResourcesPerFile option, followed by the include files they reference, all sorted by path.
+//
+// Output is byte-identical for the same state and options: resources are rendered in address order,
+// and attributes, map keys, set elements and groups are always sorted. Transformers and value
+// templates must be deterministic themselves for the guarantee to hold. Include files recorded by
+// earlier calls on the same generator are included too, so use a new generator per output directory.
+// Resources that cannot be generated are left out and returned as GenerationFailures along with the
+// files of the others.
+func (g *Generator) OutputFiles(state *terraform.State, strategy GroupingStrategy) ([]*OutputFile, error) {
+	files, _, err := g.OutputFilesWithManifest(state, strategy)
+	return files, err
+}
+
+// Renders output files like OutputFiles along with a manifest recording every generated resource and
+// the hash of its rendered block, for WriteOutput.
+func (g *Generator) OutputFilesWithManifest(state *terraform.State, strategy GroupingStrategy) ([]*OutputFile, *Manifest, error) {
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups := g.groupResources(resources, strategy)
+	groupNames := []string{}
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+
+	manifest := NewManifest()
+	failures := GenerationFailures{}
+	files := []*OutputFile{}
+	progress := g.newProgress(len(resources))
+
+	for _, group := range groupNames {
+		path := filepath.ToSlash(GroupFilePath("", group))
+
+		var b strings.Builder
+		err := g.renderBlocks(groups[group], progress, func(rs []*ResourceInstance, block string) error {
+			if b.Len() > 0 {
+				b.WriteString(g.blockSeparator())
+			}
+			b.WriteString(block)
+
+			for _, r := range rs {
+				manifest.Add(path, g.unitAddress(r, len(rs) > 1), r)
+				manifest.Resources[len(manifest.Resources)-1].Hash = blockHash(block)
+			}
+
+			return nil
+		})
+		if err != nil {
+			groupFailures, ok := err.(GenerationFailures)
+			if !ok {
+				return nil, nil, err
+			}
+			failures = append(failures, groupFailures...)
+		}
+
+		if b.Len() > 0 {
+			files = append(files, &OutputFile{Path: path, Content: b.String()})
+		}
+	}
+
+	for _, f := range g.IncludeFiles() {
+		files = append(files, &OutputFile{Path: f.Path, Content: f.Content})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	if len(failures) > 0 {
+		return files, manifest, failures
+	}
+
+	return files, manifest, nil
+}
+
+// Writes output files below dir, creating directories as needed and overwriting existing files. See
+// WriteOutput to protect files not generated by terraconf.
+func WriteOutputFiles(dir string, files []*OutputFile) error {
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f.Path))
+
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(p, []byte(f.Content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Compares output files with those below dir without writing anything, e.g. to fail a CI job when
+// regenerating would change committed config. Returns the paths of the files that are missing or
+// differ, in the order given. Files in dir that are not part of the output are not reported.
+func CheckOutputFiles(dir string, files []*OutputFile) ([]string, error) {
+	changed := []string{}
+
+	for _, f := range files {
+		existing, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if os.IsNotExist(err) {
+			changed = append(changed, f.Path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(existing, []byte(f.Content)) {
+			changed = append(changed, f.Path)
+		}
+	}
+
+	return changed, nil
+}
+
+// How WriteOutput treats output files that already exist.
+type OverwriteMode int
+
+const (
+	// Overwrite files listed in the manifest of an earlier run and refuse to overwrite any other file.
+	OverwriteGenerated OverwriteMode = iota
+	// Overwrite any existing file.
+	OverwriteAll
+	// Keep every existing file and only write the files that don't exist yet.
+	OverwriteNone
+)
+
+type WriteOptions struct {
+	Overwrite OverwriteMode
+
+	// Remove files listed in the manifest of an earlier run that are no longer part of the output.
+	Clean bool
+
+	// Merge config into existing .tf files with MergeConfig instead of replacing them, keeping what was
+	// edited by hand. Files are only merged when Overwrite allows writing them. Requires SyntaxHCL2.
+	Merge bool
+
+	// Only rewrite files that changed since the earlier run, so unchanged files keep their modification
+	// time for build tooling. Files are unchanged when the manifests of both runs record the same
+	// resources with the same block hashes for them, see Generator.OutputFilesWithManifest, or
+	// otherwise when their content is the same.
+	Incremental bool
+}
+
+// Writes output files below dir along with a manifest, ManifestFileName, that records them as
+// generated. The manifest of an earlier run tells generated files from files created by hand, which
+// are never overwritten unless opts.Overwrite is OverwriteAll, and never removed. Nothing is written
+// when a file would be overwritten against opts. The manifest may be nil if no resources are to be
+// recorded.
+func WriteOutput(dir string, files []*OutputFile, manifest *Manifest, opts WriteOptions) error {
+	manifestPath := filepath.Join(dir, ManifestFileName)
+
+	generated := map[string]bool{}
+	previous, err := readManifestFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		for _, path := range previous.Files {
+			generated[path] = true
+		}
+	}
+
+	kept := map[string]bool{}
+	foreign := []string{}
+	for _, f := range files {
+		_, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case opts.Overwrite == OverwriteNone:
+			kept[f.Path] = true
+		case opts.Overwrite == OverwriteGenerated && !generated[f.Path]:
+			foreign = append(foreign, f.Path)
+		}
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("refusing to overwrite files not generated by terraconf: %s", strings.Join(foreign, ", "))
+	}
+
+	if manifest == nil {
+		manifest = NewManifest()
+	}
+
+	previousHashes := map[string]string{}
+	if previous != nil {
+		previousHashes = previous.fileHashes()
+	}
+	hashes := manifest.fileHashes()
+
+	written := map[string]bool{}
+	toWrite := []*OutputFile{}
+	for _, f := range files {
+		written[f.Path] = true
+		if kept[f.Path] {
+			continue
+		}
+
+		if opts.Incremental {
+			unchanged, err := outputFileUnchanged(dir, f, generated[f.Path] && hashes[f.Path] != "" && hashes[f.Path] == previousHashes[f.Path])
+			if err != nil {
+				return err
+			}
+			if unchanged {
+				continue
+			}
+		}
+
+		if opts.Merge && strings.HasSuffix(f.Path, ".tf") {
+			merged, err := mergeOutputFile(dir, f)
+			if err != nil {
+				return err
+			}
+			f = merged
+		}
+		toWrite = append(toWrite, f)
+	}
+
+	if err := WriteOutputFiles(dir, toWrite); err != nil {
+		return err
+	}
+
+	manifest.Files = nil
+
+	for path := range generated {
+		if written[path] {
+			continue
+		}
+		if !opts.Clean {
+			// Still generated, so a later run can clean it up.
+			manifest.Files = append(manifest.Files, path)
+			continue
+		}
+		if err := removeOutputFile(dir, path); err != nil {
+			return err
+		}
+	}
+
+	for path := range written {
+		// Files kept by OverwriteNone are only recorded if an earlier run generated them.
+		if !kept[path] || generated[path] {
+			manifest.Files = append(manifest.Files, path)
+		}
+	}
+	sort.Strings(manifest.Files)
+
+	f, err := os.Create(manifestPath)
+	if err != nil {
+		return err
+	}
+	if err := manifest.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Whether an output file exists below dir with the same resources, as told by the block hashes of the
+// manifests without reading the file, or with the same content.
+func outputFileUnchanged(dir string, f *OutputFile, sameHashes bool) (bool, error) {
+	p := filepath.Join(dir, filepath.FromSlash(f.Path))
+
+	if sameHashes {
+		_, err := os.Stat(p)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	existing, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(existing, []byte(f.Content)), nil
+}
+
+// Merges an output file into the existing file below dir, if there is one.
+func mergeOutputFile(dir string, f *OutputFile) (*OutputFile, error) {
+	existing, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := MergeConfig(existing, []byte(f.Content), f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutputFile{Path: f.Path, Content: string(merged)}, nil
+}
+
+// Reads the manifest at path, or returns nil if there is none.
+func readManifestFile(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadManifest(f)
+}
+
+// Removes a previously generated file below dir along with the directories left empty by it. Paths
+// leaving dir are rejected, since the manifest may have been edited.
+func removeOutputFile(dir string, path string) error {
+	p := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(p) || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("manifest lists a file outside of the output directory: %s", path)
+	}
+
+	if err := os.Remove(filepath.Join(dir, p)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for parent := filepath.Dir(p); parent != "."; parent = filepath.Dir(parent) {
+		// Fails once a directory isn't empty.
+		if os.Remove(filepath.Join(dir, parent)) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Renders every resource of a state into config by file name, one file per resource type along with
+// the include files they reference, for callers that don't need control over grouping. Options are
+// the defaults of NewOptions when nil. IDs of other resources in the same module are replaced with
+// references, see Generator.LinkResources, and resources rejected by the ResourceFilter option are left out.
+// The resources of all modules are rendered into the same files: after the remap rules of the options,
+// resources of other modules are moved to the root module with their names prefixed by the names of
+// their module, e.g. `vpc_main` for module.vpc.aws_vpc.main. It is an error for two resources to end
+// up with the same name that way, e.g. module.vpc.aws_vpc.main and a root aws_vpc.vpc_main; rename one
+// of them with a remap rule. For HCL2 the moves terraform needs to adopt remapped resources are
+// rendered into MovedFileName. With the StubSecrets option, the variables of stubbed secrets are
+// declared in SecretVariablesFileName and listed in SecretsExampleFileName.
+// Resources that cannot be generated are left out and returned as GenerationFailures along with the
+// files of the others.
+func GetStateConfigString(state *terraform.State, opts *Options) (map[string]string, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	files := map[string]string{}
+	failures := GenerationFailures{}
+	moves := []*ResourceMove{}
+	variables := map[string]*SecretVariable{}
+
+	// State address of the resource generated as every block, to detect names qualified with their
+	// module colliding with other names.
+	blocks := map[string]string{}
+
+	for _, module := range state.Modules {
+		moduleState := &terraform.State{Modules: []*terraform.ModuleState{module}}
+
+		// Links only hold for the resources of their module, so every module gets a generator of its own.
+		moduleOpts := opts.Clone()
+		if names := moduleNames(module.Path); len(names) > 0 {
+			moduleOpts.RemapRules = append(moduleOpts.RemapRules, &RemapRule{
+				Module:     names,
+				MoveModule: true,
+				NamePrefix: strings.Join(names, "_") + "_",
+			})
+		}
+
+		links, err := NewGenerator(moduleOpts).LinkResources(module.Path, module.Resources)
+		if err != nil {
+			return nil, err
+		}
+
+		moduleOpts.AddTransformer(AllResourceTypes, links.Transformer())
+		g := NewGenerator(moduleOpts)
+
+		moduleFiles, manifest, err := g.OutputFilesWithManifest(moduleState, GroupByType())
+		if err != nil {
+			moduleFailures, ok := err.(GenerationFailures)
+			if !ok {
+				return nil, err
+			}
+			failures = append(failures, moduleFailures...)
+		}
+
+		for _, entry := range manifest.Resources {
+			block, err := blockAddress(entry.Address)
+			if err != nil {
+				return nil, err
+			}
+			resource, err := blockAddress(entry.StateAddress)
+			if err != nil {
+				return nil, err
+			}
+
+			if existing, ok := blocks[block]; ok && existing != resource {
+				return nil, fmt.Errorf("%s and %s are both generated as %s", existing, resource, block)
+			}
+			blocks[block] = resource
+		}
+
+		for _, v := range g.SecretVariables() {
+			variables[v.Name] = v
+		}
+
+		for _, f := range moduleFiles {
+			existing, ok := files[f.Path]
+			switch {
+			case !ok:
+				files[f.Path] = f.Content
+			case strings.HasSuffix(f.Path, ".tf"):
+				files[f.Path] = existing + g.blockSeparator() + f.Content
+			case existing != f.Content:
+				return nil, fmt.Errorf("%s is generated by more than one module with different contents", f.Path)
+			}
+		}
+
+		if moduleOpts.Syntax == SyntaxHCL2 {
+			moduleMoves, err := g.RemapMoves(moduleState)
+			if err != nil {
+				return nil, err
+			}
+			moves = append(moves, moduleMoves...)
+		}
+	}
+
+	if len(moves) > 0 {
+		sort.Slice(moves, func(i, j int) bool {
+			return moves[i].From < moves[j].From
+		})
+		files[MovedFileName] = MovedBlocksString(moves)
+	}
+
+	if len(variables) > 0 {
+		sortedVariables := []*SecretVariable{}
+		for _, v := range variables {
+			sortedVariables = append(sortedVariables, v)
+		}
+		sort.Slice(sortedVariables, func(i, j int) bool {
+			return sortedVariables[i].Name < sortedVariables[j].Name
+		})
+
+		g := NewGenerator(opts)
+		files[SecretVariablesFileName] = g.SecretVariablesString(sortedVariables)
+		files[SecretsExampleFileName] = g.SecretsExampleString(sortedVariables)
+	}
+
+	if len(failures) > 0 {
+		return files, failures
+	}
+
+	return files, nil
+}
+
+// Returns the address of the block a resource instance is declared in, i.e. its address without index.
+func blockAddress(address string) (string, error) {
+	a, err := ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	if a.Resource == nil {
+		return "", fmt.Errorf("invalid address %q: not a resource", address)
+	}
+
+	return ResourceAddress(a.Module, &ResourceKey{Data: a.Resource.Data, Type: a.Resource.Type, Name: a.Resource.Name}), nil
+}
@@ -0,0 +1,315 @@
+package terraconf_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Builds a state with many map keys, set elements keyed by hash, and several resources per type, so
+// that map iteration order would show in the output if anything rendered without sorting.
+func mapHeavyState() *terraform.State {
+	resources := map[string]*terraform.ResourceState{}
+
+	for i := 0; i < 20; i++ {
+		tags := map[string]interface{}{}
+		for j := 0; j < 20; j++ {
+			tags[fmt.Sprintf("tag_%02d", j)] = fmt.Sprintf("value-%d-%d", i, j)
+		}
+
+		resources[fmt.Sprintf("aws_instance.web_%d", i)] = terraconftest.NewResourceState("aws_instance", fmt.Sprintf("i-%08x", i), map[string]interface{}{
+			"ami":                    "ami-0c55b159cbfafe1f0",
+			"instance_type":          "t3.micro",
+			"vpc_security_group_ids": []interface{}{"sg-0000000c", "sg-0000000a", "sg-0000000b"},
+			"tags":                   tags,
+			"volume_tags":            tags,
+		})
+
+		sg := terraconftest.NewResourceState("aws_security_group", fmt.Sprintf("sg-%08x", i), map[string]interface{}{
+			"name":   fmt.Sprintf("web-%d", i),
+			"vpc_id": "vpc-0a1b2c3d",
+			"tags":   tags,
+		})
+		sg.Primary.Attributes["ingress.#"] = "3"
+		for j, port := range []string{"443", "22", "80"} {
+			hash := fmt.Sprintf("%d", 3000000000-j*123456789)
+			sg.Primary.Attributes["ingress."+hash+".from_port"] = port
+			sg.Primary.Attributes["ingress."+hash+".to_port"] = port
+			sg.Primary.Attributes["ingress."+hash+".protocol"] = "tcp"
+			sg.Primary.Attributes["ingress."+hash+".cidr_blocks.#"] = "2"
+			sg.Primary.Attributes["ingress."+hash+".cidr_blocks.0"] = "10.0.0.0/8"
+			sg.Primary.Attributes["ingress."+hash+".cidr_blocks.1"] = "0.0.0.0/0"
+		}
+		resources[fmt.Sprintf("aws_security_group.web_%d", i)] = sg
+	}
+
+	return terraconftest.NewState(resources)
+}
+
+// Builds a state with resources in the root module and two nested modules, with the same resource
+// names in each.
+func multiModuleState() *terraform.State {
+	state := &terraform.State{Version: terraform.StateVersion}
+
+	for _, path := range [][]string{{"root"}, {"root", "network"}, {"root", "network", "subnets"}, {"root", "app"}} {
+		resources := map[string]*terraform.ResourceState{}
+		for i := 0; i < 5; i++ {
+			id := fmt.Sprintf("%s-%d", path[len(path)-1], i)
+			resources[fmt.Sprintf("aws_s3_bucket.bucket_%d", i)] = terraconftest.NewResourceState("aws_s3_bucket", id, map[string]interface{}{
+				"bucket": id,
+				"tags":   map[string]interface{}{"Module": path[len(path)-1], "Index": fmt.Sprintf("%d", i)},
+			})
+		}
+
+		state.Modules = append(state.Modules, &terraform.ModuleState{Path: path, Resources: resources})
+	}
+
+	return state
+}
+
+func TestOutputFilesDeterministic(t *testing.T) {
+	states := map[string]func() *terraform.State{
+		"map heavy":    mapHeavyState,
+		"multi module": multiModuleState,
+	}
+	syntaxes := map[string]terraconf.Syntax{"hcl1": terraconf.SyntaxHCL1, "hcl2": terraconf.SyntaxHCL2}
+
+	for stateName, newState := range states {
+		for syntaxName, syntax := range syntaxes {
+			t.Run(stateName+"/"+syntaxName, func(t *testing.T) {
+				opts := terraconf.NewOptions()
+				opts.Syntax = syntax
+
+				var expected []*terraconf.OutputFile
+				for i := 0; i < 10; i++ {
+					// A new state every time, so nothing depends on the order maps were filled in.
+					files, err := terraconf.NewGenerator(opts).OutputFiles(newState(), terraconf.GroupByType())
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(files) == 0 {
+						t.Fatal("no output files")
+					}
+
+					if i == 0 {
+						expected = files
+						continue
+					}
+					if !reflect.DeepEqual(files, expected) {
+						t.Fatalf("output of run %d differs from the first run", i+1)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestGetStateConfigStringDeterministic(t *testing.T) {
+	for _, newState := range []func() *terraform.State{mapHeavyState, multiModuleState} {
+		expected, err := terraconf.GetStateConfigString(newState(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 10; i++ {
+			files, err := terraconf.GetStateConfigString(newState(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, expected) {
+				t.Fatalf("output of run %d differs from the first run", i+2)
+			}
+		}
+	}
+}
+
+// Resources of the same name in different modules are rendered into the root module under names
+// qualified with their module, along with the moves adopting them.
+func TestGetStateConfigStringModules(t *testing.T) {
+	opts := terraconf.NewOptions()
+	opts.Syntax = terraconf.SyntaxHCL2
+
+	files, err := terraconf.GetStateConfigString(multiModuleState(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := regexp.MustCompile(`(?m)^resource "aws_s3_bucket" "([^"]+)"`).FindAllStringSubmatch(files["aws_s3_bucket.tf"], -1)
+	if len(names) != 20 {
+		t.Fatalf("expected 20 buckets, got %d", len(names))
+	}
+	seen := map[string]bool{}
+	for _, m := range names {
+		if seen[m[1]] {
+			t.Errorf("bucket %s is rendered more than once", m[1])
+		}
+		seen[m[1]] = true
+	}
+	if !seen["bucket_0"] || !seen["network_subnets_bucket_0"] {
+		t.Errorf("expected root and qualified module names, got %v", seen)
+	}
+
+	moved := "moved {\n  from = module.network.module.subnets.aws_s3_bucket.bucket_0\n  to   = aws_s3_bucket.network_subnets_bucket_0\n}\n"
+	if !strings.Contains(files[terraconf.MovedFileName], moved) {
+		t.Errorf("expected %s to contain:\n%s\ngot:\n%s", terraconf.MovedFileName, moved, files[terraconf.MovedFileName])
+	}
+	if strings.Contains(files[terraconf.MovedFileName], "from = aws_s3_bucket.") {
+		t.Errorf("resources of the root module are moved:\n%s", files[terraconf.MovedFileName])
+	}
+}
+
+// Names qualified with their module colliding with other names are reported instead of rendering the
+// same block twice.
+func TestGetStateConfigStringCollisions(t *testing.T) {
+	vpc := func(id string) *terraform.ResourceState {
+		return terraconftest.NewResourceState("aws_vpc", id, map[string]interface{}{"cidr_block": "10.0.0.0/16"})
+	}
+
+	states := map[string]*terraform.State{
+		"root name": {
+			Version: terraform.StateVersion,
+			Modules: []*terraform.ModuleState{
+				{Path: []string{"root"}, Resources: map[string]*terraform.ResourceState{"aws_vpc.vpc_main": vpc("vpc-1")}},
+				{Path: []string{"root", "vpc"}, Resources: map[string]*terraform.ResourceState{"aws_vpc.main": vpc("vpc-2")}},
+			},
+		},
+		"module names": {
+			Version: terraform.StateVersion,
+			Modules: []*terraform.ModuleState{
+				{Path: []string{"root", "a_b"}, Resources: map[string]*terraform.ResourceState{"aws_vpc.main": vpc("vpc-1")}},
+				{Path: []string{"root", "a", "b"}, Resources: map[string]*terraform.ResourceState{"aws_vpc.main": vpc("vpc-2")}},
+			},
+		},
+	}
+
+	for name, state := range states {
+		if _, err := terraconf.GetStateConfigString(state, nil); err == nil || !strings.Contains(err.Error(), "are both generated as") {
+			t.Errorf("%s: expected a collision error, got %v", name, err)
+		}
+	}
+}
+
+// Stubbed secrets of every module are declared along with the config referencing them.
+func TestGetStateConfigStringSecrets(t *testing.T) {
+	state := &terraform.State{
+		Version: terraform.StateVersion,
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root", "db"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_db_instance.main": terraconftest.NewResourceState("aws_db_instance", "main", map[string]interface{}{"engine": "postgres", "password": "hunter2"}),
+				},
+			},
+		},
+	}
+
+	opts := terraconf.NewOptions()
+	opts.Syntax = terraconf.SyntaxHCL2
+	opts.StubSecrets = true
+	opts.TypeSecrets["aws_db_instance"] = terraconf.ResourceSecrets{"password": struct{}{}}
+
+	files, err := terraconf.GetStateConfigString(state, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reference := regexp.MustCompile(`var\.(\w+)`).FindStringSubmatch(files["aws_db_instance.tf"])
+	if reference == nil {
+		t.Fatalf("expected the password to reference a variable:\n%s", files["aws_db_instance.tf"])
+	}
+	if !strings.Contains(files[terraconf.SecretVariablesFileName], fmt.Sprintf("variable %q {", reference[1])) {
+		t.Errorf("expected %s to declare %s, got:\n%s", terraconf.SecretVariablesFileName, reference[1], files[terraconf.SecretVariablesFileName])
+	}
+	if !strings.Contains(files[terraconf.SecretsExampleFileName], reference[1]+" = ") {
+		t.Errorf("expected %s to list %s, got:\n%s", terraconf.SecretsExampleFileName, reference[1], files[terraconf.SecretsExampleFileName])
+	}
+	if strings.Contains(files["aws_db_instance.tf"]+files[terraconf.SecretVariablesFileName], "hunter2") {
+		t.Error("the password is rendered")
+	}
+}
+
+// IDs of other resources in the same module are replaced with references to them.
+func TestGetStateConfigStringLinks(t *testing.T) {
+	state := terraconftest.NewState(map[string]*terraform.ResourceState{
+		"aws_vpc.main": terraconftest.NewResourceState("aws_vpc", "vpc-0123", map[string]interface{}{"cidr_block": "10.0.0.0/16"}),
+		"aws_subnet.a": terraconftest.NewResourceState("aws_subnet", "subnet-0123", map[string]interface{}{"vpc_id": "vpc-0123"}),
+	})
+
+	files, err := terraconf.GetStateConfigString(state, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"aws_subnet.tf": "resource \"aws_subnet\" \"a\" {\n  vpc_id = \"${aws_vpc.main.id}\"\n}\n",
+		"aws_vpc.tf":    "resource \"aws_vpc\" \"main\" {\n  cidr_block = \"10.0.0.0/16\"\n}\n",
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %q, got %q", expected, files)
+	}
+}
+
+func TestCheckOutputFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terraconf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files, err := terraconf.NewGenerator(terraconf.NewOptions()).OutputFiles(mapHeavyState(), terraconf.GroupByType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 output files, got %d", len(files))
+	}
+
+	changed, err := terraconf.CheckOutputFiles(dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{files[0].Path, files[1].Path}) {
+		t.Errorf("expected every file to be reported missing, got %v", changed)
+	}
+
+	if err := terraconf.WriteOutputFiles(dir, files); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err = terraconf.CheckOutputFiles(dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("expected no changes after writing, got %v", changed)
+	}
+
+	// An edit by hand, a removed file and an unrelated file.
+	edited := filepath.Join(dir, filepath.FromSlash(files[1].Path))
+	if err := ioutil.WriteFile(edited, []byte(files[1].Content+"\n# edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, filepath.FromSlash(files[0].Path))); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "extra.tf"), []byte("# not generated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err = terraconf.CheckOutputFiles(dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{files[0].Path, files[1].Path}) {
+		t.Errorf("expected the removed and edited files to be reported, got %v", changed)
+	}
+}
@@ -0,0 +1,471 @@
+package terraconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Statement elements holding a single value or a list of values, which are compared order insensitively.
+var policyListElements = map[string]bool{
+	"Action":      true,
+	"NotAction":   true,
+	"Resource":    true,
+	"NotResource": true,
+}
+
+// Parses a policy document, returning false for values that are not JSON objects with statements.
+func parsePolicyDocument(s string) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return nil, false
+	}
+
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+
+	var doc map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, false
+	}
+
+	if _, ok := doc["Statement"]; !ok {
+		return nil, false
+	}
+
+	return doc, true
+}
+
+// Rewrites an IAM, KMS or S3 policy document into a canonical form: statements sorted, single element
+// arrays replaced by their element and action and resource lists sorted. Returns the value unchanged
+// when it is not a policy document.
+func CanonicalizePolicy(s string) string {
+	doc, ok := parsePolicyDocument(s)
+	if !ok {
+		return s
+	}
+
+	// Policies commonly contain characters such as `>` in conditions, which are kept unescaped.
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(canonicalPolicyDocument(doc)); err != nil {
+		return s
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func canonicalPolicyDocument(doc map[string]interface{}) map[string]interface{} {
+	statements, ok := doc["Statement"].([]interface{})
+	if !ok {
+		statements = []interface{}{doc["Statement"]}
+	}
+
+	canonical := []interface{}{}
+	keys := []string{}
+	for _, statement := range statements {
+		c := canonicalPolicyValue(statement)
+		if m, ok := c.(map[string]interface{}); ok {
+			for element := range policyListElements {
+				if list, ok := m[element].([]interface{}); ok {
+					sortPolicyList(list)
+				}
+			}
+		}
+
+		// Maps are marshalled with sorted keys, giving a sort key for the statement.
+		b, _ := json.Marshal(c)
+		keys = append(keys, string(b))
+		canonical = append(canonical, c)
+	}
+
+	order := make([]int, len(canonical))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return keys[order[i]] < keys[order[j]]
+	})
+
+	sorted := []interface{}{}
+	for _, i := range order {
+		sorted = append(sorted, canonical[i])
+	}
+
+	result := map[string]interface{}{}
+	for k, v := range doc {
+		result[k] = v
+	}
+	result["Statement"] = sorted
+
+	return result
+}
+
+// Replaces single element arrays by their element, recursively.
+func canonicalPolicyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, item := range v {
+			m[k] = canonicalPolicyValue(item)
+		}
+		return m
+	case []interface{}:
+		if len(v) == 1 {
+			return canonicalPolicyValue(v[0])
+		}
+		list := []interface{}{}
+		for _, item := range v {
+			list = append(list, canonicalPolicyValue(item))
+		}
+		return list
+	}
+
+	return v
+}
+
+func sortPolicyList(list []interface{}) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return fmt.Sprint(list[i]) < fmt.Sprint(list[j])
+	})
+}
+
+// Returns a transformer canonicalizing every string attribute holding a policy document, so
+// regenerating config doesn't cause diffs when the provider reordered the stored policy.
+func CanonicalizePolicies() ResourceTransformer {
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		for attrName, v := range attrs {
+			if s, ok := v.(string); ok {
+				attrs[attrName] = CanonicalizePolicy(s)
+			}
+		}
+
+		return attrs
+	}
+}
+
+// A policy document declared as an aws_iam_policy_document data source.
+type PolicyDocument struct {
+	Name string
+	// The canonical policy JSON.
+	Policy string
+
+	// The attributes of the data source.
+	attrs map[string]interface{}
+}
+
+// The policy documents extracted from a state by ExtractPolicyDocuments.
+type PolicyDocumentExtraction struct {
+	Documents []*PolicyDocument
+
+	generator     *Generator
+	namesByPolicy map[string]string
+}
+
+// The schema aws_iam_policy_document is rendered with, so statements render as nested blocks.
+var policyDocumentSchema = &SchemaBlock{
+	Attributes: map[string]*SchemaAttribute{
+		"version": {},
+	},
+	BlockTypes: map[string]*SchemaBlockType{
+		"statement": {
+			NestingMode: "list",
+			Block: &SchemaBlock{
+				Attributes: map[string]*SchemaAttribute{
+					"sid":           {},
+					"effect":        {},
+					"actions":       {},
+					"not_actions":   {},
+					"resources":     {},
+					"not_resources": {},
+				},
+				BlockTypes: map[string]*SchemaBlockType{
+					"principals":     {NestingMode: "set", Block: policyPrincipalsSchema},
+					"not_principals": {NestingMode: "set", Block: policyPrincipalsSchema},
+					"condition": {
+						NestingMode: "set",
+						Block: &SchemaBlock{Attributes: map[string]*SchemaAttribute{
+							"test":     {},
+							"variable": {},
+							"values":   {},
+						}},
+					},
+				},
+			},
+		},
+	},
+}
+
+var policyPrincipalsSchema = &SchemaBlock{Attributes: map[string]*SchemaAttribute{
+	"type":        {},
+	"identifiers": {},
+}}
+
+// Finds the policy documents in string attributes of the resources of a state, to be declared as
+// aws_iam_policy_document data sources instead of raw JSON. Documents are deduplicated by their
+// canonical form and named after the first resource and attribute they were found on, e.g.
+// `web_policy`. Policies using elements the data source cannot express are left as JSON.
+func (g *Generator) ExtractPolicyDocuments(state *terraform.State) (*PolicyDocumentExtraction, error) {
+	e := &PolicyDocumentExtraction{generator: g, namesByPolicy: map[string]string{}}
+	names := map[string]bool{}
+
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range resources {
+		if r.State.Primary == nil {
+			continue
+		}
+
+		attrs := g.transformAttributes(r.Key.Type, expandAttributes(r.State.Primary.Attributes))
+		for _, attrName := range sortedKeys(attrs) {
+			s, ok := attrs[attrName].(string)
+			if !ok {
+				continue
+			}
+
+			policy := CanonicalizePolicy(s)
+			if _, ok := e.namesByPolicy[policy]; ok {
+				continue
+			}
+
+			doc, ok := parsePolicyDocument(policy)
+			if !ok {
+				continue
+			}
+
+			dataAttrs, ok := policyDocumentAttributes(doc)
+			if !ok {
+				continue
+			}
+
+			name := sanitizeGroupName(r.Key.InstanceName() + "_" + attrName)
+			for i := 2; names[name]; i++ {
+				name = sanitizeGroupName(fmt.Sprintf("%s_%s_%d", r.Key.InstanceName(), attrName, i))
+			}
+			names[name] = true
+
+			e.Documents = append(e.Documents, &PolicyDocument{Name: name, Policy: policy, attrs: dataAttrs})
+			e.namesByPolicy[policy] = name
+		}
+	}
+
+	return e, nil
+}
+
+// Returns a transformer replacing extracted policy documents with references to their data source, to
+// be added for AllResourceTypes to the options of the generator rendering the resources.
+func (e *PolicyDocumentExtraction) Transformer() ResourceTransformer {
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		for attrName, v := range attrs {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+
+			if name, ok := e.namesByPolicy[CanonicalizePolicy(s)]; ok {
+				attrs[attrName] = InterpolatedString(fmt.Sprintf("${data.aws_iam_policy_document.%s.json}", name))
+			}
+		}
+
+		return attrs
+	}
+}
+
+// Renders the data sources declaring the extracted policy documents.
+func (e *PolicyDocumentExtraction) DataSourcesString() (string, error) {
+	g := e.generator
+	var b strings.Builder
+
+	for i, doc := range e.Documents {
+		if i > 0 {
+			b.WriteString(g.blockSeparator())
+		}
+
+		var body strings.Builder
+		for _, attrName := range sortedKeys(doc.attrs) {
+			body.WriteString(g.schemaAttributeString(attrName, doc.attrs[attrName], policyDocumentSchema))
+		}
+
+		block, err := g.resourceBlock("data", "aws_iam_policy_document", doc.Name, body.String())
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(block)
+	}
+
+	return b.String(), nil
+}
+
+// Converts a canonical policy document into the attributes of an aws_iam_policy_document data source,
+// returning false when the document cannot be expressed by it.
+func policyDocumentAttributes(doc map[string]interface{}) (map[string]interface{}, bool) {
+	attrs := map[string]interface{}{}
+	statements := []interface{}{}
+
+	for k, v := range doc {
+		switch k {
+		case "Version":
+			version, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			attrs["version"] = version
+		case "Statement":
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, false
+			}
+
+			for _, raw := range list {
+				statement, ok := raw.(map[string]interface{})
+				if !ok {
+					return nil, false
+				}
+
+				converted, ok := policyStatementAttributes(statement)
+				if !ok {
+					return nil, false
+				}
+				statements = append(statements, converted)
+			}
+		default:
+			// Document level elements such as Id have no data source argument.
+			return nil, false
+		}
+	}
+
+	attrs["statement"] = statements
+
+	return attrs, true
+}
+
+func policyStatementAttributes(statement map[string]interface{}) (map[string]interface{}, bool) {
+	attrs := map[string]interface{}{}
+
+	for k, v := range statement {
+		var ok bool
+
+		switch k {
+		case "Sid":
+			attrs["sid"], ok = v.(string)
+		case "Effect":
+			attrs["effect"], ok = v.(string)
+		case "Action":
+			attrs["actions"], ok = policyStringList(v)
+		case "NotAction":
+			attrs["not_actions"], ok = policyStringList(v)
+		case "Resource":
+			attrs["resources"], ok = policyStringList(v)
+		case "NotResource":
+			attrs["not_resources"], ok = policyStringList(v)
+		case "Principal":
+			attrs["principals"], ok = policyPrincipalBlocks(v)
+		case "NotPrincipal":
+			attrs["not_principals"], ok = policyPrincipalBlocks(v)
+		case "Condition":
+			attrs["condition"], ok = policyConditionBlocks(v)
+		}
+
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return attrs, true
+}
+
+// Converts a single value or list of strings into a list.
+func policyStringList(v interface{}) ([]interface{}, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		list = []interface{}{v}
+	}
+
+	for _, item := range list {
+		if _, ok := item.(string); !ok {
+			return nil, false
+		}
+	}
+
+	return list, true
+}
+
+// Converts a principal, either `*` or a map of principal type to identifiers, into principals blocks.
+func policyPrincipalBlocks(v interface{}) ([]interface{}, bool) {
+	if s, ok := v.(string); ok && s == "*" {
+		return []interface{}{map[string]interface{}{"type": "*", "identifiers": []interface{}{"*"}}}, true
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	blocks := []interface{}{}
+	for _, principalType := range sortedKeys(m) {
+		identifiers, ok := policyStringList(m[principalType])
+		if !ok {
+			return nil, false
+		}
+		blocks = append(blocks, map[string]interface{}{"type": principalType, "identifiers": identifiers})
+	}
+
+	return blocks, true
+}
+
+// Converts a condition, a map of test to a map of variable to values, into condition blocks.
+func policyConditionBlocks(v interface{}) ([]interface{}, bool) {
+	tests, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	blocks := []interface{}{}
+	for _, test := range sortedKeys(tests) {
+		variables, ok := tests[test].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		for _, variable := range sortedKeys(variables) {
+			values, ok := policyConditionValues(variables[variable])
+			if !ok {
+				return nil, false
+			}
+			blocks = append(blocks, map[string]interface{}{"test": test, "variable": variable, "values": values})
+		}
+	}
+
+	return blocks, true
+}
+
+// Condition values may also be numbers or booleans, which the data source takes as strings.
+func policyConditionValues(v interface{}) ([]interface{}, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		list = []interface{}{v}
+	}
+
+	values := []interface{}{}
+	for _, item := range list {
+		switch item := item.(type) {
+		case string:
+			values = append(values, item)
+		case json.Number:
+			values = append(values, item.String())
+		case bool:
+			values = append(values, fmt.Sprintf("%t", item))
+		default:
+			return nil, false
+		}
+	}
+
+	return values, true
+}
@@ -0,0 +1,165 @@
+package terraconf
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+var previewIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><title>terraconf preview</title></head>
+<body>
+<h1>Resources</h1>
+<ul>
+{{range .}}<li><a href="resource?address={{.}}">{{.}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+var previewResourceTemplate = template.Must(template.New("resource").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Address}} - terraconf preview</title></head>
+<body>
+<p><a href="./">All resources</a></p>
+<h1>{{.Address}}</h1>
+<form method="get" action="resource">
+<input type="hidden" name="address" value="{{.Address}}">
+<input type="hidden" name="submitted" value="1">
+<fieldset>
+<legend>Exclude attributes</legend>
+{{range .Attributes}}<label><input type="checkbox" name="exclude" value="{{.Name}}"{{if .Excluded}} checked{{end}}> {{.Name}}</label><br>
+{{end}}</fieldset>
+<label><input type="checkbox" name="defaults" value="1"{{if .Defaults}} checked{{end}}> Apply defaults</label>
+<button type="submit">Preview</button>
+</form>
+{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+<pre>{{.Config}}</pre>
+</body>
+</html>
+`))
+
+// Serves an HTML preview of the config generated for each resource of a state, with toggles for
+// excluding attributes and applying defaults, so output can be reviewed before it is written. The
+// toggles only apply to the request, the options of the handler are never modified.
+type PreviewHandler struct {
+	opts      *Options
+	resources map[string]*ResourceInstance
+	addresses []string
+}
+
+// Creates a preview handler for the resources of a state, rendered with the given options or the
+// defaults of NewOptions when nil. The options must not be modified afterwards.
+func NewPreviewHandler(state *terraform.State, opts *Options) (*PreviewHandler, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	resources, err := NewGenerator(opts).StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &PreviewHandler{opts: opts, resources: map[string]*ResourceInstance{}}
+	for _, r := range resources {
+		h.resources[r.Address()] = r
+		h.addresses = append(h.addresses, r.Address())
+	}
+
+	return h, nil
+}
+
+func (h *PreviewHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/":
+		h.serveTemplate(w, previewIndexTemplate, h.addresses)
+	case "/resource":
+		h.serveResource(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+type previewAttribute struct {
+	Name     string
+	Excluded bool
+}
+
+type previewResource struct {
+	Address    string
+	Attributes []*previewAttribute
+	Defaults   bool
+	Config     string
+	Error      string
+}
+
+func (h *PreviewHandler) serveResource(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	r, ok := h.resources[query.Get("address")]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	opts := h.opts.Clone()
+
+	// Without a submitted form the excludes and defaults of the options are shown.
+	submitted := query.Get("submitted") != ""
+	applyDefaults := !submitted || query.Get("defaults") != ""
+
+	if submitted {
+		opts.Excludes = ResourceExcludes{}
+		opts.TypeExcludes = map[string]ResourceExcludes{}
+		for _, attrName := range query["exclude"] {
+			opts.Excludes[attrName] = struct{}{}
+		}
+
+		if !applyDefaults {
+			opts.Defaults = ResourceDefaults{}
+			opts.TypeDefaults = map[string]ResourceDefaults{}
+		}
+	}
+
+	g := NewGenerator(opts)
+
+	data := &previewResource{
+		Address:  r.Address(),
+		Defaults: applyDefaults,
+	}
+
+	if r.State.Primary != nil {
+		attrNames := []string{}
+		for attrName := range uniqueAttributeNames(r.State.Primary.Attributes) {
+			attrNames = append(attrNames, attrName)
+		}
+		sort.Strings(attrNames)
+
+		for _, attrName := range attrNames {
+			data.Attributes = append(data.Attributes, &previewAttribute{Name: attrName, Excluded: g.isExcluded(r.Key.Type, attrName)})
+		}
+	}
+
+	var config bytes.Buffer
+	if err := NewHCLExporter(g).Export([]*ResourceInstance{r}, &config); err != nil {
+		data.Error = err.Error()
+	}
+	data.Config = config.String()
+
+	h.serveTemplate(w, previewResourceTemplate, data)
+}
+
+func (h *PreviewHandler) serveTemplate(w http.ResponseWriter, t *template.Template, data interface{}) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b.Bytes())
+}
@@ -0,0 +1,45 @@
+package terraconf
+
+// Provider specific rendering rules layered over the generic rendering, e.g. AWSProfile. Profiles are
+// opt-in and applied with Options.ApplyProfile.
+type Profile struct {
+	Name string
+
+	// Excludes and transformers by resource type, or AllResourceTypes.
+	TypeExcludes map[string]ResourceExcludes
+	Transformers map[string][]ResourceTransformer
+}
+
+// Adds the excludes and transformers of a profile to the options. Transformers of the profile run
+// after those added before for the same resource type, so apply profiles before adding transformers
+// that expect their output. Transformers for AllResourceTypes, e.g. the links of GetStateConfigString,
+// always run before those of a single type, so type specific transformers of the profile see their
+// output whenever they were added.
+func (o *Options) ApplyProfile(p *Profile) {
+	if o.Excludes == nil {
+		o.Excludes = ResourceExcludes{}
+	}
+	if o.TypeExcludes == nil {
+		o.TypeExcludes = map[string]ResourceExcludes{}
+	}
+
+	for resourceType, excludes := range p.TypeExcludes {
+		target := o.Excludes
+		if resourceType != AllResourceTypes {
+			if _, ok := o.TypeExcludes[resourceType]; !ok {
+				o.TypeExcludes[resourceType] = ResourceExcludes{}
+			}
+			target = o.TypeExcludes[resourceType]
+		}
+
+		for k := range excludes {
+			target[k] = struct{}{}
+		}
+	}
+
+	for resourceType, transformers := range p.Transformers {
+		for _, transformer := range transformers {
+			o.AddTransformer(resourceType, transformer)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package terraconf
+
+// The progress of a generation run, reported after every resource.
+type Progress struct {
+	// Address of the resource just processed.
+	Address string
+
+	// Number of resources processed so far, including this one, and in total.
+	Done  int
+	Total int
+
+	// Why the resource could not be generated, or nil.
+	Err error
+}
+
+// Receives the progress of a generation run, e.g. to show a progress indicator or log failures while
+// generating large states.
+type ProgressFunc func(p *Progress)
+
+// Counts the resources processed by a generation run and reports each to a ProgressFunc, so callers
+// generating a state resource by resource can show how many are left alongside GenerationFailures.
+type ProgressCounter struct {
+	fn    ProgressFunc
+	done  int
+	total int
+}
+
+// Returns a counter for a run over total resources, or nil when fn is nil.
+func NewProgressCounter(total int, fn ProgressFunc) *ProgressCounter {
+	if fn == nil {
+		return nil
+	}
+
+	return &ProgressCounter{fn: fn, total: total}
+}
+
+// Reports a processed resource along with why it could not be generated, if it failed. Does nothing
+// on a nil counter.
+func (c *ProgressCounter) Step(address string, err error) {
+	if c == nil {
+		return
+	}
+
+	c.done++
+	c.fn(&Progress{Address: address, Done: c.done, Total: c.total, Err: err})
+}
+
+// Returns a counter for rendering total resources reporting to the Progress option, or nil when
+// progress isn't reported.
+func (g *Generator) newProgress(total int) *ProgressCounter {
+	return NewProgressCounter(total, g.opts.Progress)
+}
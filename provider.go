@@ -0,0 +1,230 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// The provider configuration a resource is managed by, e.g. `aws.us_east_1`.
+type ProviderRef struct {
+	Name string
+
+	// Empty for the default provider configuration.
+	Alias string
+}
+
+// Parses the provider of a resource state, e.g. `provider.aws.us_east_1`,
+// `module.vpc.provider.aws`, `provider["registry.terraform.io/hashicorp/aws"].us_east_1` or the older
+// `aws.us_east_1`. Returns nil when the state does not record a provider.
+func ParseProviderRef(s string) *ProviderRef {
+	modulePrefix := "module" + tfStateKeyDelimiter
+	for strings.HasPrefix(s, modulePrefix) {
+		s = strings.TrimPrefix(s, modulePrefix)
+
+		i := strings.Index(s, tfStateKeyDelimiter)
+		if i < 0 {
+			return nil
+		}
+		s = s[i+1:]
+	}
+
+	if strings.HasPrefix(s, "provider.") || strings.HasPrefix(s, "provider[") {
+		s = strings.TrimPrefix(s, "provider")
+	}
+
+	p := &ProviderRef{}
+
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return nil
+		}
+
+		source, err := strconv.Unquote(s[1:end])
+		if err != nil {
+			return nil
+		}
+		p.Name = source[strings.LastIndex(source, "/")+1:]
+		s = s[end+1:]
+	} else {
+		s = strings.TrimPrefix(s, tfStateKeyDelimiter)
+		parts := strings.SplitN(s, tfStateKeyDelimiter, 2)
+		p.Name = parts[0]
+		s = ""
+		if len(parts) == 2 {
+			s = tfStateKeyDelimiter + parts[1]
+		}
+	}
+
+	p.Alias = strings.TrimPrefix(s, tfStateKeyDelimiter)
+
+	if p.Name == "" {
+		return nil
+	}
+
+	return p
+}
+
+// Returns the reference used for the provider meta-argument, e.g. `aws.us_east_1`.
+func (p *ProviderRef) String() string {
+	if p.Alias == "" {
+		return p.Name
+	}
+
+	return p.Name + tfStateKeyDelimiter + p.Alias
+}
+
+// Renders the provider meta-argument for resources managed by an aliased provider. Resources using
+// the default provider configuration don't need one.
+func (g *Generator) providerAttributeString(state *terraform.ResourceState) string {
+	p := ParseProviderRef(state.Provider)
+	if p == nil || p.Alias == "" {
+		return ""
+	}
+
+	if g.opts.Syntax == SyntaxHCL2 {
+		return fmt.Sprintf("provider = %s\n", p.String())
+	}
+
+	return fmt.Sprintf("provider = %s\n", g.primitiveValueString(p.String()))
+}
+
+// Renders an aliased provider block for every aliased provider used by resources in the state, sorted
+// by name and alias, laid out like the rest of the generated config. Only the alias is set, the
+// remaining provider configuration has to be added by hand.
+func (g *Generator) ProviderBlocksString(state *terraform.State) string {
+	aliases := map[string]*ProviderRef{}
+
+	for _, module := range state.Modules {
+		for _, resource := range module.Resources {
+			p := ParseProviderRef(resource.Provider)
+			if p != nil && p.Alias != "" {
+				aliases[p.String()] = p
+			}
+		}
+	}
+
+	sortedRefs := []string{}
+	for ref := range aliases {
+		sortedRefs = append(sortedRefs, ref)
+	}
+	sort.Strings(sortedRefs)
+
+	var b strings.Builder
+	for _, ref := range sortedRefs {
+		p := aliases[ref]
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "provider %q {\nalias = %s\n}\n", p.Name, g.primitiveValueString(p.Alias))
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	s, err := g.format(b.String())
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// Provider arguments whose value is the same for every resource of a provider, found by
+// ExtractProviderConfig, e.g. the project of google resources.
+type ProviderConfigExtraction struct {
+	Provider string
+	// Values of the extracted arguments, by name.
+	Values map[string]string
+
+	generator *Generator
+}
+
+// Finds the given top level attributes, e.g. `project` and `region`, having a single value across
+// the resources of a provider that have them, so they can be set once in the provider block instead of
+// on every resource. Credentials, see ProviderCredentialArguments, are never extracted. Aliased
+// provider configurations need the same arguments added by hand.
+func (g *Generator) ExtractProviderConfig(state *terraform.State, provider string, attrNames []string) *ProviderConfigExtraction {
+	e := &ProviderConfigExtraction{Provider: provider, Values: map[string]string{}, generator: g}
+	conflicting := map[string]bool{}
+
+	for _, module := range state.Modules {
+		for _, resource := range module.Resources {
+			if resource.Primary == nil || providerName(resource.Type) != provider {
+				continue
+			}
+
+			for _, attrName := range attrNames {
+				if isProviderCredential(provider, attrName) {
+					continue
+				}
+
+				v, ok := resource.Primary.Attributes[attrName]
+				if !ok || v == "" {
+					continue
+				}
+
+				if existing, ok := e.Values[attrName]; ok && existing != v {
+					conflicting[attrName] = true
+				}
+				e.Values[attrName] = v
+			}
+		}
+	}
+
+	for attrName := range conflicting {
+		delete(e.Values, attrName)
+	}
+
+	return e
+}
+
+// Returns a transformer removing the extracted arguments from resources of the provider when they
+// have the extracted value, to be added for AllResourceTypes.
+func (e *ProviderConfigExtraction) Transformer() ResourceTransformer {
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		if providerName(resourceType) != e.Provider {
+			return attrs
+		}
+
+		for attrName, v := range e.Values {
+			if attrs[attrName] == v {
+				delete(attrs, attrName)
+			}
+		}
+
+		return attrs
+	}
+}
+
+// Renders the default provider block with the extracted arguments, or an empty string when nothing
+// was extracted and there are no credential variables to reference.
+func (e *ProviderConfigExtraction) ProviderBlockString() string {
+	g := e.generator
+	if len(e.Values) == 0 && (!g.opts.ProviderCredentialVariables || len(ProviderCredentialArguments[e.Provider]) == 0) {
+		return ""
+	}
+
+	attrNames := []string{}
+	for attrName := range e.Values {
+		attrNames = append(attrNames, attrName)
+	}
+	sort.Strings(attrNames)
+
+	var b strings.Builder
+	for _, attrName := range attrNames {
+		b.WriteString(g.primitiveAttributeString(attrName, e.Values[attrName]))
+	}
+
+	s, err := g.providerBlockString(e.Provider, b.String())
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
@@ -0,0 +1,65 @@
+package terraconf_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Provider references parse from the formats terraform recorded them in over time.
+func TestParseProviderRef(t *testing.T) {
+	tests := map[string]string{
+		"provider.aws":                      "aws",
+		"provider.aws.us_east_1":            "aws.us_east_1",
+		"module.vpc.provider.aws.us_east_1": "aws.us_east_1",
+		`provider["registry.terraform.io/hashicorp/aws"].us_east_1`: "aws.us_east_1",
+		"aws.us_east_1": "aws.us_east_1",
+	}
+
+	for s, expected := range tests {
+		p := terraconf.ParseProviderRef(s)
+		if p == nil || p.String() != expected {
+			t.Errorf("%s: expected %s, got %v", s, expected, p)
+		}
+	}
+
+	if p := terraconf.ParseProviderRef(""); p != nil {
+		t.Errorf("expected no provider for an empty reference, got %v", p)
+	}
+}
+
+// Provider blocks follow the syntax and line endings of the options like the resources using them.
+func TestProviderBlocksString(t *testing.T) {
+	bucket := terraconftest.NewResourceState("aws_s3_bucket", "logs", map[string]interface{}{"bucket": "logs"})
+	bucket.Provider = "provider.aws.east"
+	replica := terraconftest.NewResourceState("aws_s3_bucket", "replica", map[string]interface{}{"bucket": "replica"})
+	replica.Provider = "provider.aws.west"
+
+	state := terraconftest.NewState(map[string]*terraform.ResourceState{
+		"aws_s3_bucket.logs":    bucket,
+		"aws_s3_bucket.replica": replica,
+		"aws_s3_bucket.default": terraconftest.NewResourceState("aws_s3_bucket", "default", map[string]interface{}{"bucket": "default"}),
+	})
+
+	tests := []struct {
+		name     string
+		syntax   terraconf.Syntax
+		newlines terraconf.NewlineMode
+		expected string
+	}{
+		{"hcl1", terraconf.SyntaxHCL1, terraconf.NewlineLF, "provider \"aws\" {\n  alias = \"east\"\n}\n\nprovider \"aws\" {\n  alias = \"west\"\n}\n"},
+		{"hcl2 crlf", terraconf.SyntaxHCL2, terraconf.NewlineCRLF, "provider \"aws\" {\r\n  alias = \"east\"\r\n}\r\n\r\nprovider \"aws\" {\r\n  alias = \"west\"\r\n}\r\n"},
+	}
+
+	for _, test := range tests {
+		opts := terraconf.NewOptions()
+		opts.Syntax = test.syntax
+		opts.Newlines = test.newlines
+
+		if actual := terraconf.NewGenerator(opts).ProviderBlocksString(state); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Pulumi type tokens of common resource types, used by PulumiExporter when its own types don't map a
+// resource type. The bridged providers group resources by service, which can't be derived from the
+// terraform type.
+var PulumiResourceTypes = map[string]string{
+	"aws_instance":                       "aws:ec2/instance:Instance",
+	"aws_vpc":                            "aws:ec2/vpc:Vpc",
+	"aws_subnet":                         "aws:ec2/subnet:Subnet",
+	"aws_security_group":                 "aws:ec2/securityGroup:SecurityGroup",
+	"aws_internet_gateway":               "aws:ec2/internetGateway:InternetGateway",
+	"aws_route_table":                    "aws:ec2/routeTable:RouteTable",
+	"aws_eip":                            "aws:ec2/eip:Eip",
+	"aws_s3_bucket":                      "aws:s3/bucket:Bucket",
+	"aws_iam_role":                       "aws:iam/role:Role",
+	"aws_iam_policy":                     "aws:iam/policy:Policy",
+	"aws_iam_user":                       "aws:iam/user:User",
+	"aws_lambda_function":                "aws:lambda/function:Function",
+	"aws_db_instance":                    "aws:rds/instance:Instance",
+	"aws_route53_zone":                   "aws:route53/zone:Zone",
+	"aws_route53_record":                 "aws:route53/record:Record",
+	"aws_sqs_queue":                      "aws:sqs/queue:Queue",
+	"aws_sns_topic":                      "aws:sns/topic:Topic",
+	"aws_dynamodb_table":                 "aws:dynamodb/table:Table",
+	"aws_cloudwatch_log_group":           "aws:cloudwatch/logGroup:LogGroup",
+	"google_compute_instance":            "gcp:compute/instance:Instance",
+	"google_compute_network":             "gcp:compute/network:Network",
+	"google_compute_subnetwork":          "gcp:compute/subnetwork:Subnetwork",
+	"google_storage_bucket":              "gcp:storage/bucket:Bucket",
+	"azurerm_resource_group":             "azure:core/resourceGroup:ResourceGroup",
+	"azurerm_virtual_network":            "azure:network/virtualNetwork:VirtualNetwork",
+	"azurerm_linux_virtual_machine":      "azure:compute/linuxVirtualMachine:LinuxVirtualMachine",
+	"azurerm_storage_account":            "azure:storage/account:Account",
+	"kubernetes_namespace":               "kubernetes:core/v1:Namespace",
+	"kubernetes_deployment":              "kubernetes:apps/v1:Deployment",
+	"kubernetes_service":                 "kubernetes:core/v1:Service",
+	"kubernetes_config_map":              "kubernetes:core/v1:ConfigMap",
+	"kubernetes_secret":                  "kubernetes:core/v1:Secret",
+	"kubernetes_service_account":         "kubernetes:core/v1:ServiceAccount",
+	"kubernetes_persistent_volume_claim": "kubernetes:core/v1:PersistentVolumeClaim",
+}
+
+// A resource of a Pulumi import file, as read by `pulumi import --file`.
+type PulumiImportResource struct {
+	Type string `json:"type" yaml:"type"`
+	Name string `json:"name" yaml:"name"`
+	ID   string `json:"id" yaml:"id"`
+}
+
+type PulumiImport struct {
+	Resources []*PulumiImportResource `json:"resources"`
+}
+
+// Exports managed resources as a Pulumi import file, for migrating a stack from terraform to Pulumi.
+// Resources are named like HCLExporter names them, prefixed with their module names so names are
+// unique across modules. Data resources are left out.
+type PulumiExporter struct {
+	// Pulumi type tokens by resource type, taking precedence over PulumiResourceTypes.
+	Types map[string]string
+
+	generator *Generator
+}
+
+// Creates an exporter naming resources with the remap rules of the given generator, or one with the
+// default options when nil.
+func NewPulumiExporter(g *Generator) *PulumiExporter {
+	if g == nil {
+		g = NewGenerator(nil)
+	}
+
+	return &PulumiExporter{Types: map[string]string{}, generator: g}
+}
+
+func (e *PulumiExporter) Name() string {
+	return "pulumi"
+}
+
+// Writes the import file of the resources. Resources without a primary instance or a known Pulumi
+// type are skipped and returned as GenerationFailures once the file has been written.
+func (e *PulumiExporter) Export(resources []*ResourceInstance, w io.Writer) error {
+	spec, failures := e.Import(resources)
+
+	b, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+
+	return nil
+}
+
+// Builds the import file of the resources, returning the resources left out, see Export.
+func (e *PulumiExporter) Import(resources []*ResourceInstance) (*PulumiImport, GenerationFailures) {
+	spec := &PulumiImport{Resources: []*PulumiImportResource{}}
+	failures := GenerationFailures{}
+
+	for _, r := range resources {
+		if r.Key.Data {
+			continue
+		}
+		if r.State.Primary == nil {
+			failures.Add(r.Address(), r.Key.Type, fmt.Errorf("%s resource has no primary instance", r.Key.Type))
+			continue
+		}
+
+		pulumiType, ok := e.Types[r.Key.Type]
+		if !ok {
+			pulumiType, ok = PulumiResourceTypes[r.Key.Type]
+		}
+		if !ok {
+			failures.Add(r.Address(), r.Key.Type, fmt.Errorf("no Pulumi type for %s", r.Key.Type))
+			continue
+		}
+
+		spec.Resources = append(spec.Resources, &PulumiImportResource{
+			Type: pulumiType,
+			Name: e.generator.qualifiedName(r),
+			ID:   r.State.Primary.ID,
+		})
+	}
+
+	return spec, failures
+}
+
+// Renders a Pulumi YAML program declaring the resources of an import file with the import option set,
+// as a baseline to start the migrated program from. `pulumi up` imports them, after the properties
+// reported by `pulumi preview` have been filled in.
+func PulumiProgramStub(project string, spec *PulumiImport) (string, error) {
+	type resourceOptions struct {
+		Import string `yaml:"import"`
+	}
+	type resource struct {
+		Type       string                 `yaml:"type"`
+		Properties map[string]interface{} `yaml:"properties"`
+		Options    resourceOptions        `yaml:"options"`
+	}
+
+	resources := yaml.MapSlice{}
+	for _, r := range spec.Resources {
+		resources = append(resources, yaml.MapItem{Key: r.Name, Value: &resource{
+			Type:       r.Type,
+			Properties: map[string]interface{}{},
+			Options:    resourceOptions{Import: r.ID},
+		}})
+	}
+
+	b, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "name", Value: project},
+		{Key: "runtime", Value: "yaml"},
+		{Key: "resources", Value: resources},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,206 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Name of the reference map written alongside generated files.
+const ReferencesFileName = "references.json"
+
+const referencesVersion = 1
+
+// An attribute of one resource found to hold the ID of another, e.g. the vpc_id of a subnet.
+type Reference struct {
+	// Addresses of the resources in the state.
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Path of the value within the referencing resource, e.g. `vpc_id` or `security_groups.0`.
+	Attribute string `json:"attribute"`
+	// Expression the value is replaced with, e.g. `aws_vpc.main.id`.
+	Expression string `json:"expression"`
+}
+
+// The references between the resources of a module found by Generator.LinkResources.
+type ResourceLinks struct {
+	References []*Reference
+
+	// Reference expressions by resource ID.
+	expressionsByID map[string]string
+}
+
+// Finds attributes of the resources of a module (keyed as in modules[].resources) holding the ID of
+// another resource of the module, so they can be replaced with references and terraform builds the
+// correct dependency graph. String attributes and the string elements of list attributes are linked.
+// IDs shared by several resources are ambiguous and never linked. Resources are referred to by their
+// address in the config the generator renders, see ConfigAddresses, relative to the module they are
+// rendered in, e.g. `aws_instance.legacy_web[0].id`. Resources the generator doesn't render, e.g. those
+// rejected by the ResourceFilter option, are never referred to.
+func (g *Generator) LinkResources(modulePath []string, resources map[string]*terraform.ResourceState) (*ResourceLinks, error) {
+	type target struct {
+		address    string
+		expression string
+	}
+
+	targets := map[string]*target{}
+	ambiguous := map[string]bool{}
+	keys := map[string]*ResourceKey{}
+
+	for rawKey := range resources {
+		k, err := ParseResourceKey(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		keys[rawKey] = k
+	}
+
+	instances, err := g.StateResources(&terraform.State{Modules: []*terraform.ModuleState{{Path: modulePath, Resources: resources}}})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, unit := range g.renderUnits(instances) {
+		for _, r := range unit.instances {
+			if r.State.Primary == nil || r.State.Primary.ID == "" {
+				continue
+			}
+
+			id := r.State.Primary.ID
+			if _, ok := targets[id]; ok {
+				ambiguous[id] = true
+				continue
+			}
+
+			_, ref := g.unitKey(r, unit.counted())
+			targets[id] = &target{
+				address:    r.Address(),
+				expression: ref.String() + tfStateKeyDelimiter + "id",
+			}
+		}
+	}
+
+	links := &ResourceLinks{expressionsByID: map[string]string{}}
+	for id, t := range targets {
+		if !ambiguous[id] {
+			links.expressionsByID[id] = t.expression
+		}
+	}
+
+	for rawKey, state := range resources {
+		if state.Primary == nil {
+			continue
+		}
+
+		from := ResourceAddress(modulePath, keys[rawKey])
+		attrs := expandAttributes(state.Primary.Attributes)
+
+		for attrName, v := range attrs {
+			if attrName == "id" {
+				continue
+			}
+
+			links.forEachLinked(attrName, v, state.Primary.ID, func(path string, id string) {
+				links.References = append(links.References, &Reference{
+					From:       from,
+					To:         targets[id].address,
+					Attribute:  path,
+					Expression: links.expressionsByID[id],
+				})
+			})
+		}
+	}
+
+	sort.Slice(links.References, func(i, j int) bool {
+		a, b := links.References[i], links.References[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.Attribute < b.Attribute
+	})
+
+	return links, nil
+}
+
+// Calls fn for every string value of an attribute, or element of a list attribute, holding the ID of
+// another resource than selfID.
+func (l *ResourceLinks) forEachLinked(attrName string, v interface{}, selfID string, fn func(path string, id string)) {
+	linked := func(v interface{}) (string, bool) {
+		s, ok := v.(string)
+		if !ok || s == selfID {
+			return "", false
+		}
+		_, ok = l.expressionsByID[s]
+		return s, ok
+	}
+
+	switch v := v.(type) {
+	case string:
+		if id, ok := linked(v); ok {
+			fn(attrName, id)
+		}
+	case []interface{}:
+		for i, item := range v {
+			if id, ok := linked(item); ok {
+				fn(fmt.Sprintf("%s%s%d", attrName, tfStateKeyDelimiter, i), id)
+			}
+		}
+	}
+}
+
+// Returns a transformer replacing linked IDs with references, to be added for AllResourceTypes to the
+// options of the generator rendering the module's resources.
+func (l *ResourceLinks) Transformer() ResourceTransformer {
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		// The expanded attributes still hold the id, which tells apart a resource's own ID.
+		selfID, _ := attrs["id"].(string)
+
+		for attrName, v := range attrs {
+			if attrName == "id" {
+				continue
+			}
+
+			switch v := v.(type) {
+			case string:
+				if v != selfID {
+					if expression, ok := l.expressionsByID[v]; ok {
+						attrs[attrName] = InterpolatedString("${" + expression + "}")
+					}
+				}
+			case []interface{}:
+				for i, item := range v {
+					if s, ok := item.(string); ok && s != selfID {
+						if expression, ok := l.expressionsByID[s]; ok {
+							v[i] = InterpolatedString("${" + expression + "}")
+						}
+					}
+				}
+			}
+		}
+
+		return attrs
+	}
+}
+
+// Writes the references as indented JSON, e.g. to ReferencesFileName to visualize or audit linking.
+func (l *ResourceLinks) WriteJSON(w io.Writer) error {
+	references := l.References
+	if references == nil {
+		references = []*Reference{}
+	}
+
+	b, err := json.MarshalIndent(&struct {
+		Version    int          `json:"version"`
+		References []*Reference `json:"references"`
+	}{referencesVersion, references}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,60 @@
+package terraconf_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// References follow the names resources are rendered with, after remapping and collapsing counts.
+func TestLinkResourcesConfigNames(t *testing.T) {
+	resources := map[string]*terraform.ResourceState{
+		"aws_vpc.main":  terraconftest.NewResourceState("aws_vpc", "vpc-0123", map[string]interface{}{"cidr_block": "10.0.0.0/16"}),
+		"aws_eip.nat.0": terraconftest.NewResourceState("aws_eip", "eipalloc-0", map[string]interface{}{"vpc": "true"}),
+		"aws_eip.nat.1": terraconftest.NewResourceState("aws_eip", "eipalloc-1", map[string]interface{}{"vpc": "true"}),
+		"aws_subnet.a":  terraconftest.NewResourceState("aws_subnet", "subnet-0123", map[string]interface{}{"vpc_id": "vpc-0123"}),
+		"aws_nat_gateway.a": terraconftest.NewResourceState("aws_nat_gateway", "nat-0123", map[string]interface{}{
+			"allocation_id": "eipalloc-1",
+			"subnet_id":     "subnet-0123",
+		}),
+	}
+
+	opts := terraconf.NewOptions()
+	opts.RemapRules = []*terraconf.RemapRule{{NamePrefix: "legacy_"}}
+
+	links, err := terraconf.NewGenerator(opts).LinkResources([]string{"root"}, resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expressions := map[string]string{}
+	for _, ref := range links.References {
+		expressions[ref.From+" "+ref.Attribute] = ref.Expression
+	}
+
+	expected := map[string]string{
+		"aws_nat_gateway.a allocation_id": "aws_eip.legacy_nat[1].id",
+		"aws_nat_gateway.a subnet_id":     "aws_subnet.legacy_a.id",
+		"aws_subnet.a vpc_id":             "aws_vpc.legacy_main.id",
+	}
+	if !reflect.DeepEqual(expressions, expected) {
+		t.Errorf("expected %v, got %v", expected, expressions)
+	}
+
+	// Without collapsing, instances are rendered under names of their own.
+	opts.CollapseCounts = false
+
+	links, err = terraconf.NewGenerator(opts).LinkResources([]string{"root"}, resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range links.References {
+		if ref.Attribute == "allocation_id" && ref.Expression != "aws_eip.legacy_nat_1.id" {
+			t.Errorf("expected aws_eip.legacy_nat_1.id, got %s", ref.Expression)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package terraconf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// Query evaluated by LoadRegoPolicy when none is given: the deny set of the terraconf package.
+const DefaultRegoPolicyQuery = "data.terraconf.deny"
+
+// What happens to resources violating the Rego policy of the options.
+type PolicyAction int
+
+const (
+	// Generate the resource and record the violations, see Generator.PolicyViolations.
+	PolicyWarn PolicyAction = iota
+	// Generate the resource preceded by a comment per violation, and record the violations.
+	PolicyAnnotate
+	// Skip the resource and report it as a GenerationFailure.
+	PolicyFail
+)
+
+// User supplied Rego policies generated resources are checked against, e.g. to enforce tagging or
+// naming conventions on adopted resources. Safe for concurrent use.
+type RegoPolicy struct {
+	query rego.PreparedEvalQuery
+}
+
+// Loads the Rego policies in the given files or directories. The query, or DefaultRegoPolicyQuery when
+// empty, is evaluated once per resource with its IRResource as input and must result in a set or
+// array of violation messages, e.g.
+//
+//	package terraconf
+//
+//	deny[msg] {
+//		input.type == "aws_s3_bucket"
+//		not has_tag(input, "owner")
+//		msg := "buckets must have an owner tag"
+//	}
+func LoadRegoPolicy(ctx context.Context, query string, paths ...string) (*RegoPolicy, error) {
+	if query == "" {
+		query = DefaultRegoPolicyQuery
+	}
+
+	prepared, err := rego.New(rego.Query(query), rego.Load(paths, nil)).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading policies: %s", err)
+	}
+
+	return &RegoPolicy{query: prepared}, nil
+}
+
+// Returns the violation messages of a resource, sorted.
+func (p *RegoPolicy) Evaluate(ctx context.Context, r *IRResource) ([]string, error) {
+	results, err := p.query.Eval(ctx, rego.EvalInput(r))
+	if err != nil {
+		return nil, fmt.Errorf("evaluating policies: %s", err)
+	}
+
+	messages := []string{}
+	for _, result := range results {
+		for _, expr := range result.Expressions {
+			values, ok := expr.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("policy query %s must result in a set or array, got %T", expr.Text, expr.Value)
+			}
+
+			for _, v := range values {
+				messages = append(messages, fmt.Sprint(v))
+			}
+		}
+	}
+	sort.Strings(messages)
+
+	return messages, nil
+}
+
+// The policy violations of a generated resource.
+type PolicyViolation struct {
+	Address  string
+	Type     string
+	Messages []string
+}
+
+// Returns the resources generated so far that violate the Rego policy of the options, in generation
+// order. Resources skipped by PolicyFail are reported as GenerationFailures instead.
+func (g *Generator) PolicyViolations() []*PolicyViolation {
+	g.violationsMu.Lock()
+	defer g.violationsMu.Unlock()
+
+	return append([]*PolicyViolation(nil), g.violations...)
+}
+
+// Checks a resource against the Rego policy of the options, returning the comments to precede its
+// block with.
+func (g *Generator) checkPolicy(r *ResourceInstance, ir *IRResource) (string, error) {
+	if g.opts.RegoPolicy == nil {
+		return "", nil
+	}
+
+	messages, err := g.opts.RegoPolicy.Evaluate(context.Background(), ir)
+	if err != nil || len(messages) == 0 {
+		return "", err
+	}
+
+	if g.opts.PolicyAction == PolicyFail {
+		return "", fmt.Errorf("violates policy: %s", strings.Join(messages, "; "))
+	}
+
+	g.violationsMu.Lock()
+	g.violations = append(g.violations, &PolicyViolation{Address: r.Address(), Type: r.Key.Type, Messages: messages})
+	g.violationsMu.Unlock()
+
+	if g.opts.PolicyAction != PolicyAnnotate {
+		return "", nil
+	}
+
+	var b strings.Builder
+	for _, message := range messages {
+		// Comments end at the line end.
+		fmt.Fprintf(&b, "# policy violation: %s%s", strings.Join(strings.Fields(message), " "), g.newline())
+	}
+
+	return b.String(), nil
+}
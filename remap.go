@@ -0,0 +1,161 @@
+package terraconf
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Rewrites the address resources are generated at, e.g. to prefix every name with `legacy_` or to
+// move the resources of module.app1 to the root module. See Options.RemapRules.
+type RemapRule struct {
+	// Module names from the root, as in Address, of the module the rule applies to, including its
+	// child modules. Empty matches every module.
+	Module []string
+	// Resource type the rule applies to, or empty for every type.
+	Type string
+
+	// Moves matching resources from Module to ToModule, e.g. an empty ToModule moves them to the root
+	// module. Child modules of Module keep their path below ToModule.
+	MoveModule bool
+	ToModule   []string
+
+	NamePrefix string
+	NameSuffix string
+}
+
+// Returns the module names of a legacy module path, without the root module.
+func moduleNames(path []string) []string {
+	if len(path) > 0 && path[0] == "root" {
+		return path[1:]
+	}
+
+	return path
+}
+
+func hasModulePrefix(module []string, prefix []string) bool {
+	if len(prefix) > len(module) {
+		return false
+	}
+
+	for i, name := range prefix {
+		if module[i] != name {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Applies the remap rules of the options to a resource instance, returning the module names and
+// resource name it is generated at.
+func (g *Generator) remapResource(r *ResourceInstance) ([]string, string) {
+	return g.remapName(r, r.Key.InstanceName())
+}
+
+// Applies the remap rules of the options to a resource instance generated under the given name, e.g.
+// the name shared by the instances of a counted resource.
+func (g *Generator) remapName(r *ResourceInstance, name string) ([]string, string) {
+	module := moduleNames(r.ModulePath)
+
+	for _, rule := range g.opts.RemapRules {
+		if rule.Type != "" && rule.Type != r.Key.Type {
+			continue
+		}
+		if !hasModulePrefix(module, rule.Module) {
+			continue
+		}
+
+		if rule.MoveModule {
+			module = append(append([]string{}, rule.ToModule...), module[len(rule.Module):]...)
+		}
+		name = rule.NamePrefix + name + rule.NameSuffix
+	}
+
+	return module, name
+}
+
+// Returns the name a resource instance is generated with: its instance name, see
+// ResourceKey.InstanceName, with the remap rules of the options applied.
+func (g *Generator) configName(r *ResourceInstance) string {
+	_, name := g.remapResource(r)
+	return name
+}
+
+// Returns the name of a resource instance prefixed with the names of the module it is generated in,
+// e.g. `vpc_main` for `module.vpc.aws_vpc.main`, for exporters needing names unique across modules.
+func (g *Generator) qualifiedName(r *ResourceInstance) string {
+	module, name := g.remapResource(r)
+	return strings.Join(append(append([]string{}, module...), name), "_")
+}
+
+// Returns the address of a resource instance in the generated config, with the remap rules of the
+// options applied, e.g. `aws_instance.legacy_web_0` for `module.app1.aws_instance.web[0]` when
+// module.app1 is moved to the root module and names are prefixed with `legacy_`. Instances collapsed
+// into a block with count, see Options.CollapseCounts, are not known from a single instance; use
+// ConfigAddresses for them.
+func (g *Generator) ConfigAddress(r *ResourceInstance) string {
+	return g.unitAddress(r, false)
+}
+
+// Returns the addresses of resource instances in the generated config like ConfigAddress, keeping the
+// index of instances collapsed into a block with count, e.g. `aws_instance.legacy_web[0]`. Resources
+// must be sorted by address, e.g. by StateResources.
+func (g *Generator) ConfigAddresses(resources []*ResourceInstance) map[*ResourceInstance]string {
+	addresses := map[*ResourceInstance]string{}
+	for _, unit := range g.renderUnits(resources) {
+		for _, r := range unit.instances {
+			addresses[r] = g.unitAddress(r, unit.counted())
+		}
+	}
+
+	return addresses
+}
+
+// Returns the config address of a resource instance, either rendered on its own or collapsed into the
+// block of its counted resource.
+func (g *Generator) unitAddress(r *ResourceInstance, counted bool) string {
+	module, k := g.unitKey(r, counted)
+	return ResourceAddress(module, k)
+}
+
+// Returns the module names and key of a resource instance in the generated config, either rendered on
+// its own or collapsed into the block of its counted resource.
+func (g *Generator) unitKey(r *ResourceInstance, counted bool) ([]string, *ResourceKey) {
+	if !counted {
+		module, name := g.remapResource(r)
+		return module, &ResourceKey{Data: r.Key.Data, Type: r.Key.Type, Name: name}
+	}
+
+	module, name := g.remapName(r, r.Key.Name)
+	return module, &ResourceKey{Data: r.Key.Data, Type: r.Key.Type, Name: name, Index: r.Key.Index}
+}
+
+// Returns the moves needed for terraform to adopt the managed resources of a state at their addresses
+// in the generated config, see ConfigAddresses, sorted by their original address. The config of moved
+// modules has to be placed in the module they are moved to. Render the moves with MovedBlocksString.
+func (g *Generator) RemapMoves(state *terraform.State) ([]*ResourceMove, error) {
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := g.ConfigAddresses(resources)
+	moves := []*ResourceMove{}
+	for _, r := range resources {
+		if r.Key.Data || r.State.Primary == nil {
+			continue
+		}
+
+		if from, to := r.Address(), addresses[r]; from != to {
+			moves = append(moves, &ResourceMove{From: from, To: to})
+		}
+	}
+
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].From < moves[j].From
+	})
+
+	return moves, nil
+}
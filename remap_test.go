@@ -0,0 +1,56 @@
+package terraconf_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Resources of a module moved to the root module are renamed by the rule and moved from their state
+// address, while the resources the rule doesn't match stay where they are.
+func TestRemapMoves(t *testing.T) {
+	instance := func(id string) *terraform.ResourceState {
+		return terraconftest.NewResourceState("aws_instance", id, map[string]interface{}{"instance_type": "t3.micro"})
+	}
+
+	state := &terraform.State{
+		Version: terraform.StateVersion,
+		Modules: []*terraform.ModuleState{
+			{
+				Path:      []string{"root"},
+				Resources: map[string]*terraform.ResourceState{"aws_instance.web": instance("i-web")},
+			},
+			{
+				Path: []string{"root", "app1"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.web":   instance("i-app1"),
+					"aws_s3_bucket.logs": terraconftest.NewResourceState("aws_s3_bucket", "logs", map[string]interface{}{"bucket": "logs"}),
+				},
+			},
+		},
+	}
+
+	opts := terraconf.NewOptions()
+	opts.RemapRules = []*terraconf.RemapRule{{Module: []string{"app1"}, MoveModule: true, NamePrefix: "legacy_"}}
+
+	moves, err := terraconf.NewGenerator(opts).RemapMoves(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := []string{}
+	for _, m := range moves {
+		actual = append(actual, m.From+" -> "+m.To)
+	}
+
+	expected := []string{
+		"module.app1.aws_instance.web -> aws_instance.legacy_web",
+		"module.app1.aws_s3_bucket.logs -> aws_s3_bucket.legacy_logs",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected moves %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,87 @@
+package terraconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Authentication for states read over HTTP(S) by ReadStateLocation.
+type HTTPStateOptions struct {
+	// Extra request headers, e.g. for an API gateway in front of the state.
+	Headers map[string]string
+	// Sent as a bearer token in the Authorization header when set.
+	Token string
+	// Sent as basic auth when set, as configured for terraform's http backend.
+	Username string
+	Password string
+
+	// Client to send the request with, or nil for http.DefaultClient.
+	Client *http.Client
+}
+
+// Reads a state from a file path or an http(s) URL, e.g. the address of terraform's http backend or
+// a presigned S3 URL, in any format ReadAnyState reads. URLs are fetched with a GET request carrying
+// the authentication in opts, which may be nil.
+func ReadStateLocation(ctx context.Context, location string, opts *HTTPStateOptions) (*StateModel, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return ReadAnyState(f)
+	}
+
+	body, err := fetchState(ctx, location, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ReadAnyState(body)
+}
+
+func fetchState(ctx context.Context, url string, opts *HTTPStateOptions) (io.ReadCloser, error) {
+	if opts == nil {
+		opts = &HTTPStateOptions{}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	if opts.Username != "" || opts.Password != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching state: %s", err)
+	}
+
+	// The http backend answers 204 No Content for a state that doesn't exist yet.
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching state: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,66 @@
+package terraconf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Decides whether a resource is generated, see Options.ResourceFilter.
+type ResourceFilter func(r *ResourceInstance) bool
+
+// Lists the resource instances of a state rejected by the ResourceFilter option, sorted by address,
+// e.g. to render removed blocks or a `terraform state rm` script for them. Data resources are only
+// included when the IncludeDataResources option is set.
+func (g *Generator) RemovedResources(state *terraform.State) ([]*ResourceInstance, error) {
+	if g.opts.ResourceFilter == nil {
+		return []*ResourceInstance{}, nil
+	}
+
+	return g.filterStateResources(state, false)
+}
+
+// Renders removed blocks, as understood by terraform 1.7 and later, dropping the given resources from
+// the state without destroying them. Removed blocks address whole resources, so instances of the same
+// resource share a block. Data resources are skipped since they are never managed. The output is
+// formatted by hand like MovedBlocksString.
+func RemovedBlocksString(resources []*ResourceInstance) string {
+	var b strings.Builder
+	seen := map[string]bool{}
+
+	for _, r := range resources {
+		if r.Key.Data {
+			continue
+		}
+
+		from := ResourceAddress(r.ModulePath, &ResourceKey{Type: r.Key.Type, Name: r.Key.Name})
+		if seen[from] {
+			continue
+		}
+		seen[from] = true
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "removed {\n  from = %s\n\n  lifecycle {\n    destroy = false\n  }\n}\n", from)
+	}
+
+	return b.String()
+}
+
+// Renders a script removing the given resources from the state with `terraform state rm`, for
+// terraform versions without removed blocks.
+func StateRemoveScriptString(resources []*ResourceInstance) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\nset -e\n\n")
+
+	for _, r := range resources {
+		if r.Key.Data {
+			continue
+		}
+		fmt.Fprintf(&b, "terraform state rm %s\n", shellQuote(r.Address()))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Extension of the files LoadResourceTemplates reads, named after the resource type they render.
+const ResourceTemplateExtension = ".tmpl"
+
+// A text/template rendering the whole block of a resource type in place of the generic renderer, as
+// an escape hatch for types it handles poorly. Templates are executed with the IRResource of the
+// resource and can use the functions of ValueTemplateFuncs along with:
+//
+//	value      renders a value as an HCL expression, e.g. `{{ value .ID }}`
+//	attribute  returns the value of an attribute by name, or nil, e.g. `{{ attribute . "ami" }}`
+//
+// The output is formatted like generated blocks.
+type ResourceTemplate struct {
+	template *template.Template
+}
+
+// Parses a resource template.
+func NewResourceTemplate(name string, text string) (*ResourceTemplate, error) {
+	funcs := template.FuncMap{
+		// Bound to the rendering generator on execution.
+		"value":     func(interface{}) (string, error) { return "", nil },
+		"attribute": resourceTemplateAttribute,
+	}
+	for k, f := range ValueTemplateFuncs {
+		funcs[k] = f
+	}
+
+	t, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing resource template %s: %s", name, err)
+	}
+
+	return &ResourceTemplate{template: t}, nil
+}
+
+// Reads the resource templates in dir, keyed by resource type, e.g. `aws_instance` for
+// `aws_instance.tmpl`.
+func LoadResourceTemplates(dir string) (map[string]*ResourceTemplate, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+ResourceTemplateExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := map[string]*ResourceTemplate{}
+	for _, path := range paths {
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		resourceType := strings.TrimSuffix(filepath.Base(path), ResourceTemplateExtension)
+		t, err := NewResourceTemplate(resourceType, string(text))
+		if err != nil {
+			return nil, err
+		}
+		templates[resourceType] = t
+	}
+
+	return templates, nil
+}
+
+func resourceTemplateAttribute(ir *IRResource, name string) interface{} {
+	for _, attr := range ir.Attributes {
+		if attr.Name == name {
+			return attr.Value
+		}
+	}
+
+	return nil
+}
+
+// Renders the block of a resource with its template.
+func (g *Generator) templateBlock(t *ResourceTemplate, ir *IRResource) (string, error) {
+	// Cloned so concurrent renders don't share the bound functions.
+	tmpl, err := t.template.Clone()
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(template.FuncMap{"value": g.templateValueString})
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, ir); err != nil {
+		return "", fmt.Errorf("executing resource template: %s", err)
+	}
+
+	s, err := g.format(b.String())
+	if err != nil {
+		return "", fmt.Errorf("formatting resource template output: %s", err)
+	}
+
+	return s, nil
+}
+
+// Renders a value as an HCL expression for resource templates.
+func (g *Generator) templateValueString(v interface{}) (string, error) {
+	if v == nil && g.opts.Syntax == SyntaxHCL2 {
+		return "null", nil
+	}
+	if err := checkValueType("value", v); err != nil {
+		return "", err
+	}
+
+	if g.opts.Syntax != SyntaxHCL2 && IsPrimitive(v) {
+		return g.primitiveValueString(v), nil
+	}
+
+	return string(g.hcl2ValueTokens(v).Bytes()), nil
+}
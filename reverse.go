@@ -0,0 +1,213 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// A resource block of existing configuration.
+type ConfigResource struct {
+	Type string
+	Name string
+
+	// Where the block was declared.
+	File string
+	Line int
+
+	// Attributes set to literal primitive values, in their flatmapped state form. Attributes set from
+	// expressions, e.g. references, are left out since they cannot be evaluated without the config.
+	attrs map[string]string
+}
+
+// Returns the address of the resource in config, e.g. `aws_instance.web`.
+func (r *ConfigResource) Address() string {
+	return r.Type + tfStateKeyDelimiter + r.Name
+}
+
+// Lists the resource blocks of a configuration file. Data blocks and nested blocks are ignored.
+func ParseConfigResources(filename string, src []byte) ([]*ConfigResource, error) {
+	f, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing %s: %s", filename, diags.Error())
+	}
+
+	resources := []*ConfigResource{}
+
+	for _, block := range f.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 {
+			continue
+		}
+
+		r := &ConfigResource{
+			Type:  block.Labels[0],
+			Name:  block.Labels[1],
+			File:  filename,
+			Line:  block.DefRange().Start.Line,
+			attrs: map[string]string{},
+		}
+
+		for name, attr := range block.Body.Attributes {
+			v, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				continue
+			}
+
+			if s, ok := configValueString(v); ok {
+				r.attrs[name] = s
+			}
+		}
+
+		resources = append(resources, r)
+	}
+
+	return resources, nil
+}
+
+// Renders a literal primitive value the way it is stored in flatmapped state.
+func configValueString(v cty.Value) (string, bool) {
+	if v.IsNull() || !v.IsKnown() {
+		return "", false
+	}
+
+	switch v.Type() {
+	case cty.String:
+		return v.AsString(), true
+	case cty.Number:
+		return v.AsBigFloat().Text('f', -1), true
+	case cty.Bool:
+		if v.True() {
+			return "true", true
+		}
+		return "false", true
+	}
+
+	return "", false
+}
+
+// The state resource a config resource was matched with. StateAddress is empty when no resource of the
+// state matched.
+type ImportMapping struct {
+	Config *ConfigResource
+
+	StateAddress string
+	ID           string
+
+	// Number of literal attributes of the config equal to the state.
+	MatchedAttributes int
+	// Set when another state resource matched as well, so the mapping needs to be checked by hand.
+	Ambiguous bool
+}
+
+type importCandidate struct {
+	config  int
+	address string
+	state   *terraform.ResourceState
+	score   int
+	matches int
+}
+
+// Matches resources of existing configuration to the resources of a state with the same type, by the
+// number of literal attributes set to the same value as in state. Each state resource is mapped at
+// most once, best matches first. Returns a mapping for every config resource, sorted by address, e.g.
+// to find the IDs to import before adopting a state.
+func MatchConfigResources(resources []*ConfigResource, state *terraform.State) ([]*ImportMapping, error) {
+	stateResources, err := resourcesByAddress(state)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*importCandidate{}
+	for i, r := range resources {
+		for address, s := range stateResources {
+			if s.Type != r.Type || s.Primary == nil {
+				continue
+			}
+
+			matches, mismatches := 0, 0
+			for name, v := range r.attrs {
+				if s.Primary.Attributes[name] == v {
+					matches++
+				} else {
+					mismatches++
+				}
+			}
+
+			if matches > mismatches {
+				candidates = append(candidates, &importCandidate{config: i, address: address, state: s, score: matches - mismatches, matches: matches})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.config != b.config {
+			return a.config < b.config
+		}
+		return a.address < b.address
+	})
+
+	mappings := make([]*ImportMapping, len(resources))
+	for i, r := range resources {
+		mappings[i] = &ImportMapping{Config: r}
+	}
+
+	mapped := map[string]bool{}
+	scores := map[int]int{}
+	for _, c := range candidates {
+		m := mappings[c.config]
+
+		if m.StateAddress != "" {
+			if c.score == scores[c.config] && !mapped[c.address] {
+				m.Ambiguous = true
+			}
+			continue
+		}
+		if mapped[c.address] {
+			continue
+		}
+
+		m.StateAddress = c.address
+		m.ID = c.state.Primary.ID
+		m.MatchedAttributes = c.matches
+		mapped[c.address] = true
+		scores[c.config] = c.score
+	}
+
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].Config.Address() < mappings[j].Config.Address()
+	})
+
+	return mappings, nil
+}
+
+// Renders the mappings as a table with one row per config resource.
+func ImportMappingReportString(mappings []*ImportMapping) string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "CONFIG ADDRESS\tSTATE ADDRESS\tID\tMATCHED ATTRIBUTES\tSOURCE")
+	for _, m := range mappings {
+		stateAddress := m.StateAddress
+		switch {
+		case stateAddress == "":
+			stateAddress = "-"
+		case m.Ambiguous:
+			stateAddress += " (ambiguous)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s:%d\n", m.Config.Address(), stateAddress, m.ID, m.MatchedAttributes, m.Config.File, m.Config.Line)
+	}
+	w.Flush()
+
+	return b.String()
+}
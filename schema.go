@@ -0,0 +1,239 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Provider schemas as output by `terraform providers schema -json`.
+type ProviderSchemas struct {
+	FormatVersion   string                     `json:"format_version"`
+	ProviderSchemas map[string]*ProviderSchema `json:"provider_schemas"`
+}
+
+type ProviderSchema struct {
+	Provider          *Schema            `json:"provider"`
+	ResourceSchemas   map[string]*Schema `json:"resource_schemas"`
+	DataSourceSchemas map[string]*Schema `json:"data_source_schemas"`
+}
+
+type Schema struct {
+	Version int          `json:"version"`
+	Block   *SchemaBlock `json:"block"`
+}
+
+type SchemaBlock struct {
+	Attributes map[string]*SchemaAttribute `json:"attributes"`
+	BlockTypes map[string]*SchemaBlockType `json:"block_types"`
+}
+
+type SchemaAttribute struct {
+	// Either a primitive type name such as "string", or a collection such as ["map","string"].
+	Type json.RawMessage `json:"type"`
+
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Optional    bool   `json:"optional"`
+	Computed    bool   `json:"computed"`
+	Sensitive   bool   `json:"sensitive"`
+	Deprecated  bool   `json:"deprecated"`
+}
+
+// Returns the name of the attribute's type, e.g. "string", "number", "bool", "list", "set", "map" or
+// "object".
+func (a *SchemaAttribute) TypeName() string {
+	var name string
+	if err := json.Unmarshal(a.Type, &name); err == nil {
+		return name
+	}
+
+	var collection []json.RawMessage
+	if err := json.Unmarshal(a.Type, &collection); err == nil && len(collection) > 0 {
+		if err := json.Unmarshal(collection[0], &name); err == nil {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Returns the name of the element type of a collection, e.g. "number" for ["map","number"], or "" for
+// primitives and collections of complex types.
+func (a *SchemaAttribute) ElementTypeName() string {
+	var collection []json.RawMessage
+	if err := json.Unmarshal(a.Type, &collection); err != nil || len(collection) < 2 {
+		return ""
+	}
+
+	var name string
+	if err := json.Unmarshal(collection[1], &name); err != nil {
+		return ""
+	}
+
+	return name
+}
+
+type SchemaBlockType struct {
+	// One of "single", "list", "set" or "map".
+	NestingMode string       `json:"nesting_mode"`
+	Block       *SchemaBlock `json:"block"`
+	MinItems    int          `json:"min_items"`
+	MaxItems    int          `json:"max_items"`
+}
+
+func ReadProviderSchemas(r io.Reader) (*ProviderSchemas, error) {
+	schemas := &ProviderSchemas{}
+	if err := json.NewDecoder(r).Decode(schemas); err != nil {
+		return nil, fmt.Errorf("reading provider schemas: %s", err)
+	}
+
+	return schemas, nil
+}
+
+// Returns the schema of a resource type, falling back to data sources of the same type name since
+// legacy resource states don't record their mode. Providers are searched in name order.
+func (s *ProviderSchemas) ResourceSchema(resourceType string) *Schema {
+	if s == nil {
+		return nil
+	}
+
+	providerNames := []string{}
+	for name := range s.ProviderSchemas {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
+	for _, name := range providerNames {
+		if schema, ok := s.ProviderSchemas[name].ResourceSchemas[resourceType]; ok {
+			return schema
+		}
+	}
+
+	for _, name := range providerNames {
+		if schema, ok := s.ProviderSchemas[name].DataSourceSchemas[resourceType]; ok {
+			return schema
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) resourceSchemaBlock(resourceType string) *SchemaBlock {
+	return g.typeConfig(resourceType).schemaBlock
+}
+
+// Renders an attribute as an argument or nested block depending on the schema of the enclosing block,
+// falling back to AttributeToString when the attribute is not in the schema.
+func SchemaAttributeToString(attrName string, attrRawVal interface{}, block *SchemaBlock) string {
+	return defaultGenerator.schemaAttributeString(attrName, attrRawVal, block)
+}
+
+func (g *Generator) schemaAttributeString(attrName string, attrRawVal interface{}, block *SchemaBlock) string {
+	if g.opts.Syntax == SyntaxHCL2 {
+		return g.hcl2AttributeString(attrName, attrRawVal, block)
+	}
+
+	if block == nil {
+		return g.attributeString(attrName, attrRawVal)
+	}
+
+	if _, ok := block.Attributes[attrName]; ok {
+		switch v := attrRawVal.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 && g.opts.EmptyValues == SkipEmptyValues {
+				return ""
+			}
+			return fmt.Sprintf("%s = %s\n", attrName, g.objectValueString(v))
+		case []interface{}:
+			if len(v) > 0 && !IsPrimitive(v[0]) {
+				var b strings.Builder
+				fmt.Fprintf(&b, "%s = [\n", attrName)
+				for _, item := range v {
+					b.WriteString(g.valueString(item) + ",\n")
+				}
+				b.WriteString("]\n")
+				return b.String()
+			}
+		}
+
+		return g.attributeString(attrName, attrRawVal)
+	}
+
+	if blockType, ok := block.BlockTypes[attrName]; ok {
+		switch v := attrRawVal.(type) {
+		case map[string]interface{}:
+			return g.nestedBlockString(attrName, v, blockType.Block)
+		case []interface{}:
+			var b strings.Builder
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					b.WriteString(g.nestedBlockString(attrName, m, blockType.Block))
+				}
+			}
+			return b.String()
+		}
+	}
+
+	return g.attributeString(attrName, attrRawVal)
+}
+
+func (g *Generator) nestedBlockString(blockName string, m map[string]interface{}, block *SchemaBlock) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s {\n", blockName)
+
+	for _, k := range sortedKeys(m) {
+		b.WriteString(g.schemaAttributeString(k, m[k], block))
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Renders a value as an expression, with maps as object constructors rather than blocks.
+func (g *Generator) valueString(rawValue interface{}) string {
+	if g.opts.Syntax == SyntaxHCL2 {
+		return strings.TrimSpace(string(g.hcl2ValueTokens(rawValue).Bytes()))
+	}
+
+	switch v := rawValue.(type) {
+	case map[string]interface{}:
+		return g.objectValueString(v)
+	case []interface{}:
+		var b strings.Builder
+		b.WriteString("[\n")
+		for _, item := range v {
+			b.WriteString(g.valueString(item) + ",\n")
+		}
+		b.WriteString("]")
+		return b.String()
+	}
+
+	return g.primitiveValueString(rawValue)
+}
+
+func (g *Generator) objectValueString(m map[string]interface{}) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+
+	for _, k := range sortedKeys(m) {
+		fmt.Fprintf(&b, "%s = %s\n", hclKeyString(k), g.valueString(m[k]))
+	}
+
+	b.WriteString("}")
+
+	return b.String()
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
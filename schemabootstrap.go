@@ -0,0 +1,237 @@
+package terraconf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Registry host and namespace assumed for providers named without a source, as in legacy states.
+const defaultProviderSourcePrefix = "registry.terraform.io/hashicorp/"
+
+// A provider whose schema is needed, by source address, e.g. `registry.terraform.io/hashicorp/aws`,
+// and the version constraint to fetch it for. An empty constraint allows any version.
+type ProviderRequirement struct {
+	Source  string
+	Version string
+}
+
+// Lists the providers used by the resources of a state, sorted by source, with their constraint in
+// constraints, keyed by source. States don't record the version of the providers that wrote them, so
+// only the constraints given pin versions. Providers named without a source, as in legacy states, are
+// assumed to be hashicorp providers.
+func StateProviderRequirements(state *StateModel, constraints map[string]string) []*ProviderRequirement {
+	sources := map[string]bool{}
+	for _, r := range state.Resources {
+		if source := providerSource(r.Provider, r.Type); source != "" {
+			sources[source] = true
+		}
+	}
+
+	requirements := []*ProviderRequirement{}
+	for source := range sources {
+		requirements = append(requirements, &ProviderRequirement{Source: source, Version: constraints[source]})
+	}
+	sort.Slice(requirements, func(i, j int) bool {
+		return requirements[i].Source < requirements[j].Source
+	})
+
+	return requirements
+}
+
+// Returns the source address of a provider as recorded in state, e.g.
+// `provider["registry.terraform.io/hashicorp/aws"].east` or `provider.aws`, falling back to the
+// provider of the resource type when none is recorded.
+func providerSource(provider string, resourceType string) string {
+	if i := strings.Index(provider, `["`); i >= 0 {
+		if end := strings.Index(provider[i:], `"]`); end >= 0 {
+			if source, err := strconv.Unquote(provider[i+1 : i+end+1]); err == nil {
+				if strings.Count(source, "/") == 1 {
+					source = "registry.terraform.io/" + source
+				}
+				return source
+			}
+		}
+	}
+
+	name := providerName(resourceType)
+	if p := ParseProviderRef(provider); p != nil {
+		name = p.Name
+	}
+	if name == "" {
+		return ""
+	}
+
+	return defaultProviderSourcePrefix + name
+}
+
+// Loads the schemas of the providers from the cache. Those not cached yet are fetched together by
+// bootstrapping a temporary workspace requiring them, running `terraform init` and
+// `terraform providers schema -json` with the terraform binary at execPath, and caching the schema of
+// every provider at the version terraform selected. Providers are only looked up in the cache before
+// bootstrapping when their constraint is an exact version. Requires terraform 0.13 or later.
+func (c *SchemaCache) Bootstrap(ctx context.Context, execPath string, providers []*ProviderRequirement) (*ProviderSchemas, error) {
+	cached := []*ProviderSchemas{}
+	missing := []*ProviderRequirement{}
+
+	for _, p := range providers {
+		if version, ok := exactVersion(p.Version); ok {
+			schemas, err := c.Load(p.Source, version)
+			if err != nil {
+				return nil, err
+			}
+			if schemas != nil {
+				cached = append(cached, schemas)
+				continue
+			}
+		}
+		missing = append(missing, p)
+	}
+
+	if len(missing) == 0 {
+		return MergeProviderSchemas(cached...), nil
+	}
+
+	fetched, err := c.fetchSchemas(ctx, execPath, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	return MergeProviderSchemas(append(cached, fetched)...), nil
+}
+
+// Fetches the schemas of the providers in a temporary workspace and caches them by the versions
+// recorded in its lock file.
+func (c *SchemaCache) fetchSchemas(ctx context.Context, execPath string, providers []*ProviderRequirement) (*ProviderSchemas, error) {
+	dir, err := ioutil.TempDir("", "terraconf-schemas")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "versions.tf"), []byte(requiredProvidersString(providers)), 0644); err != nil {
+		return nil, err
+	}
+
+	tf, err := tfexec.NewTerraform(dir, execPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tf.Init(ctx); err != nil {
+		return nil, fmt.Errorf("terraform init: %s", err)
+	}
+
+	raw, err := tf.ProvidersSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("terraform providers schema: %s", err)
+	}
+
+	// The schemas are read into terraconf's own types, which only know the parts it uses.
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	schemas := &ProviderSchemas{}
+	if err := json.Unmarshal(b, schemas); err != nil {
+		return nil, fmt.Errorf("reading provider schemas: %s", err)
+	}
+
+	versions, err := readLockFileVersions(filepath.Join(dir, ".terraform.lock.hcl"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range providers {
+		version, ok := versions[p.Source]
+		if !ok {
+			return nil, fmt.Errorf("terraform init did not select a version of %s", p.Source)
+		}
+		if err := c.Store(p.Source, version, schemas); err != nil {
+			return nil, err
+		}
+	}
+
+	return schemas, nil
+}
+
+// Renders a terraform block requiring the providers, named after the last part of their source.
+func requiredProvidersString(providers []*ProviderRequirement) string {
+	var b strings.Builder
+	b.WriteString("terraform {\n  required_providers {\n")
+
+	for i, p := range providers {
+		// Providers of different namespaces may share a name, only the first keeps it.
+		name := p.Source[strings.LastIndex(p.Source, "/")+1:]
+		for _, other := range providers[:i] {
+			if strings.HasSuffix(other.Source, "/"+name) {
+				name = sanitizeGroupName(strings.Replace(strings.TrimPrefix(p.Source, "registry.terraform.io/"), "/", "_", -1))
+				break
+			}
+		}
+
+		fmt.Fprintf(&b, "    %s = {\n      source = %q\n", name, strings.TrimPrefix(p.Source, "registry.terraform.io/"))
+		if p.Version != "" {
+			fmt.Fprintf(&b, "      version = %q\n", p.Version)
+		}
+		b.WriteString("    }\n")
+	}
+
+	b.WriteString("  }\n}\n")
+
+	return b.String()
+}
+
+// Reads the selected version of every provider from a dependency lock file, keyed by source.
+func readLockFileVersions(path string) (map[string]string, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, diags := hclsyntax.ParseConfig(src, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("reading lock file: %s", diags)
+	}
+
+	versions := map[string]string{}
+	for _, block := range f.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "provider" || len(block.Labels) != 1 {
+			continue
+		}
+
+		attr, ok := block.Body.Attributes["version"]
+		if !ok {
+			continue
+		}
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || !v.Type().Equals(cty.String) {
+			return nil, fmt.Errorf("reading lock file: invalid version of %s", block.Labels[0])
+		}
+
+		versions[block.Labels[0]] = v.AsString()
+	}
+
+	return versions, nil
+}
+
+// Returns the version of a constraint pinning a single version, e.g. `3.75.0` or `= 3.75.0`.
+func exactVersion(constraint string) (string, bool) {
+	version := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(constraint), "="))
+	if parts, ok := parseVersion(version); !ok || len(parts) != 3 || strings.ContainsAny(version, "-+") {
+		return "", false
+	}
+
+	return version, true
+}
@@ -0,0 +1,149 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A local cache of provider schemas keyed by provider source and version, so the schemas output by
+// `terraform providers schema -json` only have to be fetched once per provider version instead of
+// running terraform init in a scratch directory on every run.
+type SchemaCache struct {
+	Dir string
+}
+
+// Returns the default cache directory, `~/.terraconf/schemas`.
+func DefaultSchemaCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".terraconf", "schemas"), nil
+}
+
+// Creates a cache in dir, or the default directory when dir is empty.
+func NewSchemaCache(dir string) (*SchemaCache, error) {
+	if dir == "" {
+		defaultDir, err := DefaultSchemaCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = defaultDir
+	}
+
+	return &SchemaCache{Dir: dir}, nil
+}
+
+var unsafeSchemaPathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Returns the path of a cached schema, e.g. `<dir>/registry.terraform.io/hashicorp/aws/3.75.0.json`.
+func (c *SchemaCache) path(source string, version string) string {
+	parts := []string{c.Dir}
+	for _, part := range append(strings.Split(source, "/"), version+".json") {
+		part = unsafeSchemaPathChars.ReplaceAllString(part, "_")
+		// Parts must not leave the cache directory.
+		if part == "." || part == ".." {
+			part = "_"
+		}
+		parts = append(parts, part)
+	}
+
+	return filepath.Join(parts...)
+}
+
+// Returns the cached schema of a provider version, or nil when it isn't cached.
+func (c *SchemaCache) Load(source string, version string) (*ProviderSchemas, error) {
+	f, err := os.Open(c.path(source, version))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadProviderSchemas(f)
+}
+
+// Caches the schema of a provider version taken from schemas, which may hold other providers too.
+// The file is replaced atomically so concurrent runs never read a partial schema.
+func (c *SchemaCache) Store(source string, version string, schemas *ProviderSchemas) error {
+	schema, ok := schemas.ProviderSchemas[source]
+	if !ok {
+		return fmt.Errorf("no schema for provider %s", source)
+	}
+
+	b, err := json.Marshal(&ProviderSchemas{
+		FormatVersion:   schemas.FormatVersion,
+		ProviderSchemas: map[string]*ProviderSchema{source: schema},
+	})
+	if err != nil {
+		return err
+	}
+
+	path := c.path(source, version)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".schema")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Returns the cached schema of a provider version, calling fetch and caching its result when it
+// isn't cached yet, e.g. with a function running `terraform providers schema -json`.
+func (c *SchemaCache) Get(source string, version string, fetch func() (*ProviderSchemas, error)) (*ProviderSchemas, error) {
+	schemas, err := c.Load(source, version)
+	if err != nil || schemas != nil {
+		return schemas, err
+	}
+
+	schemas, err = fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Store(source, version, schemas); err != nil {
+		return nil, err
+	}
+
+	return c.Load(source, version)
+}
+
+// Combines the schemas of several providers, e.g. loaded from the cache one by one, into one set to
+// use as Options.Schemas. Later schemas win for providers present in several.
+func MergeProviderSchemas(schemas ...*ProviderSchemas) *ProviderSchemas {
+	merged := &ProviderSchemas{ProviderSchemas: map[string]*ProviderSchema{}}
+
+	for _, s := range schemas {
+		if s == nil {
+			continue
+		}
+
+		merged.FormatVersion = s.FormatVersion
+		for source, schema := range s.ProviderSchemas {
+			merged.ProviderSchemas[source] = schema
+		}
+	}
+
+	return merged
+}
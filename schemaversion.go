@@ -0,0 +1,87 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Key of the instance meta data the helper/schema SDK stores the resource schema version under.
+const schemaVersionMetaKey = "schema_version"
+
+// Returns the schema version a resource instance was stored with, or false when the state doesn't
+// record one.
+func StateSchemaVersion(state *terraform.ResourceState) (int, bool) {
+	if state.Primary == nil {
+		return 0, false
+	}
+
+	switch v := state.Primary.Meta[schemaVersionMetaKey].(type) {
+	case string:
+		version, err := strconv.Atoi(v)
+		return version, err == nil
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+
+	return 0, false
+}
+
+// A resource stored with a different schema version than the provider schemas have, so terraform
+// will upgrade or refresh it on the first plan after adoption.
+type SchemaVersionMismatch struct {
+	Address       string
+	Type          string
+	StateVersion  int
+	SchemaVersion int
+}
+
+// Compares the schema versions of resources with those of Options.Schemas. Resources without a
+// recorded version or schema are skipped.
+func (g *Generator) SchemaVersionMismatches(resources []*ResourceInstance) []*SchemaVersionMismatch {
+	mismatches := []*SchemaVersionMismatch{}
+
+	for _, r := range resources {
+		stateVersion, ok := StateSchemaVersion(r.State)
+		if !ok {
+			continue
+		}
+
+		schema := g.opts.Schemas.ResourceSchema(r.Key.Type)
+		if schema == nil || schema.Version == stateVersion {
+			continue
+		}
+
+		mismatches = append(mismatches, &SchemaVersionMismatch{
+			Address:       r.Address(),
+			Type:          r.Key.Type,
+			StateVersion:  stateVersion,
+			SchemaVersion: schema.Version,
+		})
+	}
+
+	return mismatches
+}
+
+// Renders the mismatches as a warning table, or an empty string when there are none.
+func SchemaVersionMismatchesString(mismatches []*SchemaVersionMismatch) string {
+	if len(mismatches) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ADDRESS\tTYPE\tSTATE\tPROVIDER")
+	for _, m := range mismatches {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", m.Address, m.Type, m.StateVersion, m.SchemaVersion)
+	}
+	w.Flush()
+
+	return fmt.Sprintf("%d resources have a different schema version than the provider, expect a state upgrade on the next plan:\n%s", len(mismatches), b.String())
+}
@@ -0,0 +1,133 @@
+package terraconf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Name of the example variables file listing the variables of stubbed secrets.
+const SecretsExampleFileName = "secrets.auto.tfvars.example"
+
+// Name of the file GetStateConfigString declares the variables of stubbed secrets in.
+const SecretVariablesFileName = "secrets.tf"
+
+// A variable standing in for a secret attribute of a generated resource when the StubSecrets option is
+// set, or for the credentials of a provider when the ProviderCredentialVariables option is. The state
+// value of the attribute is never rendered.
+type SecretVariable struct {
+	Name         string
+	ResourceType string
+	ResourceName string
+	// Set instead of the resource for provider credentials, whose variables are optional so
+	// credentials from the environment keep working.
+	Provider  string
+	Attribute string
+}
+
+// Renders a secret attribute as a reference to its variable and records the variable.
+func (g *Generator) secretAttributeString(resourceType string, resourceName string, attrName string) string {
+	return g.secretVariableString(&SecretVariable{
+		Name:         secretVariableName(resourceType, resourceName, attrName),
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		Attribute:    attrName,
+	})
+}
+
+// Renders the attribute of a secret variable as a reference to it and records the variable.
+func (g *Generator) secretVariableString(v *SecretVariable) string {
+	g.secretsMu.Lock()
+	g.secrets[v.Name] = v
+	g.secretsMu.Unlock()
+
+	if g.opts.Syntax == SyntaxHCL2 {
+		return fmt.Sprintf("%s = var.%s\n", v.Attribute, v.Name)
+	}
+
+	return fmt.Sprintf("%s = %s\n", v.Attribute, g.primitiveValueString(InterpolatedString("${var."+v.Name+"}")))
+}
+
+// Names the variable of a secret after the resource and attribute, e.g. `aws_db_instance_main_password`.
+// Instances of a counted resource are named after their index, e.g. `aws_db_instance_main_0_password`,
+// which keeps them from being collapsed into a block with count.
+func secretVariableName(resourceType string, resourceName string, attrName string) string {
+	parts := []string{}
+	for _, part := range []string{resourceType, resourceName, attrName} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return sanitizeGroupName(strings.Join(parts, "_"))
+}
+
+// Returns the variables of the secrets stubbed by the generator so far, sorted by name.
+func (g *Generator) SecretVariables() []*SecretVariable {
+	g.secretsMu.Lock()
+	defer g.secretsMu.Unlock()
+
+	variables := []*SecretVariable{}
+	for _, v := range g.secrets {
+		variables = append(variables, v)
+	}
+
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Name < variables[j].Name
+	})
+
+	return variables
+}
+
+// Renders the variable declarations for the given secret variables.
+func (g *Generator) SecretVariablesString(variables []*SecretVariable) string {
+	var b strings.Builder
+
+	for _, v := range variables {
+		resource := v.ResourceType
+		if v.ResourceName != "" {
+			resource += tfStateKeyDelimiter + v.ResourceName
+		}
+		if v.Provider != "" {
+			resource = "the " + v.Provider + " provider"
+		}
+
+		fmt.Fprintf(&b, "variable %q {\n", v.Name)
+		fmt.Fprintf(&b, "description = %s\n", g.primitiveValueString(fmt.Sprintf("%s of %s", v.Attribute, resource)))
+		if g.opts.Syntax == SyntaxHCL2 {
+			b.WriteString("sensitive = true\n")
+		}
+		// Providers treat null, or an empty string before HCL2, as unset and read the environment.
+		if v.Provider != "" {
+			if g.opts.Syntax == SyntaxHCL2 {
+				b.WriteString("default = null\n")
+			} else {
+				b.WriteString("default = \"\"\n")
+			}
+		}
+		b.WriteString("}\n\n")
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	s, err := g.format(b.String())
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// Renders the contents of SecretsExampleFileName, assigning an empty placeholder to every variable so
+// the real values can be filled in outside of version control.
+func (g *Generator) SecretsExampleString(variables []*SecretVariable) string {
+	var b strings.Builder
+
+	for _, v := range variables {
+		fmt.Fprintf(&b, "%s = \"\"\n", v.Name)
+	}
+
+	return g.convertNewlines(b.String())
+}
@@ -0,0 +1,91 @@
+package terraconf_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Secrets are rendered as references to variables named after the resource, which the generator
+// records for the variables file.
+func TestStubSecrets(t *testing.T) {
+	opts := terraconf.NewOptions()
+	opts.Syntax = terraconf.SyntaxHCL2
+	opts.StubSecrets = true
+	opts.TypeSecrets["aws_db_instance"] = terraconf.ResourceSecrets{"password": struct{}{}}
+	g := terraconf.NewGenerator(opts)
+
+	config, err := g.ResourceConfig(terraconftest.NewResourceState("aws_db_instance", "main", map[string]interface{}{
+		"engine":   "postgres",
+		"password": "hunter2",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(config, "password = var.aws_db_instance_main_password") || strings.Contains(config, "hunter2") {
+		t.Errorf("expected the password to reference its variable:\n%s", config)
+	}
+
+	names := []string{}
+	for _, v := range g.SecretVariables() {
+		names = append(names, v.Name)
+	}
+	if expected := []string{"aws_db_instance_main_password"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected variables %v, got %v", expected, names)
+	}
+}
+
+// Instances of a counted resource reference secret variables of their own, so they are rendered as a
+// block each rather than collapsed into a block with count.
+func TestStubSecretsCounted(t *testing.T) {
+	resources := map[string]*terraform.ResourceState{}
+	for i := 0; i < 2; i++ {
+		resources[fmt.Sprintf("aws_db_instance.main.%d", i)] = terraconftest.NewResourceState("aws_db_instance", fmt.Sprintf("main-%d", i), map[string]interface{}{
+			"engine":   "postgres",
+			"password": fmt.Sprintf("hunter%d", i),
+		})
+	}
+
+	opts := terraconf.NewOptions()
+	opts.Syntax = terraconf.SyntaxHCL2
+	opts.StubSecrets = true
+	opts.TypeSecrets["aws_db_instance"] = terraconf.ResourceSecrets{"password": struct{}{}}
+	g := terraconf.NewGenerator(opts)
+
+	files, err := g.OutputFiles(terraconftest.NewState(resources), terraconf.GroupByType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+
+	config := files[0].Content
+	for _, expected := range []string{
+		"resource \"aws_db_instance\" \"main_0\" {",
+		"password = var.aws_db_instance_main_0_password",
+		"resource \"aws_db_instance\" \"main_1\" {",
+		"password = var.aws_db_instance_main_1_password",
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected the config to contain %q:\n%s", expected, config)
+		}
+	}
+	if strings.Contains(config, "count") || strings.Contains(config, "hunter") {
+		t.Errorf("expected a block per instance without secrets:\n%s", config)
+	}
+
+	names := []string{}
+	for _, v := range g.SecretVariables() {
+		names = append(names, v.Name)
+	}
+	if expected := []string{"aws_db_instance_main_0_password", "aws_db_instance_main_1_password"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected variables %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,145 @@
+package terraconf
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const (
+	// Names of the scripts written to every part of a split state.
+	ImportScriptFileName    = "import.sh"
+	StateMoveScriptFileName = "state-mv.sh"
+)
+
+// A part of a state split by SplitState, generated into a directory of its own.
+type StateSplit struct {
+	Name      string
+	Resources []*ResourceInstance
+
+	// The config of the part, one file per resource type, followed by the import and state move
+	// scripts. Paths are relative to the directory of the part.
+	Files []*OutputFile
+}
+
+// Splits the resources of a state into parts by strategy, e.g. GroupByModule or GroupByTag, to divide
+// a monolithic stack into smaller ones. Every part gets its own config, a script importing its
+// resources into a new state, and, as an alternative, a script moving them out of the existing state
+// with `terraform state mv`. Parts are sorted by name. Resources that cannot be generated are left out
+// of the config and returned as GenerationFailures along with the parts.
+func (g *Generator) SplitState(state *terraform.State, strategy GroupingStrategy) ([]*StateSplit, error) {
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := GroupResources(resources, strategy)
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failures := GenerationFailures{}
+	splits := []*StateSplit{}
+	for _, name := range names {
+		addresses := []string{}
+		for _, r := range groups[name] {
+			addresses = append(addresses, r.Address())
+		}
+
+		part, err := ExtractState(state, addresses)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every part needs a generator of its own so include files don't leak between parts.
+		files, err := NewGenerator(g.opts).OutputFiles(part, GroupByType())
+		if err != nil {
+			partFailures, ok := err.(GenerationFailures)
+			if !ok {
+				return nil, err
+			}
+			failures = append(failures, partFailures...)
+		}
+
+		files = append(files,
+			&OutputFile{Path: ImportScriptFileName, Content: g.importScriptString(groups[name])},
+			&OutputFile{Path: StateMoveScriptFileName, Content: g.stateMoveScriptString(groups[name])},
+		)
+
+		splits = append(splits, &StateSplit{Name: name, Resources: groups[name], Files: files})
+	}
+
+	if len(failures) > 0 {
+		return splits, failures
+	}
+
+	return splits, nil
+}
+
+// Writes every part to a directory named after it within dir.
+func WriteStateSplits(dir string, splits []*StateSplit) error {
+	for _, split := range splits {
+		if err := WriteOutputFiles(filepath.Join(dir, split.Name), split.Files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the addresses of resources in the config of a part, which is a root module and names
+// resources like OutputFiles.
+func (g *Generator) splitConfigAddresses(resources []*ResourceInstance) map[*ResourceInstance]string {
+	addresses := map[*ResourceInstance]string{}
+	for _, unit := range g.renderUnits(resources) {
+		for _, r := range unit.instances {
+			_, k := g.unitKey(r, unit.counted())
+			addresses[r] = k.String()
+		}
+	}
+
+	return addresses
+}
+
+// Renders a script importing the managed resources of a part into the state of its directory.
+func (g *Generator) importScriptString(resources []*ResourceInstance) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n# Run in the directory of this part to import its resources into a new state.\nset -e\n\n")
+
+	addresses := g.splitConfigAddresses(resources)
+	for _, r := range resources {
+		if r.Key.Data || r.State.Primary == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "terraform import %s %s\n", shellQuote(addresses[r]), shellQuote(r.State.Primary.ID))
+	}
+
+	return b.String()
+}
+
+// Renders a script moving the managed resources of a part out of the existing state into the state
+// given by STATE_OUT, as an alternative to importing them.
+func (g *Generator) stateMoveScriptString(resources []*ResourceInstance) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n# Run in the directory of the original stack, with STATE_OUT set to the state file of this part.\nset -e\n: \"${STATE_OUT:?set STATE_OUT to the state file of this part}\"\n\n")
+
+	addresses := g.splitConfigAddresses(resources)
+	for _, r := range resources {
+		if r.Key.Data || r.State.Primary == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "terraform state mv -state-out=\"$STATE_OUT\" %s %s\n", shellQuote(r.Address()), shellQuote(addresses[r]))
+	}
+
+	return b.String()
+}
+
+// Quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
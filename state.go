@@ -0,0 +1,149 @@
+package terraconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Returns the format version of a JSON state file.
+func DetectStateVersion(src []byte) (int, error) {
+	var header struct {
+		Version *int `json:"version"`
+	}
+
+	if err := json.Unmarshal(src, &header); err != nil {
+		return 0, fmt.Errorf("reading state version: %s", err)
+	}
+
+	if header.Version == nil {
+		return 0, fmt.Errorf("reading state version: state has no version")
+	}
+
+	return *header.Version, nil
+}
+
+// Reads a state file written by any terraform version using state format 1 to 3, e.g. terraform 0.6 to
+// 0.11. Older formats are upgraded in memory to the current one, the file itself is left untouched.
+func ReadState(r io.Reader) (*terraform.State, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading state: %s", err)
+	}
+
+	version, err := DetectStateVersion(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if version < 1 || version > terraform.StateVersion {
+		return nil, fmt.Errorf("unsupported state version %d, expected 1 to %d", version, terraform.StateVersion)
+	}
+
+	// The terraform package upgrades versions 1 and 2 to the current version while reading.
+	state, err := terraform.ReadState(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("reading state version %d: %s", version, err)
+	}
+
+	return state, nil
+}
+
+// Returns a new state holding only the resources with the given addresses, e.g. `aws_instance.web`
+// or `module.vpc.aws_subnet.public[0]`, in the modules they were in, e.g. to split a stack into smaller
+// ones. Like in terraform, an address without an index selects every instance of the resource. The
+// new state gets its own lineage when written with terraform.WriteState; render its config with
+// Generator.OutputFiles.
+func ExtractState(state *terraform.State, addresses []string) (*terraform.State, error) {
+	selected := map[string]bool{}
+	for _, address := range addresses {
+		selected[address] = false
+	}
+
+	extracted := &terraform.State{Version: state.Version, TFVersion: state.TFVersion}
+
+	for _, module := range state.Modules {
+		var extractedModule *terraform.ModuleState
+
+		for rawKey, resource := range module.Resources {
+			k, err := ParseResourceKey(rawKey)
+			if err != nil {
+				return nil, err
+			}
+
+			address := ResourceAddress(module.Path, k)
+			groupAddress := ResourceAddress(module.Path, &ResourceKey{Data: k.Data, Type: k.Type, Name: k.Name})
+
+			matched := false
+			for _, a := range []string{address, groupAddress} {
+				if _, ok := selected[a]; ok {
+					selected[a] = true
+					matched = true
+				}
+			}
+			if !matched {
+				continue
+			}
+
+			if extractedModule == nil {
+				extractedModule = &terraform.ModuleState{Path: module.Path, Resources: map[string]*terraform.ResourceState{}}
+				extracted.Modules = append(extracted.Modules, extractedModule)
+			}
+			extractedModule.Resources[rawKey] = resource
+		}
+	}
+
+	for _, address := range addresses {
+		if !selected[address] {
+			return nil, fmt.Errorf("no resource %s in state", address)
+		}
+	}
+
+	return extracted, nil
+}
+
+// Returns a new state holding only the resources of newState missing from oldState, e.g. to generate
+// config for just the resources adopted outside terraform since the last sync. Resources are matched
+// by type and ID since their addresses can change between syncs, or by address when they have no
+// primary instance. Render the config of the delta with Generator.OutputFiles.
+func DeltaState(oldState *terraform.State, newState *terraform.State) *terraform.State {
+	existing := map[string]bool{}
+	for _, module := range oldState.Modules {
+		for key, resource := range module.Resources {
+			existing[deltaIdentity(module.Path, key, resource)] = true
+		}
+	}
+
+	delta := &terraform.State{Version: newState.Version, TFVersion: newState.TFVersion}
+
+	for _, module := range newState.Modules {
+		var deltaModule *terraform.ModuleState
+
+		for key, resource := range module.Resources {
+			if existing[deltaIdentity(module.Path, key, resource)] {
+				continue
+			}
+
+			if deltaModule == nil {
+				deltaModule = &terraform.ModuleState{Path: module.Path, Resources: map[string]*terraform.ResourceState{}}
+				delta.Modules = append(delta.Modules, deltaModule)
+			}
+			deltaModule.Resources[key] = resource
+		}
+	}
+
+	return delta
+}
+
+func deltaIdentity(modulePath []string, key string, resource *terraform.ResourceState) string {
+	if resource.Primary == nil || resource.Primary.ID == "" {
+		return "address\x00" + strings.Join(modulePath, tfStateKeyDelimiter) + tfStateKeyDelimiter + key
+	}
+
+	return "id\x00" + resource.Type + "\x00" + resource.Primary.ID
+}
@@ -0,0 +1,502 @@
+package terraconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// A state independent of the format it was read from. Build one with StateFromLegacy, ReadStateV4,
+// StateFromJSON or ReadAnyState. Only reading states goes through the model so far: generation, e.g.
+// GetStateConfigString, OutputFiles or ResourceConfig, still takes the legacy state structs of the
+// terraform version terraconf is built against, so convert the model with Legacy to generate config.
+type StateModel struct {
+	TerraformVersion string
+	Serial           int64
+	Lineage          string
+
+	// Sorted by address.
+	Resources []*ResourceModel
+}
+
+type ResourceModel struct {
+	// Module names from the root, as in Address.
+	Module []string
+
+	Data     bool
+	Type     string
+	Name     string
+	Provider string
+
+	// Sorted by index, with deposed instances after the current one of their index.
+	Instances []*InstanceModel
+}
+
+type InstanceModel struct {
+	// Index is nil for single instance resources, an int for count and a string for for_each.
+	Index interface{}
+
+	ID string
+	// Expanded attribute values, as transformers see them.
+	Attributes map[string]interface{}
+
+	// Schema version the instance was stored with, when recorded.
+	SchemaVersion *int
+	Dependencies  []string
+	Tainted       bool
+
+	// Key of a deposed instance, empty for the current instance.
+	DeposedKey string
+}
+
+// Returns the address of the resource, e.g. `module.vpc.aws_subnet.public`.
+func (r *ResourceModel) Address() string {
+	return ResourceAddress(r.Module, &ResourceKey{Data: r.Data, Type: r.Type, Name: r.Name})
+}
+
+// Returns the resource with the given module, mode, type and name, adding it to the state and to the
+// resources by address if needed.
+func (s *StateModel) resource(byAddress map[string]*ResourceModel, module []string, data bool, resourceType string, name string) *ResourceModel {
+	address := ResourceAddress(module, &ResourceKey{Data: data, Type: resourceType, Name: name})
+	if r, ok := byAddress[address]; ok {
+		return r
+	}
+
+	r := &ResourceModel{Module: module, Data: data, Type: resourceType, Name: name}
+	s.Resources = append(s.Resources, r)
+	byAddress[address] = r
+
+	return r
+}
+
+// Sorts resources by address and instances by index.
+func (s *StateModel) sort() {
+	sort.Slice(s.Resources, func(i, j int) bool {
+		return s.Resources[i].Address() < s.Resources[j].Address()
+	})
+
+	for _, r := range s.Resources {
+		sort.SliceStable(r.Instances, func(i, j int) bool {
+			a, b := r.Instances[i], r.Instances[j]
+			if a.Index != b.Index {
+				return lessInstanceIndex(a.Index, b.Index)
+			}
+			return a.DeposedKey == "" && b.DeposedKey != ""
+		})
+	}
+}
+
+// Converts a legacy state, e.g. as read by ReadState.
+func StateFromLegacy(state *terraform.State) (*StateModel, error) {
+	s := &StateModel{TerraformVersion: state.TFVersion, Serial: state.Serial, Lineage: state.Lineage}
+	byAddress := map[string]*ResourceModel{}
+
+	for _, module := range state.Modules {
+		for key, resource := range module.Resources {
+			k, err := ParseResourceKey(key)
+			if err != nil {
+				return nil, err
+			}
+
+			r := s.resource(byAddress, moduleNames(module.Path), k.Data, k.Type, k.Name)
+			r.Provider = resource.Provider
+
+			if resource.Primary != nil {
+				r.Instances = append(r.Instances, legacyInstanceModel(k.Index, resource, resource.Primary, ""))
+			}
+			for i, deposed := range resource.Deposed {
+				// Legacy states identify deposed instances by position only.
+				r.Instances = append(r.Instances, legacyInstanceModel(k.Index, resource, deposed, strconv.Itoa(i)))
+			}
+		}
+	}
+
+	s.sort()
+
+	return s, nil
+}
+
+func legacyInstanceModel(index interface{}, resource *terraform.ResourceState, instance *terraform.InstanceState, deposedKey string) *InstanceModel {
+	m := &InstanceModel{
+		Index:        index,
+		ID:           instance.ID,
+		Attributes:   expandAttributes(instance.Attributes),
+		Dependencies: resource.Dependencies,
+		Tainted:      instance.Tainted,
+		DeposedKey:   deposedKey,
+	}
+
+	if version, ok := StateSchemaVersion(&terraform.ResourceState{Primary: instance}); ok {
+		m.SchemaVersion = &version
+	}
+
+	return m
+}
+
+// The parts of a state in format version 4, as written by terraform 0.12 and later, read by
+// ReadStateV4.
+type stateV4 struct {
+	Version          int    `json:"version"`
+	TerraformVersion string `json:"terraform_version"`
+	Serial           int64  `json:"serial"`
+	Lineage          string `json:"lineage"`
+	Resources        []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Provider  string `json:"provider"`
+		Instances []struct {
+			IndexKey      interface{}            `json:"index_key"`
+			Status        string                 `json:"status"`
+			Deposed       string                 `json:"deposed"`
+			SchemaVersion *int                   `json:"schema_version"`
+			Attributes    map[string]interface{} `json:"attributes"`
+			Dependencies  []string               `json:"dependencies"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// Reads a state file in format version 4, as written by terraform 0.12 and later. Numbers are kept as
+// json.Number so large values don't lose precision.
+func ReadStateV4(r io.Reader) (*StateModel, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	v4 := &stateV4{}
+	if err := d.Decode(v4); err != nil {
+		return nil, fmt.Errorf("reading state: %s", err)
+	}
+	if v4.Version != 4 {
+		return nil, fmt.Errorf("unsupported state version %d, expected 4", v4.Version)
+	}
+
+	s := &StateModel{TerraformVersion: v4.TerraformVersion, Serial: v4.Serial, Lineage: v4.Lineage}
+	byAddress := map[string]*ResourceModel{}
+
+	for _, resource := range v4.Resources {
+		module, err := parseModuleAddress(resource.Module)
+		if err != nil {
+			return nil, err
+		}
+
+		r := s.resource(byAddress, module, resource.Mode == "data", resource.Type, resource.Name)
+		r.Provider = resource.Provider
+
+		for _, instance := range resource.Instances {
+			index, err := jsonInstanceIndex(instance.IndexKey)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", r.Address(), err)
+			}
+
+			r.Instances = append(r.Instances, &InstanceModel{
+				Index:         index,
+				ID:            jsonID(instance.Attributes),
+				Attributes:    instance.Attributes,
+				SchemaVersion: instance.SchemaVersion,
+				Dependencies:  instance.Dependencies,
+				Tainted:       instance.Status == "tainted",
+				DeposedKey:    instance.Deposed,
+			})
+		}
+	}
+
+	s.sort()
+
+	return s, nil
+}
+
+// Converts the state as read by terraform-exec, e.g. from `terraform show -json`.
+func StateFromJSON(state *tfjson.State) (*StateModel, error) {
+	s := &StateModel{TerraformVersion: state.TerraformVersion}
+	if state.Values == nil || state.Values.RootModule == nil {
+		return s, nil
+	}
+	byAddress := map[string]*ResourceModel{}
+
+	modules := []*tfjson.StateModule{state.Values.RootModule}
+	for len(modules) > 0 {
+		module := modules[0]
+		modules = append(modules[1:], module.ChildModules...)
+
+		names, err := parseModuleAddress(module.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range module.Resources {
+			r := s.resource(byAddress, names, resource.Mode == tfjson.DataResourceMode, resource.Type, resource.Name)
+			r.Provider = resource.ProviderName
+
+			index, err := jsonInstanceIndex(resource.Index)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", resource.Address, err)
+			}
+
+			version := int(resource.SchemaVersion)
+			r.Instances = append(r.Instances, &InstanceModel{
+				Index:         index,
+				ID:            jsonID(resource.AttributeValues),
+				Attributes:    resource.AttributeValues,
+				SchemaVersion: &version,
+				Dependencies:  resource.DependsOn,
+				Tainted:       resource.Tainted,
+				DeposedKey:    resource.DeposedKey,
+			})
+		}
+	}
+
+	s.sort()
+
+	return s, nil
+}
+
+// Formats of the files ReadAnyState reads.
+type InputFormat int
+
+const (
+	// A state file in format version 1 to 3, as written by terraform 0.11 and earlier.
+	InputLegacyState InputFormat = iota
+	// A state file in format version 4, as written by terraform 0.12 and later.
+	InputStateV4
+	// The output of `terraform show -json` for a state.
+	InputStateJSON
+	// The output of `terraform show -json` for a plan file.
+	InputPlanJSON
+)
+
+func (f InputFormat) String() string {
+	switch f {
+	case InputLegacyState:
+		return "legacy state"
+	case InputStateV4:
+		return "state v4"
+	case InputStateJSON:
+		return "state JSON"
+	case InputPlanJSON:
+		return "plan JSON"
+	}
+
+	return fmt.Sprintf("InputFormat(%d)", int(f))
+}
+
+// Tells the format of a file by its top level keys: state files have a version, the output of
+// `terraform show -json` a format version and, for plans, the planned values and changes.
+func DetectInputFormat(src []byte) (InputFormat, error) {
+	var header struct {
+		Version         *int            `json:"version"`
+		FormatVersion   string          `json:"format_version"`
+		PlannedValues   json.RawMessage `json:"planned_values"`
+		ResourceChanges json.RawMessage `json:"resource_changes"`
+		PriorState      json.RawMessage `json:"prior_state"`
+	}
+
+	if err := json.Unmarshal(src, &header); err != nil {
+		return 0, fmt.Errorf("detecting input format: %s", err)
+	}
+
+	switch {
+	case header.FormatVersion != "":
+		if header.PlannedValues != nil || header.ResourceChanges != nil || header.PriorState != nil {
+			return InputPlanJSON, nil
+		}
+		return InputStateJSON, nil
+	case header.Version == nil:
+		return 0, fmt.Errorf("detecting input format: neither a state nor the output of terraform show -json")
+	case *header.Version == 4:
+		return InputStateV4, nil
+	}
+
+	return InputLegacyState, nil
+}
+
+// Reads a state file in any format version, or the output of `terraform show -json` for a state or a
+// plan, telling them apart with DetectInputFormat. Plans are read as their prior state, the state they
+// were planned against. Legacy states are converted with StateFromLegacy. Files encrypted by sops or
+// age are decrypted first, see DecryptState.
+func ReadAnyState(r io.Reader) (*StateModel, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading state: %s", err)
+	}
+
+	src, err = DecryptState(context.Background(), src)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := DetectInputFormat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case InputStateV4:
+		return ReadStateV4(bytes.NewReader(src))
+	case InputStateJSON:
+		state := &tfjson.State{}
+		if err := json.Unmarshal(src, state); err != nil {
+			return nil, fmt.Errorf("reading state JSON: %s", err)
+		}
+		return StateFromJSON(state)
+	case InputPlanJSON:
+		plan := &tfjson.Plan{}
+		if err := json.Unmarshal(src, plan); err != nil {
+			return nil, fmt.Errorf("reading plan JSON: %s", err)
+		}
+		if plan.PriorState == nil {
+			return &StateModel{}, nil
+		}
+		return StateFromJSON(plan.PriorState)
+	}
+
+	state, err := ReadState(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	return StateFromLegacy(state)
+}
+
+// Returns the module names of a module address, e.g. ["vpc"] for `module.vpc`, or nil for the root
+// module. Module instances created with count or for_each are not supported.
+func parseModuleAddress(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	a, err := ParseAddress(s)
+	if err != nil {
+		return nil, err
+	}
+	if a.Resource != nil {
+		return nil, fmt.Errorf("invalid module address %q", s)
+	}
+
+	return a.Module, nil
+}
+
+// Returns the id attribute of an instance in JSON, or an empty string when it has none.
+func jsonID(attrs map[string]interface{}) string {
+	id, _ := attrs["id"].(string)
+	return id
+}
+
+// Converts the index of an instance in JSON, a number for count or a string for for_each.
+func jsonInstanceIndex(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string:
+		return v, nil
+	case json.Number:
+		n, err := strconv.Atoi(v.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %s", v)
+		}
+		return n, nil
+	case float64:
+		return int(v), nil
+	}
+
+	return nil, fmt.Errorf("invalid index %v", v)
+}
+
+// Converts the state into a legacy state for the functions taking one, flattening attributes the way
+// terraform 0.11 stores them. Resource dependencies are taken from the current instance.
+func (s *StateModel) Legacy() *terraform.State {
+	state := &terraform.State{Version: terraform.StateVersion, TFVersion: s.TerraformVersion, Serial: s.Serial, Lineage: s.Lineage}
+	modules := map[string]*terraform.ModuleState{}
+
+	for _, r := range s.Resources {
+		moduleAddress := ModuleAddress(r.Module)
+		module, ok := modules[moduleAddress]
+		if !ok {
+			module = &terraform.ModuleState{
+				Path:      append([]string{"root"}, r.Module...),
+				Resources: map[string]*terraform.ResourceState{},
+			}
+			modules[moduleAddress] = module
+			state.Modules = append(state.Modules, module)
+		}
+
+		for _, instance := range r.Instances {
+			key := legacyResourceKey(r, instance.Index)
+			resource, ok := module.Resources[key]
+			if !ok {
+				resource = &terraform.ResourceState{Type: r.Type, Provider: r.Provider}
+				module.Resources[key] = resource
+			}
+
+			legacy := &terraform.InstanceState{
+				ID:         instance.ID,
+				Attributes: map[string]string{},
+				Meta:       map[string]interface{}{},
+				Tainted:    instance.Tainted,
+			}
+			for attrName, v := range instance.Attributes {
+				flattenAttribute(attrName, v, legacy.Attributes)
+			}
+			if instance.SchemaVersion != nil {
+				legacy.Meta[schemaVersionMetaKey] = strconv.Itoa(*instance.SchemaVersion)
+			}
+
+			if instance.DeposedKey != "" {
+				resource.Deposed = append(resource.Deposed, legacy)
+				continue
+			}
+			resource.Primary = legacy
+			resource.Dependencies = instance.Dependencies
+		}
+	}
+
+	sort.Slice(state.Modules, func(i, j int) bool {
+		return ModuleAddress(state.Modules[i].Path) < ModuleAddress(state.Modules[j].Path)
+	})
+
+	return state
+}
+
+// Returns the key of an instance in modules[].resources of a legacy state, e.g. `aws_instance.web.0`.
+func legacyResourceKey(r *ResourceModel, index interface{}) string {
+	k := &ResourceKey{Data: r.Data, Type: r.Type, Name: r.Name, Index: index}
+	if n, ok := index.(int); ok {
+		return k.GroupKey() + tfStateKeyDelimiter + strconv.Itoa(n)
+	}
+
+	return k.String()
+}
+
+// Flattens an expanded value into flatmap attributes, the inverse of expandAttributes. Null values are
+// left out.
+func flattenAttribute(key string, v interface{}, flat map[string]string) {
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		flat[key+tfStateKeyDelimiter+"%"] = strconv.Itoa(len(v))
+		for k, item := range v {
+			flattenAttribute(key+tfStateKeyDelimiter+k, item, flat)
+		}
+	case []interface{}:
+		flat[key+tfStateKeyDelimiter+"#"] = strconv.Itoa(len(v))
+		for i, item := range v {
+			flattenAttribute(key+tfStateKeyDelimiter+strconv.Itoa(i), item, flat)
+		}
+	case string:
+		flat[key] = v
+	case bool:
+		flat[key] = strconv.FormatBool(v)
+	case json.Number:
+		flat[key] = v.String()
+	case float64:
+		flat[key] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		flat[key] = fmt.Sprint(v)
+	}
+}
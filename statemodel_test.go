@@ -0,0 +1,83 @@
+package terraconf_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jmseaton/terraconf"
+)
+
+const stateV4 = `{
+  "version": 4,
+  "terraform_version": "1.5.7",
+  "serial": 3,
+  "lineage": "b1c2d3",
+  "resources": [
+    {
+      "module": "module.network",
+      "mode": "managed",
+      "type": "aws_subnet",
+      "name": "private",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {"index_key": 1, "schema_version": 1, "attributes": {"id": "subnet-1", "cidr_block": "10.0.1.0/24", "tags": {"Name": "private-1"}}},
+        {"index_key": 0, "schema_version": 1, "attributes": {"id": "subnet-0", "cidr_block": "10.0.0.0/24", "tags": {"Name": "private-0"}}}
+      ]
+    }
+  ]
+}`
+
+const stateJSON = `{
+  "format_version": "1.0",
+  "terraform_version": "1.5.7",
+  "values": {
+    "root_module": {
+      "child_modules": [
+        {
+          "address": "module.network",
+          "resources": [
+            {"address": "module.network.aws_subnet.private[1]", "mode": "managed", "type": "aws_subnet", "name": "private", "index": 1, "provider_name": "registry.terraform.io/hashicorp/aws", "schema_version": 1, "values": {"id": "subnet-1", "cidr_block": "10.0.1.0/24", "tags": {"Name": "private-1"}}},
+            {"address": "module.network.aws_subnet.private[0]", "mode": "managed", "type": "aws_subnet", "name": "private", "index": 0, "provider_name": "registry.terraform.io/hashicorp/aws", "schema_version": 1, "values": {"id": "subnet-0", "cidr_block": "10.0.0.0/24", "tags": {"Name": "private-0"}}}
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+// State v4 files and the output of `terraform show -json` read into the same model, and convert to the
+// legacy state generation takes.
+func TestReadAnyState(t *testing.T) {
+	for name, src := range map[string]string{"state v4": stateV4, "state JSON": stateJSON} {
+		model, err := terraconf.ReadAnyState(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if len(model.Resources) != 1 {
+			t.Fatalf("%s: expected 1 resource, got %d", name, len(model.Resources))
+		}
+		r := model.Resources[0]
+		if r.Address() != "module.network.aws_subnet.private" || len(r.Instances) != 2 || r.Instances[0].Index != 0 || r.Instances[0].ID != "subnet-0" {
+			t.Errorf("%s: unexpected resource %s with instances %+v", name, r.Address(), r.Instances)
+		}
+
+		state := model.Legacy()
+		if len(state.Modules) != 1 || !reflect.DeepEqual(state.Modules[0].Path, []string{"root", "network"}) {
+			t.Fatalf("%s: expected the network module only, got %+v", name, state.Modules)
+		}
+
+		resource := state.Modules[0].Resources["aws_subnet.private.1"]
+		if resource == nil {
+			t.Fatalf("%s: expected legacy key aws_subnet.private.1, got %v", name, state.Modules[0].Resources)
+		}
+		expected := map[string]string{"id": "subnet-1", "cidr_block": "10.0.1.0/24", "tags.%": "1", "tags.Name": "private-1"}
+		if !reflect.DeepEqual(resource.Primary.Attributes, expected) {
+			t.Errorf("%s: expected flattened attributes %v, got %v", name, expected, resource.Primary.Attributes)
+		}
+		if version, ok := terraconf.StateSchemaVersion(resource); !ok || version != 1 {
+			t.Errorf("%s: expected schema version 1, got %d", name, version)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Counts of what a generation run would produce, without generating config.
+type StateSummary struct {
+	// Module addresses, with an empty string for the root module.
+	Modules []string
+	Types   []*TypeSummary
+}
+
+type TypeSummary struct {
+	Type  string
+	Count int
+
+	// Attributes found in the state that the excludes for the type would leave out, sorted.
+	ExcludedAttributes []string
+}
+
+// Summarizes a state with the excludes the generator uses for each resource type. The id attribute is
+// always reported as excluded.
+func (g *Generator) SummarizeState(state *terraform.State) (*StateSummary, error) {
+	summary := &StateSummary{}
+	types := map[string]*TypeSummary{}
+	excluded := map[string]map[string]bool{}
+
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, module := range state.Modules {
+		summary.Modules = append(summary.Modules, ModuleAddress(module.Path))
+	}
+	sort.Strings(summary.Modules)
+
+	for _, r := range resources {
+		t, ok := types[r.Key.Type]
+		if !ok {
+			t = &TypeSummary{Type: r.Key.Type}
+			types[r.Key.Type] = t
+			excluded[r.Key.Type] = map[string]bool{}
+		}
+		t.Count++
+
+		if r.State.Primary == nil {
+			continue
+		}
+
+		for attrName := range uniqueAttributeNames(r.State.Primary.Attributes) {
+			if g.isExcluded(r.Key.Type, attrName) {
+				excluded[r.Key.Type][attrName] = true
+			}
+		}
+	}
+
+	for resourceType, t := range types {
+		for attrName := range excluded[resourceType] {
+			t.ExcludedAttributes = append(t.ExcludedAttributes, attrName)
+		}
+		sort.Strings(t.ExcludedAttributes)
+
+		summary.Types = append(summary.Types, t)
+	}
+
+	sort.Slice(summary.Types, func(i, j int) bool {
+		return summary.Types[i].Type < summary.Types[j].Type
+	})
+
+	return summary, nil
+}
+
+func (s *StateSummary) String() string {
+	var b bytes.Buffer
+
+	modules := []string{}
+	for _, module := range s.Modules {
+		if module == "" {
+			module = "root"
+		}
+		modules = append(modules, module)
+	}
+	fmt.Fprintf(&b, "Modules: %d (%s)\n", len(modules), strings.Join(modules, ", "))
+
+	total := 0
+	for _, t := range s.Types {
+		total += t.Count
+	}
+	fmt.Fprintf(&b, "Resources: %d\n\n", total)
+
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tCOUNT\tEXCLUDED ATTRIBUTES")
+	for _, t := range s.Types {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", t.Type, t.Count, strings.Join(t.ExcludedAttributes, ", "))
+	}
+	w.Flush()
+
+	return b.String()
+}
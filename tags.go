@@ -0,0 +1,174 @@
+package terraconf
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Prefixes of tags managed by providers or the platform rather than set in config, e.g.
+// `aws:cloudformation:stack-name` or `kubernetes.io/cluster/main`.
+var ProviderManagedTagPrefixes = []string{"aws:", "kubernetes.io/"}
+
+// Controls NormalizeTags. A nil StripPrefixes strips ProviderManagedTagPrefixes.
+type TagNormalization struct {
+	// Tags with a key starting with one of the prefixes are removed.
+	StripPrefixes []string
+
+	// Tags set through the default_tags of the provider configuration. Tags of a resource equal to a
+	// default tag are removed since the provider adds them, leaving only the tags specific to the
+	// resource. Only set this for providers that support default_tags.
+	DefaultTags map[string]string
+}
+
+// Returns a transformer normalizing the tags attribute of resources. Provider managed tags and tags
+// covered by default tags are removed, along with the computed tags_all attribute which cannot be set
+// in config. Tags are always rendered sorted by key.
+func NormalizeTags(n *TagNormalization) ResourceTransformer {
+	if n == nil {
+		n = &TagNormalization{}
+	}
+
+	prefixes := n.StripPrefixes
+	if prefixes == nil {
+		prefixes = ProviderManagedTagPrefixes
+	}
+
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		delete(attrs, "tags_all")
+
+		tags, ok := attrs["tags"].(map[string]interface{})
+		if !ok {
+			return attrs
+		}
+
+		for k, v := range tags {
+			if hasAnyPrefix(k, prefixes) {
+				delete(tags, k)
+				continue
+			}
+
+			if defaultValue, ok := n.DefaultTags[k]; ok && v == defaultValue {
+				delete(tags, k)
+			}
+		}
+
+		if len(tags) == 0 {
+			delete(attrs, "tags")
+		}
+
+		return attrs
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tags set on every taggable AWS resource of a state, found by DetectDefaultTags, to be set once as
+// default_tags of the provider instead of on every resource.
+type DefaultTagsDetection struct {
+	Tags map[string]string
+
+	generator *Generator
+}
+
+// The schema the default_tags block is rendered with.
+var defaultTagsProviderSchema = &SchemaBlock{
+	BlockTypes: map[string]*SchemaBlockType{
+		"default_tags": {
+			NestingMode: "list",
+			Block:       &SchemaBlock{Attributes: map[string]*SchemaAttribute{"tags": {}}},
+		},
+	},
+}
+
+// Finds the tags with the same key and value on every taggable managed AWS resource of a state.
+// Resources are taggable when their schema has a tags attribute or, without a schema, when their state
+// records tags. Provider managed tags, see ProviderManagedTagPrefixes, are never detected since they
+// cannot be set in config.
+func (g *Generator) DetectDefaultTags(state *terraform.State) (*DefaultTagsDetection, error) {
+	d := &DefaultTagsDetection{Tags: map[string]string{}, generator: g}
+
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+
+	taggable := 0
+	for _, r := range resources {
+		if r.Key.Data || r.State.Primary == nil || providerName(r.Key.Type) != "aws" {
+			continue
+		}
+
+		attrs := r.State.Primary.Attributes
+		if _, ok := attrs["tags.%"]; !ok {
+			schemaBlock := g.typeConfig(r.Key.Type).schemaBlock
+			if schemaBlock == nil || schemaBlock.Attributes["tags"] == nil {
+				continue
+			}
+		}
+
+		tags := map[string]string{}
+		for k, v := range attrs {
+			if strings.HasPrefix(k, "tags.") && k != "tags.%" {
+				tags[strings.TrimPrefix(k, "tags.")] = v
+			}
+		}
+
+		if taggable == 0 {
+			for k, v := range tags {
+				if !hasAnyPrefix(k, ProviderManagedTagPrefixes) {
+					d.Tags[k] = v
+				}
+			}
+		} else {
+			for k, v := range d.Tags {
+				if existing, ok := tags[k]; !ok || existing != v {
+					delete(d.Tags, k)
+				}
+			}
+		}
+		taggable++
+	}
+
+	return d, nil
+}
+
+// Returns a transformer removing the detected tags from AWS resources, to be added for
+// AllResourceTypes. Like NormalizeTags, it also removes the computed tags_all attribute.
+func (d *DefaultTagsDetection) Transformer() ResourceTransformer {
+	normalize := NormalizeTags(&TagNormalization{StripPrefixes: []string{}, DefaultTags: d.Tags})
+
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		if providerName(resourceType) != "aws" {
+			return attrs
+		}
+
+		return normalize(resourceType, attrs)
+	}
+}
+
+// Renders the default aws provider block setting the detected tags as default_tags, or an empty string
+// when no tags were detected.
+func (d *DefaultTagsDetection) ProviderBlockString() (string, error) {
+	if len(d.Tags) == 0 {
+		return "", nil
+	}
+
+	g := d.generator
+	tags := map[string]interface{}{}
+	for k, v := range d.Tags {
+		tags[k] = v
+	}
+
+	body := g.schemaAttributeString("default_tags", []interface{}{map[string]interface{}{"tags": tags}}, defaultTagsProviderSchema)
+
+	return g.providerBlockString("aws", body)
+}
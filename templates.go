@@ -0,0 +1,131 @@
+package terraconf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// A value transform applied to the string values at an attribute path, e.g. to lowercase names or
+// replace a region with a variable. Templates are text/template templates executed with
+// ValueTemplateData and the functions of ValueTemplateFuncs.
+type ValueTemplate struct {
+	// Dot separated path of the values, with `*` matching any list index or map key, e.g. `name`,
+	// `tags.Name` or `ingress.*.description`.
+	Path     string
+	Template string
+
+	// Render the result without escaping interpolation sequences, e.g. for `${var.region}`.
+	Interpolated bool
+}
+
+type ValueTemplateData struct {
+	Type  string
+	Path  string
+	Value string
+}
+
+// Functions available to value templates in addition to the text/template builtins.
+var ValueTemplateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+	"replace": func(s string, old string, new string) string {
+		return strings.Replace(s, old, new, -1)
+	},
+	"regexReplace": func(s string, pattern string, repl string) (string, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", err
+		}
+		return re.ReplaceAllString(s, repl), nil
+	},
+	// Strips everything up to the resource part of an ARN, including the account ID, e.g. `role/admin`
+	// for `arn:aws:iam::123456789012:role/admin`. Other values are returned unchanged.
+	"arnResource": func(s string) string {
+		parts := strings.SplitN(s, ":", 6)
+		if len(parts) != 6 || parts[0] != "arn" {
+			return s
+		}
+		return parts[5]
+	},
+}
+
+type compiledValueTemplate struct {
+	path         []string
+	template     *template.Template
+	interpolated bool
+}
+
+// Returns a transformer applying value templates to the string values at their paths, in order. Values
+// of other types are left unchanged. Returns an error for templates that don't parse.
+func ValueTemplateTransformer(templates []*ValueTemplate) (ResourceTransformer, error) {
+	compiled := []*compiledValueTemplate{}
+
+	for _, vt := range templates {
+		t, err := template.New(vt.Path).Funcs(ValueTemplateFuncs).Option("missingkey=error").Parse(vt.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template for %s: %s", vt.Path, err)
+		}
+
+		compiled = append(compiled, &compiledValueTemplate{
+			path:         strings.Split(vt.Path, tfStateKeyDelimiter),
+			template:     t,
+			interpolated: vt.Interpolated,
+		})
+	}
+
+	return func(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+		for _, ct := range compiled {
+			v, ok := attrs[ct.path[0]]
+			if !ok {
+				continue
+			}
+
+			attrs[ct.path[0]] = ct.apply(resourceType, ct.path[0], v, ct.path[1:])
+		}
+
+		return attrs
+	}, nil
+}
+
+// Applies the template to the values at path within v, returning the new value.
+func (ct *compiledValueTemplate) apply(resourceType string, path string, v interface{}, rest []string) interface{} {
+	if len(rest) == 0 {
+		s, ok := v.(string)
+		if !ok {
+			return v
+		}
+
+		var b bytes.Buffer
+		// A failing template leaves the value unchanged rather than dropping it.
+		if err := ct.template.Execute(&b, &ValueTemplateData{Type: resourceType, Path: path, Value: s}); err != nil {
+			return v
+		}
+
+		if ct.interpolated {
+			return InterpolatedString(b.String())
+		}
+		return b.String()
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if rest[0] == "*" || rest[0] == k {
+				v[k] = ct.apply(resourceType, path+tfStateKeyDelimiter+k, item, rest[1:])
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if rest[0] == "*" || rest[0] == fmt.Sprintf("%d", i) {
+				v[i] = ct.apply(resourceType, fmt.Sprintf("%s%s%d", path, tfStateKeyDelimiter, i), item, rest[1:])
+			}
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,149 @@
+package terraconftest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Generates the config of a resource, builds a state holding the values of that config, and generates
+// the config again, returning both. They differ when the renderer cannot read back what it wrote, e.g.
+// for lists, maps or escaping. Config is always rendered in HCL2 syntax, the only one read back.
+// Options rendering anything but literal values, e.g. stubbed secrets, include files, comment rules or
+// annotations, make the configs differ by design.
+func RoundTrip(opts *terraconf.Options, state *terraform.ResourceState) (string, string, error) {
+	if opts == nil {
+		opts = terraconf.NewOptions()
+	} else {
+		opts = opts.Clone()
+	}
+	opts.Syntax = terraconf.SyntaxHCL2
+
+	// Every pass gets a generator of its own so the resource keeps its name.
+	first, err := terraconf.NewGenerator(opts).ResourceConfig(state)
+	if err != nil {
+		return "", "", err
+	}
+
+	attrs, err := configValues(first)
+	if err != nil {
+		return first, "", err
+	}
+
+	second, err := terraconf.NewGenerator(opts).ResourceConfig(NewResourceState(state.Type, state.Primary.ID, attrs))
+	if err != nil {
+		return first, "", err
+	}
+
+	return first, second, nil
+}
+
+// Fails the test when the config of a resource changes in a round trip, see RoundTrip.
+func AssertRoundTrip(t testing.TB, opts *terraconf.Options, state *terraform.ResourceState) {
+	t.Helper()
+
+	first, second, err := RoundTrip(opts, state)
+	if err != nil {
+		t.Fatalf("round trip of %s: %s", state.Type, err)
+	}
+
+	if first != second {
+		t.Errorf("config of %s changed in a round trip:\n--- generated\n%s\n--- regenerated\n%s", state.Type, first, second)
+	}
+}
+
+// Reads the values of the single resource block of a config, with nested blocks as lists of maps.
+func configValues(config string) (map[string]interface{}, error) {
+	f, diags := hclsyntax.ParseConfig([]byte(config), "generated.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing generated config: %s", diags)
+	}
+
+	blocks := f.Body.(*hclsyntax.Body).Blocks
+	if len(blocks) != 1 {
+		return nil, fmt.Errorf("generated config has %d blocks, expected 1", len(blocks))
+	}
+
+	return bodyValues(blocks[0].Body)
+}
+
+func bodyValues(body *hclsyntax.Body) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	for name, attr := range body.Attributes {
+		v, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("reading %s: %s", name, diags)
+		}
+
+		value, err := ctyValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %s", name, err)
+		}
+		if value != nil {
+			values[name] = value
+		}
+	}
+
+	for _, block := range body.Blocks {
+		nested, err := bodyValues(block.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %s", block.Type, err)
+		}
+
+		list, _ := values[block.Type].([]interface{})
+		values[block.Type] = append(list, nested)
+	}
+
+	return values, nil
+}
+
+// Converts a value to the types state attributes are flattened from. Numbers become strings since the
+// state stores them as such.
+func ctyValue(v cty.Value) (interface{}, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+	if !v.IsKnown() {
+		return nil, fmt.Errorf("unknown value")
+	}
+
+	t := v.Type()
+	switch {
+	case t == cty.String:
+		return v.AsString(), nil
+	case t == cty.Number:
+		return v.AsBigFloat().Text('f', -1), nil
+	case t == cty.Bool:
+		return v.True(), nil
+	case t.IsListType() || t.IsSetType() || t.IsTupleType():
+		list := []interface{}{}
+		for it := v.ElementIterator(); it.Next(); {
+			_, element := it.Element()
+			item, err := ctyValue(element)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case t.IsMapType() || t.IsObjectType():
+		m := map[string]interface{}{}
+		for it := v.ElementIterator(); it.Next(); {
+			k, element := it.Element()
+			item, err := ctyValue(element)
+			if err != nil {
+				return nil, err
+			}
+			m[k.AsString()] = item
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t.FriendlyName())
+}
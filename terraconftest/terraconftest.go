@@ -0,0 +1,75 @@
+// Package terraconftest provides helpers for regression testing generated config, e.g. to check the
+// excludes and defaults a project passes to terraconf against golden files.
+package terraconftest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/flatmap"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Set to a non-empty value to rewrite golden files with the actual output instead of comparing.
+const UpdateGoldenEnv = "TERRACONF_UPDATE_GOLDEN"
+
+// Directory golden files are read from, relative to the package under test.
+var GoldenDir = "testdata"
+
+// Builds a resource state from expanded attribute values, which are flattened the same way terraform
+// stores them. The id attribute is set from id.
+func NewResourceState(resourceType string, id string, attrs map[string]interface{}) *terraform.ResourceState {
+	flatAttrs := map[string]string(flatmap.Flatten(attrs))
+	flatAttrs["id"] = id
+
+	return &terraform.ResourceState{
+		Type: resourceType,
+		Primary: &terraform.InstanceState{
+			ID:         id,
+			Attributes: flatAttrs,
+		},
+	}
+}
+
+// Builds a state with the given resources, keyed as in modules[].resources, in the root module.
+func NewState(resources map[string]*terraform.ResourceState) *terraform.State {
+	return &terraform.State{
+		Version: terraform.StateVersion,
+		Modules: []*terraform.ModuleState{
+			{
+				Path:      []string{"root"},
+				Resources: resources,
+			},
+		},
+	}
+}
+
+// Compares actual with the golden file GoldenDir/<name>.golden, failing the test on a difference.
+// Setting UpdateGoldenEnv writes actual to the golden file instead.
+func AssertGolden(t testing.TB, name string, actual string) {
+	t.Helper()
+
+	path := filepath.Join(GoldenDir, name+".golden")
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating golden file directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("writing golden file: %s", err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (set %s=1 to create it): %s", UpdateGoldenEnv, err)
+	}
+
+	if !bytes.Equal(expected, []byte(actual)) {
+		t.Errorf("output does not match %s (set %s=1 to update):\n--- expected\n%s\n--- actual\n%s", path, UpdateGoldenEnv, expected, actual)
+	}
+}
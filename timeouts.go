@@ -0,0 +1,88 @@
+package terraconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Key of the instance meta data the helper/schema SDK stores resource timeouts under, in nanoseconds
+// by operation.
+const timeoutsMetaKey = "e2bfb730-ecaa-11e6-8f88-34363bc7c4c0"
+
+var timeoutOperations = []string{"create", "read", "update", "delete", "default"}
+
+// Renders the timeouts block from the timeouts stored in the instance meta data, unformatted, e.g.
+// `timeouts { create = "30m" }`. Returns an empty string when the state has no timeouts, already has a
+// timeouts attribute or timeouts are excluded.
+func (g *Generator) timeoutsString(state *terraform.ResourceState) string {
+	if state.Primary == nil || g.isExcluded(state.Type, "timeouts") {
+		return ""
+	}
+
+	if _, ok := uniqueAttributeNames(state.Primary.Attributes)["timeouts"]; ok {
+		return ""
+	}
+
+	timeouts, ok := state.Primary.Meta[timeoutsMetaKey].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, operation := range timeoutOperations {
+		d, ok := metaDuration(timeouts[operation])
+		if !ok || d <= 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s = %q\n", operation, formatTimeout(d))
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	return "\ntimeouts {\n" + b.String() + "}\n"
+}
+
+// Reads a duration in nanoseconds, as decoded from JSON or set in memory.
+func metaDuration(v interface{}) (time.Duration, bool) {
+	switch v := v.(type) {
+	case float64:
+		return time.Duration(v), true
+	case int:
+		return time.Duration(v), true
+	case int64:
+		return time.Duration(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return time.Duration(n), err == nil
+	}
+
+	return 0, false
+}
+
+// Formats a duration the way timeouts are usually written, e.g. `30m` or `1h30m` rather than the
+// `30m0s` of time.Duration.String. Fractions of seconds are dropped.
+func formatTimeout(d time.Duration) string {
+	h := d / time.Hour
+	m := (d % time.Hour) / time.Minute
+	sec := (d % time.Minute) / time.Second
+
+	s := ""
+	if h > 0 {
+		s += fmt.Sprintf("%dh", h)
+	}
+	if m > 0 {
+		s += fmt.Sprintf("%dm", m)
+	}
+	if sec > 0 || s == "" {
+		s += fmt.Sprintf("%ds", sec)
+	}
+
+	return s
+}
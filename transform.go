@@ -0,0 +1,16 @@
+package terraconf
+
+// Matches every resource type when adding a ResourceTransformer or AttributeOrder.
+const AllResourceTypes = "*"
+
+// Rewrites the expanded attributes of a resource before it is rendered, e.g. to strip provider managed
+// tags or convert timestamps. The returned map replaces the attributes and may be the modified input.
+type ResourceTransformer func(resourceType string, attrs map[string]interface{}) map[string]interface{}
+
+func (g *Generator) transformAttributes(resourceType string, attrs map[string]interface{}) map[string]interface{} {
+	for _, transformer := range g.typeConfig(resourceType).transformers {
+		attrs = transformer(resourceType, attrs)
+	}
+
+	return attrs
+}
@@ -0,0 +1,26 @@
+package terraconf
+
+// Attributes of the null, random and tls providers holding values generated on create, set as
+// TypeExcludes by NewOptions. Their resources are reconstructed from their arguments, e.g. the length
+// and keepers of a random_password, instead of rendering results that cannot be set in config and
+// are often sensitive.
+var UtilityProviderOutputs = map[string][]string{
+	"random_id":               {"b64", "b64_std", "b64_url", "dec", "hex"},
+	"random_integer":          {"result"},
+	"random_password":         {"bcrypt_hash", "result"},
+	"random_shuffle":          {"result"},
+	"random_string":           {"result"},
+	"random_uuid":             {"result"},
+	"tls_private_key":         {"private_key_openssh", "private_key_pem", "private_key_pem_pkcs8", "public_key_fingerprint_md5", "public_key_fingerprint_sha256", "public_key_openssh", "public_key_pem"},
+	"tls_cert_request":        {"cert_request_pem"},
+	"tls_self_signed_cert":    {"cert_pem", "ready_for_renewal", "validity_end_time", "validity_start_time"},
+	"tls_locally_signed_cert": {"cert_pem", "ready_for_renewal", "validity_end_time", "validity_start_time"},
+}
+
+// Private keys the tls provider takes as arguments, set as TypeSecrets by NewOptions. They usually
+// reference a tls_private_key, so they are stubbed with Options.StubSecrets rather than rendered.
+var UtilityProviderSecrets = map[string][]string{
+	"tls_cert_request":        {"private_key_pem"},
+	"tls_self_signed_cert":    {"private_key_pem"},
+	"tls_locally_signed_cert": {"ca_private_key_pem"},
+}
@@ -0,0 +1,141 @@
+package terraconf
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// A resource terraform plans to change although its config was generated from state.
+type PlanDrift struct {
+	// Address of the resource in the generated config.
+	Address string
+	Actions []string
+
+	// Top level attributes whose planned value differs from their current value, sorted by name.
+	Attributes []*PlanAttributeDrift
+}
+
+type PlanAttributeDrift struct {
+	Name   string
+	Before interface{}
+	After  interface{}
+
+	// Source of the generated attribute as in IRAttribute, e.g. "default" for a default that doesn't
+	// match the real value, or empty when the attribute wasn't generated at all.
+	Source string
+}
+
+// Runs `terraform init` and `terraform plan` in a working directory holding generated config and the
+// state it was generated from, using the terraform binary at execPath, and reports every resource the
+// plan would change. Changes are mapped back to the generated attributes described by resources, e.g.
+// from Generator.ResourceIR, by config address. Requires terraform 0.12 or later for the JSON plan.
+func VerifyPlan(ctx context.Context, workingDir string, execPath string, resources []*IRResource) ([]*PlanDrift, error) {
+	tf, err := tfexec.NewTerraform(workingDir, execPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tf.Init(ctx); err != nil {
+		return nil, fmt.Errorf("terraform init: %s", err)
+	}
+
+	planDir, err := ioutil.TempDir("", "terraconf-plan")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(planDir)
+
+	planFile := filepath.Join(planDir, "plan.tfplan")
+	hasChanges, err := tf.Plan(ctx, tfexec.Out(planFile))
+	if err != nil {
+		return nil, fmt.Errorf("terraform plan: %s", err)
+	}
+	if !hasChanges {
+		return nil, nil
+	}
+
+	plan, err := tf.ShowPlanFile(ctx, planFile)
+	if err != nil {
+		return nil, fmt.Errorf("terraform show: %s", err)
+	}
+
+	return PlanDrifts(plan, resources), nil
+}
+
+// Maps the resource changes of a JSON plan, e.g. from `terraform show -json`, to the generated
+// resources by config address, including their module and, for instances collapsed into a block with
+// count, their index, e.g. `module.app.aws_instance.web[1]`.
+func PlanDrifts(plan *tfjson.Plan, resources []*IRResource) []*PlanDrift {
+	byAddress := map[string]*IRResource{}
+	for _, r := range resources {
+		k := &ResourceKey{Data: r.Mode == "data", Type: r.Type, Name: r.Name}
+		byAddress[ResourceAddress(r.Module, k)] = r
+
+		if r.CountName != "" {
+			k := &ResourceKey{Data: k.Data, Type: r.Type, Name: r.CountName, Index: r.Index}
+			byAddress[ResourceAddress(r.Module, k)] = r
+		}
+	}
+
+	drifts := []*PlanDrift{}
+
+	for _, change := range plan.ResourceChanges {
+		if change.Change == nil || change.Change.Actions.NoOp() || change.Change.Actions.Read() {
+			continue
+		}
+
+		drift := &PlanDrift{Address: change.Address}
+		for _, action := range change.Change.Actions {
+			drift.Actions = append(drift.Actions, string(action))
+		}
+
+		sources := map[string]string{}
+		if r, ok := byAddress[change.Address]; ok {
+			for _, attr := range r.Attributes {
+				sources[attr.Name] = attr.Source
+			}
+		}
+
+		before, _ := change.Change.Before.(map[string]interface{})
+		after, _ := change.Change.After.(map[string]interface{})
+
+		attrNames := map[string]bool{}
+		for attrName := range before {
+			attrNames[attrName] = true
+		}
+		for attrName := range after {
+			attrNames[attrName] = true
+		}
+
+		sortedAttrNames := []string{}
+		for attrName := range attrNames {
+			sortedAttrNames = append(sortedAttrNames, attrName)
+		}
+		sort.Strings(sortedAttrNames)
+
+		for _, attrName := range sortedAttrNames {
+			if reflect.DeepEqual(before[attrName], after[attrName]) {
+				continue
+			}
+
+			drift.Attributes = append(drift.Attributes, &PlanAttributeDrift{
+				Name:   attrName,
+				Before: before[attrName],
+				After:  after[attrName],
+				Source: sources[attrName],
+			})
+		}
+
+		drifts = append(drifts, drift)
+	}
+
+	return drifts
+}
@@ -0,0 +1,154 @@
+package terraconf_test
+
+import (
+	"fmt"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+	"github.com/jmseaton/terraconf/terraconftest"
+)
+
+// Planned changes are mapped back to the attributes they were generated from, and no-op changes are
+// left out.
+func TestPlanDrifts(t *testing.T) {
+	state := terraconftest.NewState(map[string]*terraform.ResourceState{
+		"aws_instance.web":   terraconftest.NewResourceState("aws_instance", "web", map[string]interface{}{"instance_type": "t3.micro"}),
+		"aws_s3_bucket.logs": terraconftest.NewResourceState("aws_s3_bucket", "logs", map[string]interface{}{"bucket": "logs"}),
+	})
+
+	g := terraconf.NewGenerator(terraconf.NewOptions())
+
+	resources, err := g.StateResources(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := []*terraconf.IRResource{}
+	for _, r := range resources {
+		resourceIR, err := g.ResourceIR(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ir = append(ir, resourceIR)
+	}
+
+	update := tfjson.Actions{tfjson.ActionUpdate}
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: "aws_instance.web",
+				Change: &tfjson.Change{
+					Actions: update,
+					Before:  map[string]interface{}{"instance_type": "t3.micro"},
+					After:   map[string]interface{}{"instance_type": "t3.large", "monitoring": true},
+				},
+			},
+			{
+				Address: "aws_s3_bucket.logs",
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionNoop},
+					Before:  map[string]interface{}{"bucket": "logs"},
+					After:   map[string]interface{}{"bucket": "logs"},
+				},
+			},
+		},
+	}
+
+	drifts := terraconf.PlanDrifts(plan, ir)
+	if len(drifts) != 1 || drifts[0].Address != "aws_instance.web" {
+		t.Fatalf("expected a drift of aws_instance.web only, got %d drifts", len(drifts))
+	}
+
+	got := []string{}
+	for _, attr := range drifts[0].Attributes {
+		got = append(got, fmt.Sprintf("%s from %q", attr.Name, attr.Source))
+	}
+	if expected := []string{`instance_type from "state"`, `monitoring from ""`}; fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+// Changes to resources in modules and to instances collapsed into a block with count are mapped back
+// to the attributes they were generated from.
+func TestPlanDriftsAddresses(t *testing.T) {
+	state := &terraform.State{
+		Version: terraform.StateVersion,
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_instance.web.0": terraconftest.NewResourceState("aws_instance", "i-0", map[string]interface{}{"instance_type": "t3.micro"}),
+					"aws_instance.web.1": terraconftest.NewResourceState("aws_instance", "i-1", map[string]interface{}{"instance_type": "t3.micro"}),
+				},
+			},
+			{
+				Path: []string{"root", "app"},
+				Resources: map[string]*terraform.ResourceState{
+					"aws_s3_bucket.logs": terraconftest.NewResourceState("aws_s3_bucket", "logs", map[string]interface{}{"bucket": "logs"}),
+				},
+			},
+		},
+	}
+
+	opts := terraconf.NewOptions()
+	opts.CollapseCounts = true
+	g := terraconf.NewGenerator(opts)
+
+	resources, err := g.StateResources(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := []*terraconf.IRResource{}
+	for _, r := range resources {
+		resourceIR, err := g.ResourceIR(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ir = append(ir, resourceIR)
+	}
+
+	update := tfjson.Actions{tfjson.ActionUpdate}
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: "aws_instance.web[1]",
+				Change: &tfjson.Change{
+					Actions: update,
+					Before:  map[string]interface{}{"instance_type": "t3.micro"},
+					After:   map[string]interface{}{"instance_type": "t3.large"},
+				},
+			},
+			{
+				Address: "module.app.aws_s3_bucket.logs",
+				Change: &tfjson.Change{
+					Actions: update,
+					Before:  map[string]interface{}{"bucket": "logs"},
+					After:   map[string]interface{}{"bucket": "logs-replica"},
+				},
+			},
+		},
+	}
+
+	drifts := terraconf.PlanDrifts(plan, ir)
+
+	expected := map[string]struct{ attribute, source string }{
+		"aws_instance.web[1]":           {"instance_type", "state"},
+		"module.app.aws_s3_bucket.logs": {"bucket", "state"},
+	}
+	if len(drifts) != len(expected) {
+		t.Fatalf("expected %d drifts, got %d", len(expected), len(drifts))
+	}
+	for _, drift := range drifts {
+		e := expected[drift.Address]
+		got := []string{}
+		for _, attr := range drift.Attributes {
+			got = append(got, fmt.Sprintf("%s from %q", attr.Name, attr.Source))
+		}
+		if want := fmt.Sprintf("%s from %q", e.attribute, e.source); len(got) != 1 || got[0] != want {
+			t.Errorf("%s: expected %s, got %v", drift.Address, want, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package terraconf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Minimum terraform versions of the features generated config may use.
+const (
+	hcl2MinVersion               = "0.12.0"
+	sensitiveVariablesMinVersion = "0.14.0"
+	movedBlocksMinVersion        = "1.1.0"
+	postconditionsMinVersion     = "1.2.0"
+	startswithMinVersion         = "1.3.0"
+	checkBlocksMinVersion        = "1.5.0"
+)
+
+// A terraform version constraint for generated config, inferred by Generator.RequiredVersion.
+type VersionRequirement struct {
+	// Version of terraform that last wrote the state, or empty when the state doesn't record it.
+	StateVersion string
+
+	// Minimum version the generated config needs for its syntax, and the features needing it, e.g.
+	// "HCL2 syntax".
+	SyntaxVersion  string
+	SyntaxFeatures []string
+
+	// The inferred constraint, e.g. `>= 0.11.14`, or empty when nothing is known.
+	Constraint string
+
+	// Problems found while inferring the constraint, e.g. config that needs a newer version than the
+	// one that wrote the state.
+	Warnings []string
+}
+
+// Infers the required_version of generated config from the terraform version that wrote a state and
+// the features the options make the generated config use: HCL2 syntax, sensitive variables for stubbed
+// secrets, postconditions or check blocks for invariants, the startswith function for prefix
+// invariants, and moved blocks for remap rules. Config for a state should not need an
+// older terraform than the one that wrote it, since older versions refuse to read the state. The
+// schema versions recorded in the state are compared with Options.Schemas, warning about resources
+// written by a newer provider than the schemas describe.
+func (g *Generator) RequiredVersion(state *terraform.State) (*VersionRequirement, error) {
+	v := &VersionRequirement{StateVersion: state.TFVersion}
+
+	features := []struct {
+		name    string
+		version string
+		used    bool
+	}{
+		{"HCL2 syntax", hcl2MinVersion, g.opts.Syntax == SyntaxHCL2},
+		{"sensitive variables", sensitiveVariablesMinVersion, g.opts.Syntax == SyntaxHCL2 && g.opts.StubSecrets},
+		{"moved blocks", movedBlocksMinVersion, len(g.opts.RemapRules) > 0},
+		{"postconditions", postconditionsMinVersion, g.opts.Syntax == SyntaxHCL2 && g.opts.InvariantStyle == InvariantPostconditions && len(g.opts.Invariants) > 0},
+		{"startswith", startswithMinVersion, g.opts.Syntax == SyntaxHCL2 && g.hasPrefixInvariants()},
+		{"check blocks", checkBlocksMinVersion, g.opts.Syntax == SyntaxHCL2 && g.opts.InvariantStyle == InvariantChecks && len(g.opts.Invariants) > 0},
+	}
+	for _, feature := range features {
+		if !feature.used {
+			continue
+		}
+
+		v.SyntaxFeatures = append(v.SyntaxFeatures, feature.name)
+		if compareVersions(feature.version, v.SyntaxVersion) > 0 {
+			v.SyntaxVersion = feature.version
+		}
+	}
+
+	minVersion := v.SyntaxVersion
+	if v.StateVersion != "" {
+		if _, ok := parseVersion(v.StateVersion); !ok {
+			return nil, fmt.Errorf("invalid terraform version %q in state", v.StateVersion)
+		}
+
+		if compareVersions(v.SyntaxVersion, v.StateVersion) > 0 {
+			v.Warnings = append(v.Warnings, fmt.Sprintf("generated config uses %s, which need terraform %s, but the state was written by terraform %s", strings.Join(v.SyntaxFeatures, ", "), v.SyntaxVersion, v.StateVersion))
+		} else {
+			minVersion = v.StateVersion
+		}
+	}
+
+	if minVersion != "" {
+		v.Constraint = ">= " + minVersion
+	}
+
+	resources, err := g.StateResources(state)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range g.SchemaVersionMismatches(resources) {
+		if m.StateVersion > m.SchemaVersion {
+			v.Warnings = append(v.Warnings, fmt.Sprintf("%s was written with schema version %d by a newer provider than the schemas describe (%d)", m.Address, m.StateVersion, m.SchemaVersion))
+		}
+	}
+
+	return v, nil
+}
+
+// Renders a terraform block setting required_version to the constraint, or an empty string when
+// there is none.
+func (g *Generator) TerraformBlockString(v *VersionRequirement) (string, error) {
+	if v.Constraint == "" {
+		return "", nil
+	}
+
+	b, err := g.format(fmt.Sprintf("terraform {\n%s}\n", g.primitiveAttributeString("required_version", v.Constraint)))
+	if err != nil {
+		return "", fmt.Errorf("formatting terraform block: %s", err)
+	}
+
+	return b, nil
+}
+
+// Parses the numeric parts of a version such as `0.11.14` or `v1.5.0-beta1`, ignoring pre-release and
+// build suffixes.
+func parseVersion(s string) ([]int, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+
+	parts := []int{}
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+
+	return parts, true
+}
+
+// Compares two versions numerically, returning -1, 0 or 1. Empty or invalid versions are lower than
+// any valid one.
+func compareVersions(a string, b string) int {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := 0, 0
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,47 @@
+package terraconf_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jmseaton/terraconf"
+)
+
+func TestRequiredVersionFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(opts *terraconf.Options)
+		version  string
+		features []string
+	}{
+		{"stubbed secrets", func(opts *terraconf.Options) {
+			opts.StubSecrets = true
+		}, "0.14.0", []string{"HCL2 syntax", "sensitive variables"}},
+		{"postconditions", func(opts *terraconf.Options) {
+			opts.Invariants["aws_instance"] = []*terraconf.AttributeInvariant{{Attribute: "ami"}}
+		}, "1.2.0", []string{"HCL2 syntax", "postconditions"}},
+		{"prefix postconditions", func(opts *terraconf.Options) {
+			opts.Invariants["aws_instance"] = []*terraconf.AttributeInvariant{{Attribute: "instance_type", PrefixSeparator: "."}}
+		}, "1.3.0", []string{"HCL2 syntax", "postconditions", "startswith"}},
+		{"prefix checks", func(opts *terraconf.Options) {
+			opts.InvariantStyle = terraconf.InvariantChecks
+			opts.Invariants["aws_instance"] = []*terraconf.AttributeInvariant{{Attribute: "instance_type", PrefixSeparator: "."}}
+		}, "1.5.0", []string{"HCL2 syntax", "startswith", "check blocks"}},
+	}
+
+	for _, test := range tests {
+		opts := terraconf.NewOptions()
+		opts.Syntax = terraconf.SyntaxHCL2
+		test.setup(opts)
+
+		v, err := terraconf.NewGenerator(opts).RequiredVersion(&terraform.State{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v.SyntaxVersion != test.version || !reflect.DeepEqual(v.SyntaxFeatures, test.features) {
+			t.Errorf("%s: expected %s for %v, got %s for %v", test.name, test.version, test.features, v.SyntaxVersion, v.SyntaxFeatures)
+		}
+	}
+}